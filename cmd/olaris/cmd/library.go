@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gitlab.com/olaris/olaris-server/metadata/app"
 	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
 )
 
 var libraryCmd = &cobra.Command{
@@ -33,6 +35,109 @@ var libraryCreateCmd = &cobra.Command{
 	},
 }
 
+var libraryID uint
+var rootID uint
+
+var libraryAddRootCmd = &cobra.Command{
+	Use:   "add-root",
+	Short: "Add another directory to a library",
+	Long: `Add another directory to a library, e.g. on a second disk or another Rclone remote.
+
+A running server picks up the new directory after it is restarted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mctx := app.NewDefaultMDContext()
+		defer mctx.Db.Close()
+
+		return db.AddLibraryRoot(&db.LibraryRoot{LibraryID: libraryID, FilePath: filePath, Backend: backendType, RcloneName: rcloneName})
+	},
+}
+
+var libraryRemoveRootCmd = &cobra.Command{
+	Use:   "remove-root",
+	Short: "Remove a directory from a library together with the metadata of its files",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mctx := app.NewDefaultMDContext()
+		defer mctx.Db.Close()
+
+		root, err := db.FindLibraryRoot(rootID)
+		if err != nil {
+			return fmt.Errorf("library root %d not found", rootID)
+		}
+		return db.DeleteLibraryRoot(root)
+	},
+}
+var metadataLanguage string
+var metadataRegion string
+var agent string
+var rescanInterval time.Duration
+var vfsCacheMode string
+var vfsCacheMaxSizeMB int64
+var vfsChunkSizeMB int64
+var probeConcurrency int
+
+var librarySettingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "Show and change the settings of a library",
+	Long: `Show the settings of a library, changing the ones given as flags first.
+
+Changes are picked up by a running server after it is restarted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mctx := app.NewDefaultMDContext()
+		defer mctx.Db.Close()
+
+		if db.FindLibrary(int(libraryID)).ID == 0 {
+			return fmt.Errorf("library %d not found", libraryID)
+		}
+
+		settings := db.FindLibrarySettings(libraryID)
+		flags := cmd.Flags()
+		if flags.Changed("metadata_language") {
+			settings.MetadataLanguage = metadataLanguage
+		}
+		if flags.Changed("metadata_region") {
+			settings.MetadataRegion = metadataRegion
+		}
+		if flags.Changed("agent") {
+			settings.Agent = agent
+		}
+		if flags.Changed("rescan_interval") {
+			settings.RescanInterval = rescanInterval
+		}
+		if flags.Changed("vfs_cache_mode") {
+			settings.VFSCacheMode = vfsCacheMode
+		}
+		if flags.Changed("vfs_cache_max_size_mb") {
+			settings.VFSCacheMaxSize = vfsCacheMaxSizeMB << 20
+			if vfsCacheMaxSizeMB < 0 {
+				settings.VFSCacheMaxSize = -1
+			}
+		}
+		if flags.Changed("vfs_chunk_size_mb") {
+			settings.VFSChunkSize = vfsChunkSizeMB << 20
+		}
+		if flags.Changed("probe_concurrency") {
+			settings.ProbeConcurrency = probeConcurrency
+		}
+
+		// All flags but --id change a setting
+		if flags.NFlag() > 1 {
+			if err := db.SaveLibrarySettings(settings); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Metadata language: %s\n", settings.MetadataLanguage)
+		fmt.Printf("Metadata region:   %s\n", settings.MetadataRegion)
+		fmt.Printf("Agent:             %s\n", settings.Agent)
+		fmt.Printf("Rescan interval:   %s\n", settings.RescanInterval)
+		fmt.Printf("VFS cache mode:    %s\n", settings.VFSCacheMode)
+		fmt.Printf("VFS cache size:    %d\n", settings.VFSCacheMaxSize)
+		fmt.Printf("VFS chunk size:    %d\n", settings.VFSChunkSize)
+		fmt.Printf("Probe concurrency: %d\n", settings.ProbeConcurrency)
+		return nil
+	},
+}
+
 func init() {
 	libraryCreateCmd.Flags().StringVar(&name, "name", "", "A name for this library")
 	libraryCreateCmd.MarkFlagRequired("name")
@@ -46,5 +151,31 @@ func init() {
 
 	libraryCmd.AddCommand(libraryCreateCmd)
 
+	libraryAddRootCmd.Flags().UintVar(&libraryID, "id", 0, "ID of the library")
+	libraryAddRootCmd.MarkFlagRequired("id")
+	libraryAddRootCmd.Flags().StringVar(&filePath, "path", "", "Path of the directory")
+	libraryAddRootCmd.MarkFlagRequired("path")
+	libraryAddRootCmd.Flags().IntVar(&backendType, "backend_type", 0, "Backend type, 0 for Local, 1 for Rclone")
+	libraryAddRootCmd.Flags().StringVar(&rcloneName, "rclone_name", "", "Name for the Rclone remote")
+	libraryCmd.AddCommand(libraryAddRootCmd)
+
+	libraryRemoveRootCmd.Flags().UintVar(&rootID, "root_id", 0, "ID of the library root")
+	libraryRemoveRootCmd.MarkFlagRequired("root_id")
+	libraryCmd.AddCommand(libraryRemoveRootCmd)
+
+	librarySettingsCmd.Flags().UintVar(&libraryID, "id", 0, "ID of the library")
+	librarySettingsCmd.MarkFlagRequired("id")
+
+	librarySettingsCmd.Flags().StringVar(&metadataLanguage, "metadata_language", "", "ISO 639-1 code of the language metadata is retrieved in, e.g. \"de\"")
+	librarySettingsCmd.Flags().StringVar(&metadataRegion, "metadata_region", "", "ISO 3166-1 code of the country whose release information is preferred, e.g. \"DE\"")
+	librarySettingsCmd.Flags().StringVar(&agent, "agent", db.AgentTmdb, "Metadata agent, \"tmdb\" or \"none\" to only identify files by hand")
+	librarySettingsCmd.Flags().DurationVar(&rescanInterval, "rescan_interval", 0, "How often the library is rescanned automatically, 0 for never")
+	librarySettingsCmd.Flags().StringVar(&vfsCacheMode, "vfs_cache_mode", "minimal", "Rclone VFS cache mode: off, minimal, writes or full")
+	librarySettingsCmd.Flags().Int64Var(&vfsCacheMaxSizeMB, "vfs_cache_max_size_mb", -1, "Size in MiB the rclone VFS cache is kept below, -1 for unlimited")
+	librarySettingsCmd.Flags().Int64Var(&vfsChunkSizeMB, "vfs_chunk_size_mb", 32, "Size in MiB of the chunks files are requested from the rclone remote in")
+	librarySettingsCmd.Flags().IntVar(&probeConcurrency, "probe_concurrency", db.DefaultProbeConcurrency, "How many files are probed at the same time")
+
+	libraryCmd.AddCommand(librarySettingsCmd)
+
 	rootCmd.AddCommand(libraryCmd)
 }
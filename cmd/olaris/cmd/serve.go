@@ -55,9 +55,6 @@ var serveCmd = &cobra.Command{
 		// This is also relevant during development because the realize auto-reload
 		// tool doesn't properly send SIGTERM.
 		ffmpeg.CleanTranscodingCache()
-		// TODO(Leon Handreke): Find a better way to do this, maybe a global flag?
-		streaming.FfmpegUrlPort = port
-		ffmpeg.FfmpegUrlPort = port
 
 		appRoute := rrr.PathPrefix("/app").
 			Handler(http.StripPrefix("/olaris/app", react.GetHandler())).
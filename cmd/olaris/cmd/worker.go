@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+var workerServerURL string
+var workerPort int
+var workerURL string
+var workerName string
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Run transcoding jobs for an olaris server",
+	Long: "Registers with an olaris server and transcodes on its behalf. " +
+		"The server and the worker must be started with the same --transcoding_worker_secret.",
+	Run: func(cmd *cobra.Command, args []string) {
+		hostname, _ := os.Hostname()
+		if workerName == "" {
+			workerName = hostname
+		}
+		if workerURL == "" {
+			workerURL = fmt.Sprintf("http://%s:%d", hostname, workerPort)
+		}
+
+		worker := ffmpeg.NewTranscodingWorker(
+			strings.TrimSuffix(workerServerURL, "/"), strings.TrimSuffix(workerURL, "/"), workerName)
+
+		log.Infoln("binding on port", workerPort)
+		srv := &http.Server{Addr: fmt.Sprintf(":%d", workerPort), Handler: worker.Handler()}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.WithFields(log.Fields{"error": err}).Fatal("Error starting worker.")
+			}
+		}()
+
+		stopRegistering := make(chan bool)
+		go worker.KeepRegistered(stopRegistering)
+
+		stopChan := make(chan os.Signal, 1)
+		signal.Notify(stopChan, os.Interrupt)
+		signal.Notify(stopChan, os.Kill)
+
+		// Wait for termination signal
+		<-stopChan
+		log.Println("Shutting down...")
+
+		close(stopRegistering)
+		worker.Shutdown()
+		srv.Close()
+		log.Println("Shut down complete, exiting.")
+	},
+}
+
+func init() {
+	workerCmd.Flags().StringVar(&workerServerURL, "server", "", "Base URL of the olaris server, e.g. http://nas:8080")
+	workerCmd.MarkFlagRequired("server")
+	workerCmd.Flags().IntVarP(&workerPort, "port", "p", 8090, "http port")
+	workerCmd.Flags().StringVar(&workerURL, "url", "", "Base URL under which the server can reach this worker, defaults to http://<hostname>:<port>")
+	workerCmd.Flags().StringVar(&workerName, "name", "", "A name for this worker, defaults to the hostname")
+	rootCmd.AddCommand(workerCmd)
+}
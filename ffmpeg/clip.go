@@ -0,0 +1,162 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"gitlab.com/olaris/olaris-server/ffmpeg/executable"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClipVideoPreset is the video encoder preset clips are encoded with. Clips are meant to be
+// shared, so we keep them small rather than preserving the original quality.
+const ClipVideoPreset = "720-5000k-video"
+
+// Subtitle codecs that are bitmaps and have to be burned in with the overlay filter instead of
+// being rendered by libass.
+var imageSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// IsImageSubtitleCodec returns whether subtitles in the given codec are bitmaps rather than text.
+func IsImageSubtitleCodec(codecName string) bool {
+	return imageSubtitleCodecs[codecName]
+}
+
+// ClipOptions describes the part of a file that is cut into a clip.
+type ClipOptions struct {
+	Start time.Duration
+	End   time.Duration
+	// SubtitleStreamID is the ffmpeg stream ID of an embedded subtitle stream to burn into the
+	// video, or -1 for none.
+	SubtitleStreamID int64
+}
+
+// escapeFilterValue escapes a value for use as a filter option inside a filtergraph. ffmpeg
+// unescapes filtergraphs twice, once for the graph and once for the filter options.
+func escapeFilterValue(value string) string {
+	optionEscaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+	graphEscaper := strings.NewReplacer(
+		`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`)
+	return graphEscaper.Replace(optionEscaper.Replace(value))
+}
+
+// buildClipVideoFilter returns the -filter_complex graph that scales the video and burns in the
+// given subtitle stream, if any. The result is labelled [v].
+func buildClipVideoFilter(
+	fileURL string,
+	container *ProbeContainer,
+	videoStreamID int64,
+	encoderParams EncoderParams,
+	options ClipOptions) (string, error) {
+
+	scale := fmt.Sprintf("scale=%d:%d", encoderParams.width, encoderParams.height)
+	video := fmt.Sprintf("[0:%d]", videoStreamID)
+
+	if options.SubtitleStreamID < 0 {
+		return fmt.Sprintf("%s%s[v]", video, scale), nil
+	}
+
+	// libass' subtitles filter takes the index among the subtitle streams, not the stream ID.
+	subtitleIndex := 0
+	for _, s := range container.Streams {
+		if s.CodecType != "subtitle" {
+			continue
+		}
+		if int64(s.Index) != options.SubtitleStreamID {
+			subtitleIndex++
+			continue
+		}
+
+		if imageSubtitleCodecs[s.CodecName] {
+			return fmt.Sprintf("%s[0:%d]overlay,%s[v]", video, s.Index, scale), nil
+		}
+
+		// The subtitles filter reads the file on its own and knows nothing about our seeking, so
+		// we shift the timestamps to where they were in the file while it renders.
+		start := strconv.FormatFloat(options.Start.Seconds(), 'f', 3, 64)
+		return fmt.Sprintf("%ssetpts=PTS+%s/TB,subtitles=filename=%s:si=%d,setpts=PTS-STARTPTS,%s[v]",
+			video, start, escapeFilterValue(fileURL), subtitleIndex, scale), nil
+	}
+
+	return "", fmt.Errorf("file has no subtitle stream with ID %d", options.SubtitleStreamID)
+}
+
+// NewClipTranscodingJob prepares a job that cuts the given time range out of a file and encodes
+// it to a small standalone MP4 file at outputPath. Seeking happens on the input so that ffmpeg
+// decodes from the preceding keyframe and the clip starts exactly at the requested time.
+func NewClipTranscodingJob(
+	fileLocator filesystem.FileLocator,
+	options ClipOptions,
+	outputPath string) (*FileTranscodingJob, error) {
+
+	if options.Start < 0 || options.End <= options.Start {
+		return nil, fmt.Errorf("invalid clip range %s-%s", options.Start, options.End)
+	}
+
+	streams, err := GetStreams(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+	if len(streams.VideoStreams) == 0 {
+		return nil, fmt.Errorf("file %s has no video stream", fileLocator)
+	}
+	videoStream := streams.GetVideoStream()
+	if options.End > videoStream.TotalDuration {
+		return nil, fmt.Errorf("clip ends after the end of the file at %s", videoStream.TotalDuration)
+	}
+
+	container, err := Probe(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	encoderParams, err := GetVideoEncoderPreset(videoStream, ClipVideoPreset)
+	if err != nil {
+		return nil, err
+	}
+	audioEncoderParams := AudioEncoderPresets["128k-audio"]
+
+	fileURL, err := buildFfmpegUrlFromFileLocator(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+	videoFilter, err := buildClipVideoFilter(
+		fileURL, container, videoStream.StreamId, encoderParams, options)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := options.End - options.Start
+	args := []string{
+		"-ss", strconv.FormatFloat(options.Start.Seconds(), 'f', 3, 64),
+		"-i", fileURL,
+		"-t", strconv.FormatFloat(duration.Seconds(), 'f', 3, 64),
+		"-filter_complex", videoFilter,
+		"-map", "[v]",
+		"-map", "0:a:0?",
+		"-c:v", "libx264", "-b:v", strconv.Itoa(encoderParams.videoBitrate),
+		"-preset:v", "veryfast",
+		"-c:a", "aac", "-ac", "2", "-b:a", strconv.Itoa(audioEncoderParams.audioBitrate),
+		"-sn",
+		"-movflags", "+faststart",
+		"-progress", "pipe:1",
+		"-nostats",
+		"-f", "mp4",
+		"-y", outputPath + ".part",
+	}
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	cmd.Stderr = getTranscodingLogSink("ffmpeg_clip")
+
+	return &FileTranscodingJob{
+		cmd:        cmd,
+		duration:   duration,
+		outputPath: outputPath,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEscapeFilterValue(t *testing.T) {
+	assert.Equal(t, `file\\:///movies/a\\\\b.mkv`, escapeFilterValue(`file:///movies/a\b.mkv`))
+	assert.Equal(t, `It\\\'s\, here\[1\]`, escapeFilterValue(`It's, here[1]`))
+}
+
+func TestBuildClipVideoFilter(t *testing.T) {
+	container := &ProbeContainer{Streams: []ProbeStream{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "aac"},
+		{Index: 2, CodecType: "subtitle", CodecName: "subrip"},
+		{Index: 3, CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+		{Index: 4, CodecType: "subtitle", CodecName: "ass"},
+	}}
+	params := EncoderParams{width: -2, height: 720}
+	options := ClipOptions{Start: 90 * time.Second, End: 120 * time.Second, SubtitleStreamID: -1}
+
+	filter, err := buildClipVideoFilter("file:///a.mkv", container, 0, params, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "[0:0]scale=-2:720[v]", filter)
+
+	options.SubtitleStreamID = 3
+	filter, err = buildClipVideoFilter("file:///a.mkv", container, 0, params, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "[0:0][0:3]overlay,scale=-2:720[v]", filter)
+
+	options.SubtitleStreamID = 4
+	filter, err = buildClipVideoFilter("file:///a.mkv", container, 0, params, options)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`[0:0]setpts=PTS+90.000/TB,subtitles=filename=file\\:///a.mkv:si=2,setpts=PTS-STARTPTS,scale=-2:720[v]`,
+		filter)
+
+	options.SubtitleStreamID = 1
+	_, err = buildClipVideoFilter("file:///a.mkv", container, 0, params, options)
+	assert.Error(t, err)
+}
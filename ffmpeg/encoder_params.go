@@ -1,9 +1,12 @@
 package ffmpeg
 
 import (
-	"bytes"
 	"encoding/base64"
-	"encoding/gob"
+	"errors"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"strconv"
+	"strings"
 )
 
 type EncoderParams struct {
@@ -18,28 +21,131 @@ type EncoderParams struct {
 	Codecs string
 }
 
+// Version of the EncoderParams serialization format. Bump this whenever the fields change so that
+// old representation IDs are rejected instead of being misinterpreted.
+const encoderParamsVersion = "1"
+
+// Length of the signature in transcode representation IDs in bytes.
+const representationIDSignatureLength = 16
+
+// Highest audio bitrate a client may ask for if the source stream has a lower bitrate.
+const maxAudioBitrate = 320000
+
+// ErrInvalidRepresentationID is returned for representation IDs that were tampered with or make
+// no sense for the stream.
+var ErrInvalidRepresentationID = errors.New("Invalid representation ID")
+
+// ErrStaleRepresentationID is returned for representation IDs in an older format. The client has
+// to fetch a new manifest.
+var ErrStaleRepresentationID = errors.New("Stale representation ID, please reload the manifest")
+
+// EncoderParamsToString serializes EncoderParams in a compact, versioned format. The result is not
+// signed, use TranscodeRepresentationID for anything that is handed out to clients.
 func EncoderParamsToString(m EncoderParams) string {
-	b := bytes.Buffer{}
-	e := gob.NewEncoder(&b)
-	err := e.Encode(m)
+	return strings.Join([]string{
+		encoderParamsVersion,
+		strconv.Itoa(m.width),
+		strconv.Itoa(m.height),
+		strconv.Itoa(m.videoBitrate),
+		strconv.Itoa(m.audioBitrate),
+		m.Codecs,
+	}, ",")
+}
+
+// EncoderParamsFromString parses EncoderParams serialized by EncoderParamsToString.
+func EncoderParamsFromString(str string) (EncoderParams, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) == 0 || parts[0] != encoderParamsVersion {
+		return EncoderParams{}, ErrStaleRepresentationID
+	}
+	if len(parts) != 6 {
+		return EncoderParams{}, fmt.Errorf("Expected 6 fields in EncoderParams, got %d", len(parts))
+	}
+
+	ints := make([]int, 4)
+	for i := range ints {
+		v, err := strconv.Atoi(parts[i+1])
+		if err != nil {
+			return EncoderParams{}, err
+		}
+		ints[i] = v
+	}
+	return EncoderParams{
+		width:        ints[0],
+		height:       ints[1],
+		videoBitrate: ints[2],
+		audioBitrate: ints[3],
+		Codecs:       parts[5],
+	}, nil
+}
+
+// TranscodeRepresentationID returns a "transcode:" representation ID for the given EncoderParams.
+// It is signed so that clients can't make up their own.
+func TranscodeRepresentationID(m EncoderParams) (string, error) {
+	payload := []byte(EncoderParamsToString(m))
+	signature, err := auth.Sign(payload)
 	if err != nil {
-		panic(`failed gob Encode`)
+		return "", fmt.Errorf("failed to sign representation ID: %s", err.Error())
 	}
-	return base64.URLEncoding.EncodeToString(b.Bytes())
+	return "transcode:" +
+		base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signature[:representationIDSignatureLength]), nil
 }
 
-func EncoderParamsFromString(str string) (EncoderParams, error) {
-	m := EncoderParams{}
-	by, err := base64.URLEncoding.DecodeString(str)
+// encoderParamsFromTranscodeRepresentationID verifies and parses a representation ID built by
+// TranscodeRepresentationID and checks that its EncoderParams are allowed for the given stream.
+func encoderParamsFromTranscodeRepresentationID(s Stream, representationID string) (EncoderParams, error) {
+	parts := strings.Split(strings.TrimPrefix(representationID, "transcode:"), ".")
+	if len(parts) != 2 {
+		// IDs from before they were signed were a single gob blob.
+		return EncoderParams{}, ErrStaleRepresentationID
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
 	if err != nil {
-		return EncoderParams{}, err
+		return EncoderParams{}, ErrInvalidRepresentationID
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !auth.VerifySignature(payload, signature) {
+		return EncoderParams{}, ErrInvalidRepresentationID
 	}
-	b := bytes.Buffer{}
-	b.Write(by)
-	d := gob.NewDecoder(&b)
-	err = d.Decode(&m)
+
+	encoderParams, err := EncoderParamsFromString(string(payload))
 	if err != nil {
 		return EncoderParams{}, err
 	}
-	return m, nil
+	if !encoderParamsAllowed(s, encoderParams) {
+		return EncoderParams{}, ErrInvalidRepresentationID
+	}
+	return encoderParams, nil
+}
+
+// encoderParamsAllowed returns whether the server would itself offer the given EncoderParams for
+// the stream: either the stream's own resolution or a preset resolution, at no more than the
+// bitrate of the stream or the highest preset.
+func encoderParamsAllowed(s Stream, m EncoderParams) bool {
+	switch s.StreamType {
+	case "video":
+		if m.width != -2 || m.videoBitrate < 0 || m.audioBitrate != 0 {
+			return false
+		}
+		maxBitrate := int(s.BitRate)
+		heightAllowed := m.height == s.Height
+		for _, preset := range videoEncoderPresets {
+			if preset.height == m.height {
+				heightAllowed = true
+			}
+			if preset.videoBitrate > maxBitrate {
+				maxBitrate = preset.videoBitrate
+			}
+		}
+		return heightAllowed && m.videoBitrate <= maxBitrate
+	case "audio":
+		maxBitrate := int(s.BitRate)
+		if maxBitrate < maxAudioBitrate {
+			maxBitrate = maxAudioBitrate
+		}
+		return m.width == 0 && m.height == 0 && m.videoBitrate == 0 &&
+			m.audioBitrate >= 0 && m.audioBitrate <= maxBitrate
+	}
+	return false
 }
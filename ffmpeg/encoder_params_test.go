@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEncoderParamsToString(t *testing.T) {
+	m := EncoderParams{width: -2, height: 720, videoBitrate: 5000000, Codecs: "avc1.64001f"}
+
+	s := EncoderParamsToString(m)
+	assert.Equal(t, "1,-2,720,5000000,0,avc1.64001f", s)
+
+	parsed, err := EncoderParamsFromString(s)
+	assert.Nil(t, err)
+	assert.Equal(t, m, parsed)
+
+	_, err = EncoderParamsFromString("0,-2,720,5000000,0,avc1.64001f")
+	assert.Equal(t, ErrStaleRepresentationID, err)
+}
+
+func TestTranscodeRepresentationID(t *testing.T) {
+	stream := Stream{
+		StreamType: "video",
+		Width:      1920,
+		Height:     1080,
+		BitRate:    8000000,
+		FrameRate:  big.NewRat(24, 1),
+	}
+
+	r, err := GetSimilarTranscodedRepresentation(stream)
+	assert.Nil(t, err)
+	fromID, err := StreamRepresentationFromRepresentationId(stream, r.Representation.RepresentationId)
+	assert.Nil(t, err)
+	assert.Equal(t, r.Representation.BitRate, fromID.Representation.BitRate)
+
+	// A client can't ask for a higher bitrate by making up its own ID.
+	payload := base64.RawURLEncoding.EncodeToString([]byte("1,-2,1080,100000000,0,avc1.640028"))
+	signature := strings.Split(r.Representation.RepresentationId, ".")[1]
+	_, err = StreamRepresentationFromRepresentationId(stream, "transcode:"+payload+"."+signature)
+	assert.Equal(t, ErrInvalidRepresentationID, err)
+
+	// Even a signed ID is rejected for a stream it wasn't made for.
+	_, err = StreamRepresentationFromRepresentationId(
+		Stream{StreamType: "audio", BitRate: 128000}, r.Representation.RepresentationId)
+	assert.Equal(t, ErrInvalidRepresentationID, err)
+
+	// IDs from before they were signed
+	_, err = StreamRepresentationFromRepresentationId(stream, "transcode:Zm9vYmFy")
+	assert.Equal(t, ErrStaleRepresentationID, err)
+}
@@ -4,6 +4,7 @@ package ffmpeg
 
 import (
 	"fmt"
+	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/helpers"
 	"os"
 	"path"
@@ -67,24 +68,26 @@ func GetTransmuxedOrTranscodedRepresentation(
 	if len(capabilities.PlayableCodecs) == 0 || capabilities.CanPlay(transmuxed) {
 		return transmuxed, nil
 	}
-	return GetSimilarTranscodedRepresentation(stream), nil
+	return GetSimilarTranscodedRepresentation(stream)
 }
 
-func GetSimilarTranscodedRepresentation(stream Stream) StreamRepresentation {
+func GetSimilarTranscodedRepresentation(stream Stream) (StreamRepresentation, error) {
 	similarEncoderParams, _ := GetSimilarEncoderParams(stream)
+	representationID, err := TranscodeRepresentationID(similarEncoderParams)
+	if err != nil {
+		return StreamRepresentation{}, err
+	}
 	if stream.StreamType == "audio" {
 		return GetTranscodedAudioRepresentation(
 			stream,
-			// TODO(Leon Handreke): Make a util method for this prefix.
-			"transcode:"+EncoderParamsToString(similarEncoderParams),
-			similarEncoderParams)
+			representationID,
+			similarEncoderParams), nil
 	}
 	if stream.StreamType == "video" {
 		return GetTranscodedVideoRepresentation(
 			stream,
-			// TODO(Leon Handreke): Make a util method for this prefix.
-			"transcode:"+EncoderParamsToString(similarEncoderParams),
-			similarEncoderParams)
+			representationID,
+			similarEncoderParams), nil
 
 	}
 
@@ -113,8 +116,7 @@ func StreamRepresentationFromRepresentationId(
 			return GetTranscodedAudioRepresentation(s, representationId, encoderParams), nil
 		}
 	} else if strings.HasPrefix(representationId, "transcode:") {
-		encoderParamsStr := representationId[10:]
-		encoderParams, err := EncoderParamsFromString(encoderParamsStr)
+		encoderParams, err := encoderParamsFromTranscodeRepresentationID(s, representationId)
 		if err != nil {
 			return StreamRepresentation{}, err
 		}
@@ -135,7 +137,7 @@ func NewTranscodingSession(s StreamRepresentation, segmentStartIndex int, feedba
 	helpers.EnsurePath(runtimeDir)
 
 	startTime := time.Duration(int64(segmentStartIndex) * int64(SegmentDuration))
-	if s.Representation.RepresentationId == "direct" {
+	if s.Representation.Transmuxed {
 		session, err := NewTransmuxingSession(s, startTime, segmentStartIndex, runtimeDir, feedbackURL)
 		if err != nil {
 			return nil, err
@@ -149,6 +151,17 @@ func NewTranscodingSession(s StreamRepresentation, segmentStartIndex int, feedba
 		var session *TranscodingSession
 		var err error
 
+		// Leave the heavy lifting to a transcoding worker if we have one.
+		if s.Stream.StreamType == "video" || s.Stream.StreamType == "audio" {
+			session, err = newRemoteTranscodingSession(s, startTime, segmentStartIndex, runtimeDir, feedbackURL)
+			if err == nil {
+				return session, nil
+			}
+			if *transcodingWorkerSecret != "" {
+				log.WithFields(log.Fields{"error": err}).Debugln("Transcoding locally")
+			}
+		}
+
 		if s.Stream.StreamType == "video" {
 			session, err = NewVideoTranscodingSession(s, startTime, segmentStartIndex, runtimeDir, feedbackURL)
 		} else if s.Stream.StreamType == "audio" {
@@ -109,7 +109,10 @@ func Probe(fileLocator filesystem.FileLocator) (*ProbeContainer, error) {
 	if !inCache {
 		log.WithFields(log.Fields{"fileLocator": fileLocator}).
 			Debugln("File not in cache, probing it.")
-		ffmpegUrl := buildFfmpegUrlFromFileLocator(fileLocator)
+		ffmpegUrl, err := buildFfmpegUrlFromFileLocator(fileLocator)
+		if err != nil {
+			return nil, err
+		}
 		cmd := exec.Command(
 			executable.GetFFprobeExecutablePath(),
 			"-show_data",
@@ -0,0 +1,143 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg/executable"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os/exec"
+	"time"
+)
+
+// frameGrabCandidates is the number of frames that are extracted and compared when looking for a
+// representative frame of a file.
+const frameGrabCandidates = 8
+
+// blackFrameLumaThreshold is the mean luma (0-255) below which a frame is considered to be black,
+// e.g. a fade-out between scenes.
+const blackFrameLumaThreshold = 20
+
+// ExtractFrame grabs a single frame at the given timestamp and returns it encoded as JPEG. If
+// height is larger than 0, the frame is scaled to that height, preserving the aspect ratio.
+func ExtractFrame(fileLocator filesystem.FileLocator, at time.Duration, height int) ([]byte, error) {
+	inputURL, err := buildFfmpegUrlFromFileLocator(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		// -ss being before -i is important for fast seeking
+		"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+		"-i", inputURL,
+		"-frames:v", "1",
+		"-an", "-sn",
+	}
+	if height > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:%d", height))
+	}
+	args = append(args,
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"-q:v", "3",
+		"pipe:1")
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	log.Debugln("ffmpeg started with", cmd.Args)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrap(err, "ffmpeg failed to extract frame")
+	}
+	if out.Len() == 0 {
+		return nil, fmt.Errorf("no frame found at %s in %s", at, fileLocator)
+	}
+
+	return out.Bytes(), nil
+}
+
+// ExtractRepresentativeFrame extracts a number of frames spread across the given duration of the
+// file and returns the one that is most likely to be interesting to look at, i.e. one that is not
+// black and has a lot of detail.
+func ExtractRepresentativeFrame(
+	fileLocator filesystem.FileLocator,
+	duration time.Duration,
+	height int) ([]byte, error) {
+
+	var bestFrame []byte
+	bestScore := math.Inf(-1)
+
+	for i := 0; i < frameGrabCandidates; i++ {
+		// Stay clear of the first and last 10% of the file, that's where intros, logos and
+		// credits live.
+		at := duration/10 + time.Duration(i)*(duration*8/10)/frameGrabCandidates
+
+		frame, err := ExtractFrame(fileLocator, at, height)
+		if err != nil {
+			log.WithFields(log.Fields{"fileLocator": fileLocator, "at": at, "error": err}).
+				Debugln("Failed to extract frame candidate")
+			continue
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(frame))
+		if err != nil {
+			continue
+		}
+
+		meanLuma, entropy := frameLumaStats(img)
+		score := entropy
+		// Entropy is at most 8 bits, so this always ranks black frames below all others while
+		// still returning something if the whole file is dark.
+		if meanLuma < blackFrameLumaThreshold {
+			score -= 8
+		}
+		if score > bestScore {
+			bestScore = score
+			bestFrame = frame
+		}
+	}
+
+	if bestFrame == nil {
+		return nil, fmt.Errorf("could not extract any frames from %s", fileLocator)
+	}
+	return bestFrame, nil
+}
+
+// frameLumaStats computes the mean luma (0-255) and the Shannon entropy (in bits) of the luma
+// histogram of the given image.
+func frameLumaStats(img image.Image) (float64, float64) {
+	var histogram [256]int
+	bounds := img.Bounds()
+	total := 0
+	sum := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			luma := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			histogram[luma]++
+			sum += int(luma)
+			total++
+		}
+	}
+
+	if total == 0 {
+		return 0, 0
+	}
+
+	entropy := 0.0
+	for _, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+
+	return float64(sum) / float64(total), entropy
+}
@@ -0,0 +1,26 @@
+package ffmpeg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFrameLumaStats(t *testing.T) {
+	black := image.NewGray(image.Rect(0, 0, 16, 16))
+	meanLuma, entropy := frameLumaStats(black)
+	assert.EqualValues(t, 0, meanLuma)
+	assert.EqualValues(t, 0, entropy)
+
+	// A gradient using all 256 luma values has the maximum entropy of 8 bits.
+	gradient := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			gradient.SetGray(x, y, color.Gray{Y: uint8(y*16 + x)})
+		}
+	}
+	meanLuma, entropy = frameLumaStats(gradient)
+	assert.InDelta(t, 127.5, meanLuma, 0.001)
+	assert.InDelta(t, 8, entropy, 0.001)
+}
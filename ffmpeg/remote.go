@@ -0,0 +1,303 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var transcodingWorkerSecret = flag.String(
+	"transcoding_worker_secret",
+	"",
+	"Shared secret between the server and its transcoding workers. Workers are disabled if empty.")
+
+// WorkerSecretHeader is the HTTP header carrying the shared secret in requests between the server
+// and its transcoding workers.
+const WorkerSecretHeader = "X-Olaris-Worker-Secret"
+
+// Workers register again every workerHeartbeatInterval. A worker that missed a few heartbeats is
+// not considered healthy anymore.
+const workerHeartbeatInterval = 30 * time.Second
+const workerTimeout = 3 * workerHeartbeatInterval
+
+// RemoteTranscodingJob describes a transcoding job sent to a worker. The paths are relative to the
+// server's base URL, which only the worker knows.
+type RemoteTranscodingJob struct {
+	ID string
+	// InputPath is the path of the file on the server's /files/ route.
+	InputPath string
+	// FeedbackPath is the path ffmpeg reports its progress to so that the server can throttle it.
+	FeedbackPath string
+
+	StreamID   int64
+	StreamType string
+	// EncoderParams as serialized by EncoderParamsToString.
+	EncoderParams string
+	// The job transcodes from SegmentStartIndex until the end of the stream, throttled via feedback.
+	SegmentStartIndex int
+}
+
+// TranscodingWorkerInfo is the information about a worker that is displayed on the debug page.
+type TranscodingWorkerInfo struct {
+	Name     string
+	URL      string
+	LastSeen time.Time
+	Jobs     int
+}
+
+// remoteJob links a TranscodingSession to the worker running its job.
+type remoteJob struct {
+	id     string
+	worker *TranscodingWorkerInfo
+}
+
+// Guards the maps below and the workers therein.
+var workersMutex = sync.Mutex{}
+
+// Registered transcoding workers by their URL.
+var transcodingWorkers = map[string]*TranscodingWorkerInfo{}
+
+// Sessions whose jobs run on a worker by job ID.
+var remoteSessions = map[string]*TranscodingSession{}
+
+// The client used to talk to workers. Jobs themselves run much longer than this, we only wait for
+// the worker to accept them.
+var workerClient = &http.Client{Timeout: 5 * time.Second}
+
+var remoteSegmentNameRegexp = regexp.MustCompile("^(init\\.mp4|stream0_\\d+\\.m4s)$")
+
+// CheckWorkerSecret returns whether the given secret matches the configured worker secret.
+func CheckWorkerSecret(secret string) bool {
+	if *transcodingWorkerSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(*transcodingWorkerSecret)) == 1
+}
+
+// RegisterTranscodingWorker registers a worker reachable under the given URL or refreshes its
+// registration.
+func RegisterTranscodingWorker(name string, workerURL string) {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+
+	w, exists := transcodingWorkers[workerURL]
+	if !exists {
+		log.WithFields(log.Fields{"name": name, "url": workerURL}).Infoln("Transcoding worker registered")
+		w = &TranscodingWorkerInfo{URL: workerURL}
+		transcodingWorkers[workerURL] = w
+	}
+	w.Name = name
+	w.LastSeen = time.Now()
+}
+
+// GetTranscodingWorkers returns all registered workers.
+func GetTranscodingWorkers() []TranscodingWorkerInfo {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+
+	workers := []TranscodingWorkerInfo{}
+	for _, w := range transcodingWorkers {
+		workers = append(workers, *w)
+	}
+	return workers
+}
+
+// pickTranscodingWorker returns the healthy worker with the fewest jobs, if any.
+// Must be called with workersMutex held.
+func pickTranscodingWorker() *TranscodingWorkerInfo {
+	var best *TranscodingWorkerInfo
+	for _, w := range transcodingWorkers {
+		if time.Since(w.LastSeen) > workerTimeout {
+			continue
+		}
+		if best == nil || w.Jobs < best.Jobs {
+			best = w
+		}
+	}
+	return best
+}
+
+// newRemoteTranscodingSession dispatches the transcoding of the given stream to a healthy worker.
+// The worker uploads the segments into the session's output directory.
+func newRemoteTranscodingSession(
+	stream StreamRepresentation,
+	startTime time.Duration,
+	segmentStartIndex int,
+	outputDirBase string,
+	feedbackURL string) (*TranscodingSession, error) {
+
+	if *transcodingWorkerSecret == "" {
+		return nil, fmt.Errorf("transcoding workers are disabled")
+	}
+
+	workersMutex.Lock()
+	worker := pickTranscodingWorker()
+	workersMutex.Unlock()
+	if worker == nil {
+		return nil, fmt.Errorf("no healthy transcoding worker")
+	}
+
+	parsedFeedbackURL, err := url.Parse(feedbackURL)
+	if err != nil {
+		return nil, err
+	}
+	token, err := auth.CreateStreamingJWT(0, stream.Stream.FileLocator.String())
+	if err != nil {
+		return nil, err
+	}
+
+	job := RemoteTranscodingJob{
+		ID:                uuid.New().String(),
+		InputPath:         fmt.Sprintf("/olaris/s/files/jwt/%s", url.PathEscape(token)),
+		FeedbackPath:      parsedFeedbackURL.RequestURI(),
+		StreamID:          stream.Stream.StreamId,
+		StreamType:        stream.Stream.StreamType,
+		EncoderParams:     EncoderParamsToString(stream.Representation.encoderParams),
+		SegmentStartIndex: segmentStartIndex,
+	}
+
+	outputDir, err := ioutil.TempDir(outputDirBase, "transcoding-session-")
+	if err != nil {
+		return nil, err
+	}
+	session := &TranscodingSession{
+		Stream:    stream,
+		OutputDir: outputDir,
+		remote:    &remoteJob{id: job.ID, worker: worker},
+	}
+
+	// Register the session first, the worker may start uploading before it even answered.
+	workersMutex.Lock()
+	remoteSessions[job.ID] = session
+	worker.Jobs++
+	workersMutex.Unlock()
+
+	if err := sendToWorker(worker.URL, "POST", "/jobs", job); err != nil {
+		workersMutex.Lock()
+		delete(remoteSessions, job.ID)
+		worker.Jobs--
+		// Don't send any more jobs there until it registers again.
+		worker.LastSeen = time.Time{}
+		workersMutex.Unlock()
+
+		os.RemoveAll(outputDir)
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"worker": worker.Name,
+		"job":    job.ID,
+		"file":   stream.Stream.FileLocator,
+		"start":  startTime,
+	}).Infoln("Dispatched transcoding job to worker")
+
+	return session, nil
+}
+
+// cancelRemoteJob stops the worker running the session's job from uploading any more segments.
+func (s *TranscodingSession) cancelRemoteJob() {
+	workersMutex.Lock()
+	_, running := remoteSessions[s.remote.id]
+	if running {
+		delete(remoteSessions, s.remote.id)
+		s.remote.worker.Jobs--
+	}
+	workersMutex.Unlock()
+
+	if running && !s.Terminated {
+		if err := sendToWorker(s.remote.worker.URL, "DELETE", "/jobs/"+s.remote.id, nil); err != nil {
+			log.WithFields(log.Fields{"job": s.remote.id, "error": err}).
+				Warnln("Failed to cancel job on transcoding worker")
+		}
+	}
+}
+
+func sendToWorker(workerURL string, method string, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, workerURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(WorkerSecretHeader, *transcodingWorkerSecret)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := workerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("worker responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// StoreRemoteSegment stores a segment uploaded by a worker in the output directory of the job's
+// session.
+func StoreRemoteSegment(jobID string, name string, r io.Reader) error {
+	if !remoteSegmentNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid segment name %s", name)
+	}
+
+	workersMutex.Lock()
+	s := remoteSessions[jobID]
+	workersMutex.Unlock()
+	if s == nil {
+		return fmt.Errorf("no transcoding job %s", jobID)
+	}
+
+	// Write to a temporary file first so that a partial segment is never served.
+	p := filepath.Join(s.OutputDir, name)
+	f, err := os.Create(p + ".part")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	f.Close()
+	if err != nil {
+		os.Remove(p + ".part")
+		return err
+	}
+	return os.Rename(p+".part", p)
+}
+
+// FinishRemoteJob marks the job's session as terminated once the worker uploaded all segments.
+func FinishRemoteJob(jobID string, jobErr string) error {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+
+	s := remoteSessions[jobID]
+	if s == nil {
+		return fmt.Errorf("no transcoding job %s", jobID)
+	}
+	if jobErr != "" {
+		log.WithFields(log.Fields{"job": jobID, "worker": s.remote.worker.Name, "error": jobErr}).
+			Warnln("Transcoding job failed on worker")
+	}
+	s.Terminated = true
+	delete(remoteSessions, jobID)
+	s.remote.worker.Jobs--
+	return nil
+}
@@ -0,0 +1,129 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupWorkerTest(t *testing.T) func() {
+	oldSecret := *transcodingWorkerSecret
+	*transcodingWorkerSecret = "test-secret"
+	transcodingWorkers = map[string]*TranscodingWorkerInfo{}
+	remoteSessions = map[string]*TranscodingSession{}
+
+	return func() {
+		*transcodingWorkerSecret = oldSecret
+		transcodingWorkers = map[string]*TranscodingWorkerInfo{}
+		remoteSessions = map[string]*TranscodingSession{}
+	}
+}
+
+func TestCheckWorkerSecret(t *testing.T) {
+	defer setupWorkerTest(t)()
+
+	assert.True(t, CheckWorkerSecret("test-secret"))
+	assert.False(t, CheckWorkerSecret("wrong"))
+
+	*transcodingWorkerSecret = ""
+	assert.False(t, CheckWorkerSecret(""), "workers must be disabled without a secret")
+}
+
+func TestPickTranscodingWorker(t *testing.T) {
+	defer setupWorkerTest(t)()
+
+	assert.Nil(t, pickTranscodingWorker())
+
+	RegisterTranscodingWorker("busy", "http://busy:8090")
+	RegisterTranscodingWorker("idle", "http://idle:8090")
+	RegisterTranscodingWorker("stale", "http://stale:8090")
+	transcodingWorkers["http://busy:8090"].Jobs = 2
+	transcodingWorkers["http://stale:8090"].LastSeen = time.Now().Add(-2 * workerTimeout)
+
+	assert.Equal(t, "idle", pickTranscodingWorker().Name)
+}
+
+func TestRemoteTranscodingSession(t *testing.T) {
+	defer setupWorkerTest(t)()
+
+	var received RemoteTranscodingJob
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-secret", r.Header.Get(WorkerSecretHeader))
+		assert.Equal(t, "/jobs", r.URL.Path)
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer worker.Close()
+	RegisterTranscodingWorker("worker", worker.URL)
+
+	outputDirBase, _ := ioutil.TempDir("", "test-remote-transcoding-session")
+	defer os.RemoveAll(outputDirBase)
+
+	fileLocator, _ := filesystem.ParseFileLocator("/movies/test.mkv")
+	stream := GetTranscodedVideoRepresentation(
+		Stream{StreamKey: StreamKey{FileLocator: fileLocator, StreamId: 0}, StreamType: "video"},
+		"preset:720-5000k-video",
+		videoEncoderPresets["720-5000k-video"])
+
+	s, err := newRemoteTranscodingSession(
+		stream, 10*SegmentDuration, 10, outputDirBase, "http://127.0.0.1:8080/olaris/s/ffmpeg/abc/feedback")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, transcodingWorkers[worker.URL].Jobs)
+
+	assert.Equal(t, "video", received.StreamType)
+	assert.Equal(t, 10, received.SegmentStartIndex)
+	assert.Equal(t, "/olaris/s/ffmpeg/abc/feedback", received.FeedbackPath)
+	assert.True(t, strings.HasPrefix(received.InputPath, "/olaris/s/files/jwt/"))
+
+	// Uploaded segments end up in the session's output directory.
+	assert.NotNil(t, StoreRemoteSegment(received.ID, "../escape.m4s", strings.NewReader("")))
+	assert.Nil(t, StoreRemoteSegment(received.ID, "init.mp4", strings.NewReader("init")))
+	assert.Nil(t, StoreRemoteSegment(received.ID, "stream0_10.m4s", strings.NewReader("segment")))
+
+	segments, err := s.AvailableSegments()
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{
+		InitialSegmentIdx: filepath.Join(s.OutputDir, "init.mp4"),
+		10:                filepath.Join(s.OutputDir, "stream0_10.m4s"),
+	}, segments)
+
+	assert.Nil(t, FinishRemoteJob(received.ID, ""))
+	assert.True(t, s.Terminated)
+	assert.Equal(t, 0, transcodingWorkers[worker.URL].Jobs)
+	assert.NotNil(t, StoreRemoteSegment(received.ID, "stream0_11.m4s", strings.NewReader("")))
+
+	s.Destroy()
+}
+
+func TestRemoteTranscodingSessionWorkerDown(t *testing.T) {
+	defer setupWorkerTest(t)()
+
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusInternalServerError)
+	}))
+	defer worker.Close()
+	RegisterTranscodingWorker("worker", worker.URL)
+
+	outputDirBase, _ := ioutil.TempDir("", "test-remote-transcoding-session")
+	defer os.RemoveAll(outputDirBase)
+
+	fileLocator, _ := filesystem.ParseFileLocator("/movies/test.mkv")
+	stream := GetTranscodedAudioRepresentation(
+		Stream{StreamKey: StreamKey{FileLocator: fileLocator, StreamId: 1}, StreamType: "audio"},
+		"preset:128k-audio",
+		AudioEncoderPresets["128k-audio"])
+
+	_, err := newRemoteTranscodingSession(
+		stream, 0, 0, outputDirBase, "http://127.0.0.1:8080/olaris/s/ffmpeg/abc/feedback")
+	assert.NotNil(t, err)
+	// The worker isn't picked again until it registers again.
+	assert.Nil(t, pickTranscodingWorker())
+	assert.Empty(t, remoteSessions)
+}
@@ -21,9 +21,16 @@ type TranscodingSession struct {
 	Terminated      bool
 	Throttled       bool
 	ProgressPercent float32
+
+	// Set if the session's job runs on a transcoding worker instead of a local ffmpeg process.
+	remote *remoteJob
 }
 
 func (s *TranscodingSession) Start() error {
+	if s.remote != nil {
+		// The worker started the job as soon as it was dispatched.
+		return nil
+	}
 	if err := s.cmd.Start(); err != nil {
 		return err
 	}
@@ -36,6 +43,11 @@ func (s *TranscodingSession) Start() error {
 }
 
 func (s *TranscodingSession) Destroy() error {
+	if s.remote != nil {
+		s.cancelRemoteJob()
+		return os.RemoveAll(s.OutputDir)
+	}
+
 	// Signal the process group (-pid), not just the process, so that the process
 	// and all its children are signaled. Else, child procs can keep running and
 	// keep the stdout/stderr fd open and cause cmd.Wait to hang.
@@ -83,7 +95,8 @@ func (s *TranscodingSession) AvailableSegments() (map[int]string, error) {
 	}
 
 	// We delete the "newest" segment because it may still be written to to avoid races.
-	if len(res) > 0 && !s.Terminated {
+	// Workers only upload finished segments, so this isn't necessary for remote sessions.
+	if len(res) > 0 && !s.Terminated && s.remote == nil {
 		delete(res, maxSegmentId)
 	}
 
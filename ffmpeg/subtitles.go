@@ -17,9 +17,14 @@ func NewSubtitleSession(
 		return nil, err
 	}
 
+	inputURL, err := buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command("ffmpeg",
 		// -ss being before -i is important for fast seeking
-		"-i", buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator),
+		"-i", inputURL,
 		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
 		"-threads", "2",
 		"-f", "webvtt",
@@ -29,7 +29,46 @@ func NewAudioTranscodingSession(
 		return nil, err
 	}
 
-	encoderParams := stream.Representation.encoderParams
+	inputURL, err := buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	args := audioTranscodingArgs(
+		stream.Representation.encoderParams,
+		inputURL,
+		stream.Stream.StreamId,
+		startTime,
+		segmentStartIndex,
+		feedbackURL,
+		outputDir)
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	log.Println("ffmpeg started with", cmd.Args)
+
+	logSink := getTranscodingLogSink("ffmpeg_transcode_audio")
+	cmd.Stderr = logSink
+
+	cmd.Stdout = os.Stdout
+	cmd.Dir = outputDir
+
+	return &TranscodingSession{
+		cmd:       cmd,
+		Stream:    stream,
+		OutputDir: outputDir,
+	}, nil
+}
+
+// audioTranscodingArgs builds the ffmpeg arguments to transcode the given audio stream of inputURL
+// into segments in outputDir.
+func audioTranscodingArgs(
+	encoderParams EncoderParams,
+	inputURL string,
+	streamID int64,
+	startTime time.Duration,
+	segmentStartIndex int,
+	feedbackURL string,
+	outputDir string) []string {
 
 	args := []string{}
 	if startTime != 0 {
@@ -39,10 +78,10 @@ func NewAudioTranscodingSession(
 		}...)
 	}
 
-	args = append(args, []string{
-		"-i", buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator),
+	return append(args, []string{
+		"-i", inputURL,
 		"-copyts",
-		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
+		"-map", fmt.Sprintf("0:%d", streamID),
 		"-c:0", "aac", "-ac", "2", "-ab", strconv.Itoa(encoderParams.audioBitrate),
 		"-f", "hls",
 		"-start_number", fmt.Sprintf("%d", segmentStartIndex),
@@ -53,21 +92,6 @@ func NewAudioTranscodingSession(
 		// We serve our own manifest, so we don't really care about this.
 		path.Join(outputDir, "generated_by_ffmpeg.m3u"),
 	}...)
-
-	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
-	log.Println("ffmpeg started with", cmd.Args)
-
-	logSink := getTranscodingLogSink("ffmpeg_transcode_audio")
-	cmd.Stderr = logSink
-
-	cmd.Stdout = os.Stdout
-	cmd.Dir = outputDir
-
-	return &TranscodingSession{
-		cmd:       cmd,
-		Stream:    stream,
-		OutputDir: outputDir,
-	}, nil
 }
 
 func GetTranscodedAudioRepresentation(stream Stream, representationId string, encoderParams EncoderParams) StreamRepresentation {
@@ -0,0 +1,175 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg/executable"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscodeToFileOptions describes the file produced by a FileTranscodingJob.
+type TranscodeToFileOptions struct {
+	// Name of the video encoder preset, e.g. "720-5000k-video"
+	Preset string
+	// AudioStreamIds are the ffmpeg stream IDs of the audio streams to include. If empty, all
+	// audio streams are included.
+	AudioStreamIds []int64
+	// SubtitleStreamIds are the ffmpeg stream IDs of embedded text subtitle streams to include.
+	SubtitleStreamIds []int64
+}
+
+// FileTranscodingJob transcodes a whole file to a standalone MP4 file using one of the video
+// encoder presets. Keyframes are forced at segment boundaries so that the result can later be
+// transmuxed into segments that line up with the ones we would produce when transcoding on the fly.
+type FileTranscodingJob struct {
+	cmd        *exec.Cmd
+	duration   time.Duration
+	outputPath string
+
+	mutex     sync.Mutex
+	cancelled bool
+}
+
+// NewFileTranscodingJob prepares a job that transcodes the given file as described by the
+// options and writes the result to outputPath.
+func NewFileTranscodingJob(
+	fileLocator filesystem.FileLocator,
+	options TranscodeToFileOptions,
+	outputPath string) (*FileTranscodingJob, error) {
+
+	streams, err := GetStreams(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+	if len(streams.VideoStreams) == 0 {
+		return nil, fmt.Errorf("file %s has no video stream", fileLocator)
+	}
+	videoStream := streams.GetVideoStream()
+
+	encoderParams, err := GetVideoEncoderPreset(videoStream, options.Preset)
+	if err != nil {
+		return nil, err
+	}
+
+	audioEncoderParams := AudioEncoderPresets["128k-audio"]
+
+	inputURL, err := buildFfmpegUrlFromFileLocator(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-i", inputURL,
+		"-map", fmt.Sprintf("0:%d", videoStream.StreamId),
+	}
+	if len(options.AudioStreamIds) == 0 {
+		args = append(args, "-map", "0:a?")
+	}
+	for _, id := range options.AudioStreamIds {
+		args = append(args, "-map", fmt.Sprintf("0:%d", id))
+	}
+	for _, id := range options.SubtitleStreamIds {
+		args = append(args, "-map", fmt.Sprintf("0:%d", id))
+	}
+
+	args = append(args, []string{
+		"-c:v", "libx264", "-b:v", strconv.Itoa(encoderParams.videoBitrate),
+		"-preset:v", "veryfast",
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%.3f)", SegmentDuration.Seconds()),
+		"-filter:v", fmt.Sprintf("scale=%d:%d", encoderParams.width, encoderParams.height),
+		"-c:a", "aac", "-ac", "2", "-b:a", strconv.Itoa(audioEncoderParams.audioBitrate),
+		// MP4 only supports text subtitles as mov_text, image-based ones will make ffmpeg fail.
+		"-c:s", "mov_text",
+		"-movflags", "+faststart",
+		"-progress", "pipe:1",
+		"-nostats",
+		"-f", "mp4",
+		"-y", outputPath + ".part",
+	}...)
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	cmd.Stderr = getTranscodingLogSink("ffmpeg_transcode_file")
+
+	return &FileTranscodingJob{
+		cmd:        cmd,
+		duration:   videoStream.TotalDuration,
+		outputPath: outputPath,
+	}, nil
+}
+
+// Run runs the job to completion, calling progress with the progress in percent whenever ffmpeg
+// reports some. The output file is only moved into place once ffmpeg finished successfully.
+func (j *FileTranscodingJob) Run(progress func(percent float64)) error {
+	stdout, err := j.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	if j.cancelled {
+		j.mutex.Unlock()
+		return errors.New("job was cancelled")
+	}
+	log.Debugln("ffmpeg started with", j.cmd.Args)
+	err = j.cmd.Start()
+	j.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		outTime, ok := parseProgressOutTime(scanner.Text())
+		if ok && j.duration > 0 {
+			progress(100 * outTime.Seconds() / j.duration.Seconds())
+		}
+	}
+
+	if err := j.cmd.Wait(); err != nil {
+		os.Remove(j.outputPath + ".part")
+		if j.isCancelled() {
+			return errors.New("job was cancelled")
+		}
+		return errors.Wrap(err, "ffmpeg failed to transcode file")
+	}
+
+	return os.Rename(j.outputPath+".part", j.outputPath)
+}
+
+// Cancel stops the job. Run will return an error and no output file will be left behind.
+func (j *FileTranscodingJob) Cancel() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.cancelled = true
+	if j.cmd.Process != nil {
+		j.cmd.Process.Kill()
+	}
+}
+
+func (j *FileTranscodingJob) isCancelled() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.cancelled
+}
+
+// parseProgressOutTime parses the output position from a line written by ffmpeg's -progress
+// option. Despite its name, out_time_ms is in microseconds.
+func parseProgressOutTime(line string) (time.Duration, bool) {
+	if !strings.HasPrefix(line, "out_time_ms=") {
+		return 0, false
+	}
+	us, err := strconv.ParseInt(strings.TrimPrefix(line, "out_time_ms="), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(us) * time.Microsecond, true
+}
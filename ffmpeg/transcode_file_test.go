@@ -0,0 +1,19 @@
+package ffmpeg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseProgressOutTime(t *testing.T) {
+	outTime, ok := parseProgressOutTime("out_time_ms=12500000")
+	assert.True(t, ok)
+	assert.Equal(t, 12500*time.Millisecond, outTime)
+
+	_, ok = parseProgressOutTime("out_time=00:00:12.500000")
+	assert.False(t, ok)
+
+	_, ok = parseProgressOutTime("out_time_ms=N/A")
+	assert.False(t, ok)
+}
@@ -9,21 +9,31 @@ import (
 	"os/exec"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 )
 
+var videoEncoderPresets = map[string]EncoderParams{
+	"480-1000k-video": {
+		height: 480, width: -2,
+		videoBitrate: 1000000},
+	"720-5000k-video": {
+		height: 720, width: -2,
+		videoBitrate: 5000000},
+	"1080-10000k-video": {
+		height: 1080, width: -2,
+		videoBitrate: 10000000},
+}
+
+// IsVideoEncoderPreset returns whether a video encoder preset with the given name, e.g.
+// "720-5000k-video", exists.
+func IsVideoEncoderPreset(name string) bool {
+	_, exists := videoEncoderPresets[name]
+	return exists
+}
+
 func GetVideoEncoderPreset(stream Stream, name string) (EncoderParams, error) {
-	encoderParams, exists := map[string]EncoderParams{
-		"480-1000k-video": {
-			height: 480, width: -2,
-			videoBitrate: 1000000},
-		"720-5000k-video": {
-			height: 720, width: -2,
-			videoBitrate: 5000000},
-		"1080-10000k-video": {
-			height: 1080, width: -2,
-			videoBitrate: 10000000},
-	}[name]
+	encoderParams, exists := videoEncoderPresets[name]
 
 	if !exists {
 		return EncoderParams{}, fmt.Errorf("no preset \"%s\"", name)
@@ -40,20 +50,51 @@ func GetVideoEncoderPreset(stream Stream, name string) (EncoderParams, error) {
 }
 
 // List of standard presets that are offered by default
-var standardPresets = []string{
+var StandardPresets = []string{
 	"preset:480-1000k-video",
 	"preset:720-5000k-video",
 	"preset:1080-10000k-video"}
 
 func GetStandardPresetVideoRepresentations(stream Stream) []StreamRepresentation {
 	representations := []StreamRepresentation{}
-	for _, preset := range standardPresets {
+	for _, preset := range StandardPresets {
 		r, _ := StreamRepresentationFromRepresentationId(stream, preset)
 		representations = append(representations, r)
 	}
 	return representations
 }
 
+// GetCappedTranscodedVideoRepresentation returns the best standard preset representation that does
+// not exceed maxBitrate. If even the lowest preset exceeds it, the lowest preset's resolution is
+// transcoded at maxBitrate.
+func GetCappedTranscodedVideoRepresentation(stream Stream, maxBitrate int) (StreamRepresentation, error) {
+	var best *StreamRepresentation
+	for _, preset := range StandardPresets {
+		r, err := StreamRepresentationFromRepresentationId(stream, preset)
+		if err != nil || r.Representation.BitRate > maxBitrate {
+			continue
+		}
+		if best == nil || r.Representation.BitRate > best.Representation.BitRate {
+			best = &r
+		}
+	}
+	if best != nil {
+		return *best, nil
+	}
+
+	encoderParams, _ := GetVideoEncoderPreset(stream, strings.TrimPrefix(StandardPresets[0], "preset:"))
+	encoderParams.videoBitrate = maxBitrate
+	scaledWidth, scaledHeight := scalePreserveAspectRatio(
+		stream.Width, stream.Height,
+		encoderParams.width, encoderParams.height)
+	encoderParams.Codecs = GetAVC1Tag(scaledWidth, scaledHeight, int64(maxBitrate), stream.FrameRate)
+	representationID, err := TranscodeRepresentationID(encoderParams)
+	if err != nil {
+		return StreamRepresentation{}, err
+	}
+	return GetTranscodedVideoRepresentation(stream, representationID, encoderParams), nil
+}
+
 func NewVideoTranscodingSession(
 	stream StreamRepresentation,
 	startTime time.Duration,
@@ -66,7 +107,51 @@ func NewVideoTranscodingSession(
 		return nil, err
 	}
 
-	encoderParams := stream.Representation.encoderParams
+	inputURL, err := buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	args := videoTranscodingArgs(
+		stream.Representation.encoderParams,
+		inputURL,
+		stream.Stream.StreamId,
+		startTime,
+		segmentStartIndex,
+		feedbackURL,
+		outputDir)
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	log.Println("ffmpeg started with", cmd.Path, cmd.Args)
+
+	logSink := getTranscodingLogSink("ffmpeg_transcode_video")
+	//io.WriteString(logSink, fmt.Sprintf("%s %s\n\n", cmd.Args, options.String()))
+	cmd.Stderr = logSink
+
+	cmd.Stdout = os.Stdout
+	cmd.Dir = outputDir
+
+	//stdin, _ := cmd.StdinPipe()
+	//stdin.Write(optionsSerialized)
+	//stdin.Close()
+
+	return &TranscodingSession{
+		cmd:       cmd,
+		Stream:    stream,
+		OutputDir: outputDir,
+	}, nil
+}
+
+// videoTranscodingArgs builds the ffmpeg arguments to transcode the given video stream of inputURL
+// into segments in outputDir.
+func videoTranscodingArgs(
+	encoderParams EncoderParams,
+	inputURL string,
+	streamID int64,
+	startTime time.Duration,
+	segmentStartIndex int,
+	feedbackURL string,
+	outputDir string) []string {
 
 	args := []string{}
 	if startTime != 0 {
@@ -77,9 +162,9 @@ func NewVideoTranscodingSession(
 	}
 
 	args = append(args, []string{
-		"-i", buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator),
+		"-i", inputURL,
 		"-copyts",
-		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
+		"-map", fmt.Sprintf("0:%d", streamID),
 		"-c:0", "libx264", "-b:v", strconv.Itoa(encoderParams.videoBitrate),
 		"-preset:0", "veryfast",
 		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%.3f)", SegmentDuration.Seconds()),
@@ -97,27 +182,7 @@ func NewVideoTranscodingSession(
 		}...)
 	}
 	// We serve our own manifest, so we don't really care about this.
-	args = append(args, path.Join(outputDir, "generated_by_ffmpeg.m3u"))
-
-	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
-	log.Println("ffmpeg started with", cmd.Path, cmd.Args)
-
-	logSink := getTranscodingLogSink("ffmpeg_transcode_video")
-	//io.WriteString(logSink, fmt.Sprintf("%s %s\n\n", cmd.Args, options.String()))
-	cmd.Stderr = logSink
-
-	cmd.Stdout = os.Stdout
-	cmd.Dir = outputDir
-
-	//stdin, _ := cmd.StdinPipe()
-	//stdin.Write(optionsSerialized)
-	//stdin.Close()
-
-	return &TranscodingSession{
-		cmd:       cmd,
-		Stream:    stream,
-		OutputDir: outputDir,
-	}, nil
+	return append(args, path.Join(outputDir, "generated_by_ffmpeg.m3u"))
 }
 
 func GetTranscodedVideoRepresentation(
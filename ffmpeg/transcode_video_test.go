@@ -0,0 +1,32 @@
+package ffmpeg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestGetCappedTranscodedVideoRepresentation(t *testing.T) {
+	stream := Stream{
+		StreamType: "video",
+		Width:      1920,
+		Height:     1080,
+		BitRate:    60000000,
+		FrameRate:  big.NewRat(24, 1),
+	}
+
+	r, err := GetCappedTranscodedVideoRepresentation(stream, 6000000)
+	assert.Nil(t, err)
+	assert.Equal(t, "preset:720-5000k-video", r.Representation.RepresentationId)
+
+	r, err = GetCappedTranscodedVideoRepresentation(stream, 500000)
+	assert.Nil(t, err)
+	assert.True(t, r.Representation.Transcoded)
+	assert.Equal(t, 500000, r.Representation.BitRate)
+	assert.Equal(t, 480, r.Representation.Height)
+
+	// The representation can be reconstructed from its ID when segments are requested.
+	fromID, err := StreamRepresentationFromRepresentationId(stream, r.Representation.RepresentationId)
+	assert.Nil(t, err)
+	assert.Equal(t, 500000, fromID.Representation.BitRate)
+}
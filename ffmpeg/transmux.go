@@ -24,6 +24,11 @@ func NewTransmuxingSession(
 		return nil, err
 	}
 
+	inputURL, err := buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator)
+	if err != nil {
+		return nil, err
+	}
+
 	args := []string{}
 	if startTime != 0 {
 		args = append(args, []string{
@@ -33,7 +38,7 @@ func NewTransmuxingSession(
 	}
 
 	args = append(args, []string{
-		"-i", buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator),
+		"-i", inputURL,
 		"-copyts",
 		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
 		"-c:0", "copy",
@@ -1,28 +1,149 @@
 package ffmpeg
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/filesystem"
-	"gitlab.com/olaris/olaris-server/metadata/auth"
-	"net/url"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
-// TODO(Leon Handreke): Figure out a better way than a package-global variable to
-// convey this info from the top-level command flag to ffmpeg. Or maybe a setter is enough?
-// Also this should go in some util package to build URLs
-var FfmpegUrlPort = 8080
+// The internal server is how ffmpeg processes talk back to us: they read files they can't open by
+// themselves, e.g. on rclone remotes, and report their progress through it. It listens on a random
+// loopback port so that it doesn't depend on how the public server is bound, and only accepts file
+// tokens signed with a key that never leaves this process.
+var internalServer = struct {
+	once    sync.Once
+	router  *mux.Router
+	key     []byte
+	baseURL string
+	err     error
+}{router: mux.NewRouter()}
+
+func init() {
+	internalServer.router.HandleFunc("/files/{token}", serveInternalFile)
+}
+
+func startInternalServer() error {
+	internalServer.once.Do(func() {
+		internalServer.key = make([]byte, 32)
+		if _, err := rand.Read(internalServer.key); err != nil {
+			internalServer.err = err
+			return
+		}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			internalServer.err = err
+			return
+		}
+		internalServer.baseURL = "http://" + listener.Addr().String()
+		log.WithFields(log.Fields{"url": internalServer.baseURL}).Debugln("Internal ffmpeg server listening")
+
+		go func() {
+			if err := http.Serve(listener, internalServer.router); err != nil {
+				log.WithFields(log.Fields{"error": err}).Errorln("Internal ffmpeg server stopped")
+			}
+		}()
+	})
+	return internalServer.err
+}
+
+// HandleInternal registers a handler for the given gorilla/mux path template on the internal
+// server. Handlers have to be registered before any ffmpeg process is started.
+func HandleInternal(pathTemplate string, f http.HandlerFunc) {
+	internalServer.router.HandleFunc(pathTemplate, f)
+}
+
+// InternalURL returns the URL under which ffmpeg processes can reach the given path on the
+// internal server, starting the server if required.
+func InternalURL(path string) (string, error) {
+	if err := startInternalServer(); err != nil {
+		return "", err
+	}
+	return internalServer.baseURL + path, nil
+}
+
+func signInternalFileToken(payload string) string {
+	mac := hmac.New(sha256.New, internalServer.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// internalFileToken returns a token that allows reading the given file through the internal
+// server.
+func internalFileToken(fileLocator filesystem.FileLocator) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fileLocator.String()))
+	return payload + "." + signInternalFileToken(payload)
+}
+
+// fileLocatorFromInternalFileToken checks the signature of a token created by internalFileToken
+// and returns the file it is for.
+func fileLocatorFromInternalFileToken(token string) (filesystem.FileLocator, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(signInternalFileToken(parts[0])), []byte(parts[1])) {
+		return filesystem.FileLocator{}, fmt.Errorf("invalid file token")
+	}
+	locatorStr, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return filesystem.FileLocator{}, fmt.Errorf("invalid file token")
+	}
+	return filesystem.ParseFileLocator(string(locatorStr))
+}
+
+// serveInternalFile serves the file a token was issued for, reading it through its
+// filesystem.Node so that it works for all backends.
+func serveInternalFile(w http.ResponseWriter, r *http.Request) {
+	fileLocator, err := fileLocatorFromInternalFileToken(mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	node, err := filesystem.GetNodeFromFileLocator(fileLocator)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	f, err := node.Open()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	http.ServeContent(w, r, node.Name(), time.Time{}, f)
+}
+
+// buildFfmpegUrlFromFileLocator returns the URL ffmpeg should read the given file from. Local
+// files and files on HTTP(S) servers are read directly, everything else through the internal
+// server.
+func buildFfmpegUrlFromFileLocator(fileLocator filesystem.FileLocator) (string, error) {
+	// ffmpeg reads the video a .strm file points to directly from its server.
+	fileLocator, err := filesystem.ResolveFileLocator(fileLocator)
+	if err != nil {
+		return "", err
+	}
 
-// NOTE(Leon Handreke): This doesn't really belong here. It doesn't really belong anywhere since it
-// really breaks the layering. I'm not really sure where to put this. Probably some URL building
-// package.
-func buildFfmpegUrlFromFileLocator(fileLocator filesystem.FileLocator) string {
 	switch fileLocator.Backend {
-	case filesystem.BackendRclone:
-		jwt, _ := auth.CreateStreamingJWT(0, fileLocator.String())
-		return fmt.Sprintf("http://127.0.0.1:%d/olaris/s/files/jwt/%s",
-			FfmpegUrlPort, url.PathEscape(jwt))
 	case filesystem.BackendLocal:
-		return "file://" + fileLocator.Path
+		return "file://" + fileLocator.Path, nil
+	case filesystem.BackendRclone:
+		// The server has to be running before we can sign a token with its key.
+		if err := startInternalServer(); err != nil {
+			return "", err
+		}
+		return InternalURL("/files/" + internalFileToken(fileLocator))
+	case filesystem.BackendHTTP:
+		return fileLocator.Path, nil
 	}
-	panic(fmt.Sprintf("Unknown fileLocator backend in %s", fileLocator))
+	return "", fmt.Errorf("unknown backend in file locator %s", fileLocator)
 }
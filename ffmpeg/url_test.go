@@ -0,0 +1,57 @@
+package ffmpeg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestInternalFileToken(t *testing.T) {
+	assert.NoError(t, startInternalServer())
+
+	fileLocator := filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: "/drive/Movies/a.mkv"}
+	token := internalFileToken(fileLocator)
+
+	parsed, err := fileLocatorFromInternalFileToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, fileLocator, parsed)
+
+	forged := internalFileToken(filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: "/etc/passwd"})
+	_, err = fileLocatorFromInternalFileToken(forged[:len(forged)-2] + token[len(token)-2:])
+	assert.Error(t, err)
+	_, err = fileLocatorFromInternalFileToken("bm9wZQ")
+	assert.Error(t, err)
+}
+
+func TestServeInternalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "olaris-internal-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := path.Join(dir, "a.mkv")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte("0123456789"), 0644))
+
+	assert.NoError(t, startInternalServer())
+	fileURL, err := InternalURL("/files/" + internalFileToken(
+		filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: filePath}))
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", fileURL, nil)
+	req.Header.Set("Range", "bytes=2-5")
+	res, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+		assert.Equal(t, "2345", string(body))
+	}
+
+	res, err = http.Get(fileURL + "x")
+	if assert.NoError(t, err) {
+		res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	}
+}
@@ -0,0 +1,320 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg/executable"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// TranscodingWorker runs transcoding jobs on behalf of an olaris server and uploads the resulting
+// segments back to it.
+type TranscodingWorker struct {
+	// ServerURL is the base URL of the server, e.g. "http://nas:8080".
+	ServerURL string
+	// URL is the base URL under which the server can reach this worker.
+	URL  string
+	Name string
+
+	client *http.Client
+
+	mutex sync.Mutex
+	jobs  map[string]*workerJob
+}
+
+type workerJob struct {
+	RemoteTranscodingJob
+	cmd       *exec.Cmd
+	outputDir string
+	// Segments that were already uploaded by name
+	uploaded  map[string]bool
+	cancelled bool
+}
+
+// NewTranscodingWorker creates a worker for the server with the given base URL.
+func NewTranscodingWorker(serverURL string, workerURL string, name string) *TranscodingWorker {
+	return &TranscodingWorker{
+		ServerURL: serverURL,
+		URL:       workerURL,
+		Name:      name,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		jobs:      map[string]*workerJob{},
+	}
+}
+
+// Handler returns the HTTP handler the server sends jobs to.
+func (w *TranscodingWorker) Handler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/jobs", w.serveStartJob).Methods("POST")
+	router.HandleFunc("/jobs/{jobID}", w.serveCancelJob).Methods("DELETE")
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !CheckWorkerSecret(r.Header.Get(WorkerSecretHeader)) {
+			http.Error(rw, "invalid worker secret", http.StatusUnauthorized)
+			return
+		}
+		router.ServeHTTP(rw, r)
+	})
+}
+
+// KeepRegistered registers the worker with the server and keeps doing so periodically so that
+// the server knows it is still healthy. It returns when stopChan is closed.
+func (w *TranscodingWorker) KeepRegistered(stopChan chan bool) {
+	ticker := time.NewTicker(workerHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.register(); err != nil {
+			log.WithFields(log.Fields{"server": w.ServerURL, "error": err}).
+				Warnln("Failed to register with server")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+func (w *TranscodingWorker) register() error {
+	b, _ := json.Marshal(map[string]string{"name": w.Name, "url": w.URL})
+	resp, err := w.sendToServer("POST", "/olaris/s/workers/register", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Shutdown cancels all running jobs.
+func (w *TranscodingWorker) Shutdown() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, job := range w.jobs {
+		job.cancel()
+	}
+}
+
+func (w *TranscodingWorker) serveStartJob(rw http.ResponseWriter, r *http.Request) {
+	job := RemoteTranscodingJob{}
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	j, err := w.newJob(job)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := j.cmd.Start(); err != nil {
+		os.RemoveAll(j.outputDir)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.mutex.Lock()
+	w.jobs[job.ID] = j
+	w.mutex.Unlock()
+
+	log.WithFields(log.Fields{"job": job.ID, "stream": job.StreamID, "start": job.SegmentStartIndex}).
+		Infoln("Started transcoding job")
+	go w.run(j)
+}
+
+func (w *TranscodingWorker) serveCancelJob(rw http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobID"]
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	job := w.jobs[jobID]
+	if job == nil {
+		http.NotFound(rw, r)
+		return
+	}
+	job.cancel()
+	log.WithFields(log.Fields{"job": jobID}).Infoln("Cancelled transcoding job")
+}
+
+func (w *TranscodingWorker) newJob(job RemoteTranscodingJob) (*workerJob, error) {
+	encoderParams, err := EncoderParamsFromString(job.EncoderParams)
+	if err != nil {
+		return nil, err
+	}
+
+	outputDirBase := path.Join(helpers.CacheDir(), "worker-jobs")
+	helpers.EnsurePath(outputDirBase)
+	outputDir, err := ioutil.TempDir(outputDirBase, "job-")
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Duration(int64(job.SegmentStartIndex) * int64(SegmentDuration))
+	inputURL := w.ServerURL + job.InputPath
+	feedbackURL := w.ServerURL + job.FeedbackPath
+
+	var args []string
+	switch job.StreamType {
+	case "video":
+		args = videoTranscodingArgs(
+			encoderParams, inputURL, job.StreamID, startTime, job.SegmentStartIndex, feedbackURL, outputDir)
+	case "audio":
+		args = audioTranscodingArgs(
+			encoderParams, inputURL, job.StreamID, startTime, job.SegmentStartIndex, feedbackURL, outputDir)
+	default:
+		os.RemoveAll(outputDir)
+		return nil, fmt.Errorf("cannot transcode %s streams", job.StreamType)
+	}
+
+	cmd := exec.Command(executable.GetFFmpegExecutablePath(), args...)
+	cmd.Stderr = getTranscodingLogSink("ffmpeg_worker")
+	cmd.Stdout = os.Stdout
+	cmd.Dir = outputDir
+
+	return &workerJob{
+		RemoteTranscodingJob: job,
+		cmd:                  cmd,
+		outputDir:            outputDir,
+		uploaded:             map[string]bool{},
+	}, nil
+}
+
+// cancel stops the job's ffmpeg process. Must be called with the worker's mutex held.
+func (j *workerJob) cancel() {
+	j.cancelled = true
+	syscall.Kill(j.cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// run uploads segments as ffmpeg finishes them and tells the server once the job is done.
+func (w *TranscodingWorker) run(j *workerJob) {
+	defer func() {
+		w.mutex.Lock()
+		delete(w.jobs, j.ID)
+		w.mutex.Unlock()
+		os.RemoveAll(j.outputDir)
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- j.cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			if w.isCancelled(j) {
+				return
+			}
+			// Upload whatever ffmpeg wrote last and report back.
+			uploadErr := w.uploadSegments(j, true)
+			jobErr := ""
+			if err != nil {
+				jobErr = err.Error()
+			} else if uploadErr != nil {
+				jobErr = uploadErr.Error()
+			}
+			b, _ := json.Marshal(map[string]string{"error": jobErr})
+			if resp, err := w.sendToServer("POST", w.jobPath(j, "done"), bytes.NewReader(b)); err == nil {
+				resp.Body.Close()
+			}
+			log.WithFields(log.Fields{"job": j.ID, "error": jobErr}).Infoln("Finished transcoding job")
+			return
+		case <-ticker.C:
+			if w.isCancelled(j) {
+				continue
+			}
+			if err := w.uploadSegments(j, false); err != nil {
+				log.WithFields(log.Fields{"job": j.ID, "error": err}).
+					Warnln("Failed to upload segments, cancelling job")
+				w.mutex.Lock()
+				j.cancel()
+				w.mutex.Unlock()
+			}
+		}
+	}
+}
+
+func (w *TranscodingWorker) isCancelled(j *workerJob) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return j.cancelled
+}
+
+// uploadSegments uploads all segments that weren't uploaded yet. Unless all is set, the newest
+// segment is skipped because ffmpeg may still be writing to it.
+func (w *TranscodingWorker) uploadSegments(j *workerJob, all bool) error {
+	s := TranscodingSession{OutputDir: j.outputDir, Terminated: all}
+	segments, err := s.AvailableSegments()
+	if err != nil {
+		return err
+	}
+
+	// Upload the init segment first and the rest in order so that the server never sees gaps.
+	indices := []int{}
+	for idx := range segments {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	for _, idx := range indices {
+		name := filepath.Base(segments[idx])
+		if j.uploaded[name] {
+			continue
+		}
+		f, err := os.Open(segments[idx])
+		if err != nil {
+			return err
+		}
+		resp, err := w.sendToServer("PUT", w.jobPath(j, name), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		j.uploaded[name] = true
+	}
+	return nil
+}
+
+func (w *TranscodingWorker) jobPath(j *workerJob, name string) string {
+	return fmt.Sprintf("/olaris/s/workers/jobs/%s/%s", url.PathEscape(j.ID), url.PathEscape(name))
+}
+
+func (w *TranscodingWorker) sendToServer(method string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, w.ServerURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(WorkerSecretHeader, *transcodingWorkerSecret)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server responded with %s", resp.Status)
+	}
+	return resp, nil
+}
@@ -2,8 +2,10 @@ package filesystem
 
 import (
 	"fmt"
+	"io"
 	"path"
 	"strings"
+	"time"
 )
 
 // BackendType specifies what kind of Library backend is being used.
@@ -14,6 +16,8 @@ const (
 	BackendLocal = iota
 	// BackendRclone is used for Rclone remotes
 	BackendRclone
+	// BackendHTTP is used for files served over HTTP(S), the path of their locators is the URL
+	BackendHTTP
 )
 
 type FileLocator struct {
@@ -24,6 +28,7 @@ type FileLocator struct {
 var backendTypeToString = map[BackendType]string{
 	BackendLocal:  "local",
 	BackendRclone: "rclone",
+	BackendHTTP:   "http",
 }
 
 func (fl FileLocator) String() string {
@@ -32,14 +37,24 @@ func (fl FileLocator) String() string {
 
 type WalkFunc func(path string, node Node, err error) error
 
+// File is an opened file, regardless of the backend it is stored on.
+type File interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
 type Node interface {
 	BackendType() BackendType
 	Size() int64
+	ModTime() time.Time
 	Name() string
 	Path() string
 	IsDir() bool
 	Walk(walkFunc WalkFunc, followFileSymlinks bool) error
 	FileLocator() FileLocator
+	// Open opens the file for reading. It fails for directories.
+	Open() (File, error)
 }
 
 func ParseFileLocator(locatorStr string) (FileLocator, error) {
@@ -56,17 +71,30 @@ func ParseFileLocator(locatorStr string) (FileLocator, error) {
 		return FileLocator{BackendRclone, parts[1]}, nil
 	} else if parts[0] == "local" {
 		return FileLocator{BackendLocal, parts[1]}, nil
+	} else if parts[0] == "http" {
+		return FileLocator{BackendHTTP, parts[1]}, nil
 	}
 	// Don't require an explicit local prefix for now
 	return FileLocator{BackendLocal, path.Clean("/" + locatorStr)}, nil
 }
 
+// GetNodeFromFileLocator returns the node of the given file. For .strm files, that is the node of
+// the file at the URL they contain.
 func GetNodeFromFileLocator(l FileLocator) (Node, error) {
+	l, err := ResolveFileLocator(l)
+	if err != nil {
+		return nil, err
+	}
+	return getNode(l)
+}
+
+func getNode(l FileLocator) (Node, error) {
 	if l.Backend == BackendLocal {
 		return LocalNodeFromPath(l.Path)
 	} else if l.Backend == BackendRclone {
 		return RcloneNodeFromPath(l.Path)
-
+	} else if l.Backend == BackendHTTP {
+		return HTTPNodeFromURL(l.Path)
 	}
 	return nil, fmt.Errorf("No such backend: %d", l.Backend)
 }
@@ -0,0 +1,38 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Number of bytes read from the start and the end of a file to compute its fingerprint.
+const fingerprintChunkSize = 64 * 1024
+
+// Fingerprint identifies a file by its size and a hash of its first and last bytes. It stays the
+// same when the file is moved or renamed, so it can be used to recognize a file under a new path,
+// while only reading a small part of it, which matters for remote backends.
+func Fingerprint(n Node) (string, error) {
+	f, err := n.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	size := n.Size()
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, fingerprintChunkSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	if size > 2*fingerprintChunkSize {
+		if _, err := f.Seek(size-fingerprintChunkSize, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(h, f, fingerprintChunkSize); err != nil && err != io.EOF {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%d-%s", size, hex.EncodeToString(h.Sum(nil))), nil
+}
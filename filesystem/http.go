@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+// httpClient is used for all requests to HTTP nodes. It has no overall timeout since reading a
+// file can take arbitrarily long, but gives up on servers that don't answer.
+var httpClient = &http.Client{
+	Transport: func() http.RoundTripper {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ResponseHeaderTimeout = 30 * time.Second
+		return t
+	}(),
+}
+
+// HTTPNode is a file served by an HTTP(S) server that supports Range requests.
+type HTTPNode struct {
+	url     string
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// HTTPNodeFromURL returns the node of the file at the given http or https URL. Its size and name
+// are taken from a HEAD request.
+func HTTPNodeFromURL(rawURL string) (*HTTPNode, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("\"%s\" is not an HTTP(S) URL", rawURL)
+	}
+
+	res, err := httpClient.Head(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("HEAD %s returned %s", rawURL, res.Status)
+	}
+	if res.ContentLength < 0 {
+		return nil, fmt.Errorf("server didn't return the size of %s", rawURL)
+	}
+
+	n := &HTTPNode{url: rawURL, size: res.ContentLength, name: path.Base(u.Path)}
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		n.name = path.Base(params["filename"])
+	}
+	if modTime, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		n.modTime = modTime
+	}
+	return n, nil
+}
+
+func (n *HTTPNode) Name() string {
+	return n.name
+}
+func (n *HTTPNode) Size() int64 {
+	return n.size
+}
+func (n *HTTPNode) ModTime() time.Time {
+	return n.modTime
+}
+func (n *HTTPNode) IsDir() bool {
+	return false
+}
+
+// Path returns the URL of the file.
+func (n *HTTPNode) Path() string {
+	return n.url
+}
+func (n *HTTPNode) BackendType() BackendType {
+	return BackendHTTP
+}
+func (n *HTTPNode) FileLocator() FileLocator {
+	return FileLocator{Backend: n.BackendType(), Path: n.url}
+}
+
+// Walk calls walkFn for the node itself since there is no way to list directories over HTTP.
+func (n *HTTPNode) Walk(walkFn WalkFunc, followFileSymlinks bool) error {
+	return walkFn(n.url, n, nil)
+}
+
+func (n *HTTPNode) Open() (File, error) {
+	return &httpFile{url: n.url, size: n.size}, nil
+}
+
+// httpFile reads a file over HTTP, requesting the part from the current offset on whenever it
+// seeks somewhere else.
+type httpFile struct {
+	url    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		req, err := http.NewRequest("GET", f.url, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(f.offset, 10)+"-")
+		res, err := httpClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		// Servers without Range support answer with the whole file, which is only what we want if
+		// we are at the start anyway.
+		if res.StatusCode != http.StatusPartialContent && !(res.StatusCode == http.StatusOK && f.offset == 0) {
+			res.Body.Close()
+			return 0, fmt.Errorf("GET %s from byte %d returned %s", f.url, f.offset, res.Status)
+		}
+		f.body = res.Body
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.size {
+		// The connection was closed early, continue with a new request next time.
+		f.body.Close()
+		f.body = nil
+		err = nil
+		if n == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative offset %d", offset)
+	}
+
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *httpFile) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
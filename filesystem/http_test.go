@@ -0,0 +1,118 @@
+package filesystem
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(content []byte) *httptest.Server {
+	modTime := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/videos/Heat.mkv", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "Heat.mkv", modTime, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="Ronin.mkv"`)
+		http.ServeContent(w, r, "", modTime, bytes.NewReader(content))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPNode(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	server := newTestServer(content)
+	defer server.Close()
+
+	n, err := HTTPNodeFromURL(server.URL + "/videos/Heat.mkv")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Heat.mkv", n.Name())
+	assert.Equal(t, int64(len(content)), n.Size())
+	assert.Equal(t, 2020, n.ModTime().Year())
+	assert.Equal(t, "http#"+server.URL+"/videos/Heat.mkv", n.FileLocator().String())
+
+	n, err = HTTPNodeFromURL(server.URL + "/download")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Ronin.mkv", n.Name())
+	}
+
+	_, err = HTTPNodeFromURL(server.URL + "/missing.mkv")
+	assert.Error(t, err)
+	_, err = HTTPNodeFromURL("ftp://example.com/Heat.mkv")
+	assert.Error(t, err)
+
+	f, err := n.Open()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	all, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, content, all)
+
+	buf := make([]byte, 1000)
+	_, err = f.Seek(50000, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(f, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, content[50000:51000], buf)
+
+	_, err = f.Seek(-500, io.SeekEnd)
+	assert.NoError(t, err)
+	rest, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, content[len(content)-500:], rest)
+}
+
+func TestStrmFile(t *testing.T) {
+	content := []byte("not really a video")
+	server := newTestServer(content)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "olaris-strm-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	strmPath := filepath.Join(dir, "Heat.strm")
+	assert.NoError(t, ioutil.WriteFile(strmPath,
+		[]byte("# Heat (1995)\n\n"+server.URL+"/videos/Heat.mkv\n"), 0644))
+	invalidPath := filepath.Join(dir, "Invalid.strm")
+	assert.NoError(t, ioutil.WriteFile(invalidPath, []byte("/mnt/videos/Heat.mkv\n"), 0644))
+
+	strm, err := LocalNodeFromPath(strmPath)
+	assert.NoError(t, err)
+	assert.True(t, IsStrmFile(strm))
+	u, err := ReadStrmFile(strm)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/videos/Heat.mkv", u)
+
+	l, err := ResolveFileLocator(strm.FileLocator())
+	assert.NoError(t, err)
+	assert.Equal(t, FileLocator{Backend: BackendHTTP, Path: u}, l)
+
+	n, err := GetNodeFromFileLocator(strm.FileLocator())
+	if assert.NoError(t, err) {
+		assert.Equal(t, BackendType(BackendHTTP), n.BackendType())
+		assert.Equal(t, int64(len(content)), n.Size())
+	}
+
+	_, err = GetNodeFromFileLocator(FileLocator{Backend: BackendLocal, Path: invalidPath})
+	assert.Error(t, err)
+
+	parsed, err := ParseFileLocator(l.String())
+	assert.NoError(t, err)
+	assert.Equal(t, l, parsed)
+}
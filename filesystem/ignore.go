@@ -0,0 +1,184 @@
+package filesystem
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the name of the files that list paths to skip while walking, one pattern per
+// line in gitignore syntax. The patterns apply to the directory the file is in and everything
+// below it.
+const IgnoreFileName = ".olarisignore"
+
+type ignorePattern struct {
+	// Directory the pattern is relative to
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	// Anchored patterns match the path relative to base, others match the name at any depth.
+	anchored bool
+}
+
+// IgnoreRules are the patterns of all ignore files that apply to a directory. Later patterns
+// override earlier ones, so the rules of subdirectories take precedence over those of their
+// parents.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+// globToRegexp translates a gitignore glob into an equivalent regular expression.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				break
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// With returns the rules extended by the patterns in content, which are relative to the
+// directory base. Lines that are empty, comments or invalid patterns are skipped.
+func (rules IgnoreRules) With(base string, content string) IgnoreRules {
+	patterns := append([]ignorePattern{}, rules.patterns...)
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := ignorePattern{base: base}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		re, err := globToRegexp(line)
+		if err != nil {
+			continue
+		}
+		p.re = re
+		patterns = append(patterns, p)
+	}
+
+	return IgnoreRules{patterns: patterns}
+}
+
+// relativeTo returns p relative to the directory base, or false if it isn't below it.
+func relativeTo(base string, p string) (string, bool) {
+	switch base {
+	case "", ".":
+		return strings.TrimPrefix(p, "/"), true
+	case "/":
+		return strings.TrimPrefix(p, "/"), strings.HasPrefix(p, "/")
+	}
+	if strings.HasPrefix(p, base+"/") {
+		return p[len(base)+1:], true
+	}
+	return "", false
+}
+
+// Ignored returns whether the entry at p is ignored. It doesn't consider whether one of its
+// parent directories is, see IgnoredPath for that.
+func (rules IgnoreRules) Ignored(p string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range rules.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := relativeTo(pattern.base, p)
+		if !ok || rel == "" {
+			continue
+		}
+		if !pattern.anchored {
+			rel = path.Base(rel)
+		}
+		if pattern.re.MatchString(rel) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
+
+// IgnoredPath returns whether the entry at p or one of its parent directories is ignored.
+func (rules IgnoreRules) IgnoredPath(p string, isDir bool) bool {
+	for _, dir := range ancestors(p) {
+		if rules.Ignored(dir, true) {
+			return true
+		}
+	}
+	return rules.Ignored(p, isDir)
+}
+
+// ancestors returns the directories containing p, outermost first.
+func ancestors(p string) (dirs []string) {
+	for d := path.Dir(p); d != p; d = path.Dir(d) {
+		dirs = append([]string{d}, dirs...)
+		if d == "/" || d == "." {
+			break
+		}
+	}
+	return dirs
+}
+
+// ancestorIgnoreRules collects the ignore rules of all directories containing p, reading their
+// ignore files with readIgnoreFile. Returns false if p or one of the directories is ignored.
+func ancestorIgnoreRules(p string, isDir bool, readIgnoreFile func(dir string) string) (IgnoreRules, bool) {
+	rules := IgnoreRules{}
+	for _, dir := range ancestors(p) {
+		if rules.Ignored(dir, true) {
+			return rules, false
+		}
+		rules = rules.With(dir, readIgnoreFile(dir))
+	}
+	return rules, !rules.Ignored(p, isDir)
+}
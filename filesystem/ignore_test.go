@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := IgnoreRules{}.With("/lib", `
+# Synology thumbnails
+@eaDir/
+*sample*
+!keep-sample.mkv
+/Extras
+docs/**/*.txt
+`)
+
+	assert.True(t, rules.Ignored("/lib/Heat/@eaDir", true))
+	assert.False(t, rules.Ignored("/lib/Heat/@eaDir", false))
+	assert.True(t, rules.Ignored("/lib/Heat/heat-sample.mkv", false))
+	assert.False(t, rules.Ignored("/lib/Heat/keep-sample.mkv", false))
+	assert.True(t, rules.Ignored("/lib/Extras", true))
+	assert.False(t, rules.Ignored("/lib/Heat/Extras", true))
+	assert.True(t, rules.Ignored("/lib/docs/a/b/c.txt", false))
+	assert.True(t, rules.Ignored("/lib/docs/c.txt", false))
+	assert.False(t, rules.Ignored("/other/heat-sample.mkv", false))
+	assert.False(t, rules.Ignored("/lib/Heat/Heat.mkv", false))
+
+	assert.True(t, rules.IgnoredPath("/lib/Extras/Behind the scenes.mkv", false))
+	assert.False(t, rules.IgnoredPath("/lib/Heat/Heat.mkv", false))
+
+	rclone := IgnoreRules{}.With(".", "Extras/")
+	assert.True(t, rclone.Ignored("Movies/Extras", true))
+	assert.False(t, rclone.Ignored("Movies/Extras", false))
+}
+
+func TestLocalWalkIgnore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "olaris-ignore-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, p := range []string{
+		"Heat/Heat.mkv",
+		"Heat/Heat-sample.mkv",
+		"Heat/@eaDir/Heat.mkv",
+		"Ronin/Ronin.mkv",
+		"Ronin/Extras/Interview.mkv",
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, p), []byte{}, 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, IgnoreFileName), []byte("@eaDir/\n*-sample.*\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Ronin", IgnoreFileName), []byte("Extras/\n"), 0644))
+
+	walkFiles := func(root string) (files []string) {
+		n, err := LocalNodeFromPath(root)
+		assert.NoError(t, err)
+		n.Walk(func(walkPath string, n Node, err error) error {
+			if err == nil && !n.IsDir() && n.Name() != IgnoreFileName {
+				rel, _ := filepath.Rel(dir, walkPath)
+				files = append(files, rel)
+			}
+			return nil
+		}, true)
+		sort.Strings(files)
+		return files
+	}
+
+	assert.Equal(t, []string{"Heat/Heat.mkv", "Ronin/Ronin.mkv"}, walkFiles(dir))
+	// Ignore files of parent directories apply when walking a subdirectory.
+	assert.Equal(t, []string{"Heat/Heat.mkv"}, walkFiles(filepath.Join(dir, "Heat")))
+	assert.Nil(t, walkFiles(filepath.Join(dir, "Heat", "@eaDir")))
+}
@@ -1,8 +1,11 @@
 package filesystem
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type LocalNode struct {
@@ -24,6 +27,9 @@ func (n *LocalNode) Name() string {
 func (n *LocalNode) Size() int64 {
 	return n.fileInfo.Size()
 }
+func (n *LocalNode) ModTime() time.Time {
+	return n.fileInfo.ModTime()
+}
 func (n *LocalNode) IsDir() bool {
 	return n.fileInfo.IsDir()
 }
@@ -36,8 +42,48 @@ func (n *LocalNode) BackendType() BackendType {
 func (n *LocalNode) FileLocator() FileLocator {
 	return FileLocator{Backend: n.BackendType(), Path: n.path}
 }
+func (n *LocalNode) Open() (File, error) {
+	if n.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", n.path)
+	}
+	return os.Open(n.path)
+}
+
+// readLocalIgnoreFile returns the content of the ignore file in the given directory, if any.
+func readLocalIgnoreFile(dir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// Walk calls walkFn for the node and everything below it, skipping paths that are ignored by
+// IgnoreFileName files in the directories containing them.
 func (n *LocalNode) Walk(walkFn WalkFunc, followFileSymlinks bool) error {
+	rootRules, ok := ancestorIgnoreRules(n.path, n.IsDir(), readLocalIgnoreFile)
+	if !ok {
+		return nil
+	}
+	rulesByDir := map[string]IgnoreRules{}
+
 	return filepath.Walk(n.path, func(walkPath string, info os.FileInfo, err error) error {
+		if err == nil {
+			rules := rootRules
+			if walkPath != n.path {
+				rules = rulesByDir[filepath.Dir(walkPath)]
+				if rules.Ignored(walkPath, info.IsDir()) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			if info.IsDir() {
+				rulesByDir[filepath.Clean(walkPath)] = rules.With(filepath.Clean(walkPath), readLocalIgnoreFile(walkPath))
+			}
+		}
+
 		// NOTE(Leon Handreke): This behaviour breaks with what filepath.Walk usually does
 		// and is a bit weird in general. We should figure out a good strategy here and how
 		// to properly abstract it out
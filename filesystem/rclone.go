@@ -1,14 +1,19 @@
 package filesystem
 
 import (
+	"context"
 	"fmt"
 	_ "github.com/ncw/rclone/backend/all"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/vfs"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
 type rclonePath struct {
@@ -36,7 +41,124 @@ type RcloneNode struct {
 	Node vfs.Node
 }
 
+// rcloneMutex guards the caches below, which are shared by all libraries.
+var rcloneMutex = sync.Mutex{}
+
 var vfsCache = map[string]*vfs.VFS{}
+var fsCache = map[string]fs.Fs{}
+
+// vfsOptions holds the VFS options of each remote by the ID of the library that set them.
+var vfsOptions = map[string]map[uint]VFSOptions{}
+
+// VFSOptions are the options of the VFS through which the files of an rclone remote are read.
+type VFSOptions struct {
+	// CacheMode is one of the rclone VFS cache modes: off, minimal, writes or full.
+	CacheMode string
+	// CacheMaxSize is the size in bytes the cache is kept below, -1 means unlimited.
+	CacheMaxSize int64
+	// ChunkSize is the size in bytes of the chunks files are requested from the remote in.
+	ChunkSize int64
+}
+
+// DefaultVFSOptions are used for remotes that have no options set.
+var DefaultVFSOptions = VFSOptions{
+	CacheMode:    vfs.CacheModeMinimal.String(),
+	CacheMaxSize: -1,
+	ChunkSize:    int64(32 * fs.MebiByte),
+}
+
+// Validate returns an error if one of the options has an invalid value.
+func (o VFSOptions) Validate() error {
+	var mode vfs.CacheMode
+	if err := mode.Set(o.CacheMode); err != nil {
+		return fmt.Errorf("invalid VFS cache mode '%s'", o.CacheMode)
+	}
+	if o.CacheMaxSize < -1 {
+		return fmt.Errorf("VFS cache size can't be negative")
+	}
+	if o.ChunkSize < 0 {
+		return fmt.Errorf("VFS chunk size can't be negative")
+	}
+	return nil
+}
+
+// CheckRcloneVFSOptions returns an error if another library already set different VFS options for
+// the given remote. All libraries on a remote share its VFS, so they have to agree on its options.
+func CheckRcloneVFSOptions(remoteName string, libraryID uint, opts VFSOptions) error {
+	rcloneMutex.Lock()
+	defer rcloneMutex.Unlock()
+	return checkRcloneVFSOptionsLocked(remoteName, libraryID, opts)
+}
+
+func checkRcloneVFSOptionsLocked(remoteName string, libraryID uint, opts VFSOptions) error {
+	for otherID, other := range vfsOptions[remoteName] {
+		if otherID != libraryID && other != opts {
+			return fmt.Errorf(
+				"rclone remote '%s' is used by another library with different VFS options", remoteName)
+		}
+	}
+	return nil
+}
+
+// SetRcloneVFSOptions sets the options of the VFS of the given remote on behalf of the given
+// library, failing like CheckRcloneVFSOptions if another library disagrees. They only apply when
+// the VFS is created, so changes to a remote that is already in use take effect after a restart.
+func SetRcloneVFSOptions(remoteName string, libraryID uint, opts VFSOptions) error {
+	rcloneMutex.Lock()
+	defer rcloneMutex.Unlock()
+
+	if err := checkRcloneVFSOptionsLocked(remoteName, libraryID, opts); err != nil {
+		return err
+	}
+	if vfsOptions[remoteName] == nil {
+		vfsOptions[remoteName] = map[uint]VFSOptions{}
+	}
+	vfsOptions[remoteName][libraryID] = opts
+	return nil
+}
+
+// ReleaseRcloneVFSOptions forgets the VFS options the given library set for the given remote once
+// it no longer uses it.
+func ReleaseRcloneVFSOptions(remoteName string, libraryID uint) {
+	rcloneMutex.Lock()
+	defer rcloneMutex.Unlock()
+	delete(vfsOptions[remoteName], libraryID)
+}
+
+// getRcloneVFS returns the VFS of the given remote, creating it if required.
+func getRcloneVFS(remoteName string) (*vfs.VFS, error) {
+	rcloneMutex.Lock()
+	defer rcloneMutex.Unlock()
+
+	if v, inCache := vfsCache[remoteName]; inCache {
+		return v, nil
+	}
+
+	log.WithFields(log.Fields{"remoteName": remoteName}).Debugln("Creating Rclone VFS")
+	filesystem, err := fs.NewFs(remoteName + ":/")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create rclone Fs")
+	}
+	// The libraries on the remote all set the same options.
+	vfsOpts := DefaultVFSOptions
+	for _, o := range vfsOptions[remoteName] {
+		vfsOpts = o
+		break
+	}
+	if err := vfsOpts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Ensuring the latest default options modified for our usecase is probaly safer
+	opts := vfs.DefaultOpt
+	opts.CacheMode.Set(vfsOpts.CacheMode)
+	opts.CacheMaxSize = fs.SizeSuffix(vfsOpts.CacheMaxSize)
+	opts.ChunkSize = fs.SizeSuffix(vfsOpts.ChunkSize)
+
+	fsCache[remoteName] = filesystem
+	vfsCache[remoteName] = vfs.New(filesystem, &opts)
+	return vfsCache[remoteName], nil
+}
 
 func RcloneNodeFromPath(pathStr string) (*RcloneNode, error) {
 	l, err := splitRclonePath(pathStr)
@@ -44,22 +166,13 @@ func RcloneNodeFromPath(pathStr string) (*RcloneNode, error) {
 		return nil, err
 	}
 
-	if _, inCache := vfsCache[l.remoteName]; !inCache {
-		log.WithFields(log.Fields{"remoteName": l.remoteName}).Debugln("Creating Rclone VFS")
-		filesystem, err := fs.NewFs(l.remoteName + ":/")
-		if err != nil {
-			return nil, errors.Wrap(err, "Failed to create rclone Fs")
-		}
-		// Ensuring the latest default options modified for our usecase is probaly safer
-		opts := vfs.DefaultOpt
-		opts.CacheMode = vfs.CacheModeMinimal
-		opts.ChunkSize = 32 * fs.MebiByte
-
-		vfsCache[l.remoteName] = vfs.New(filesystem, &opts)
+	v, err := getRcloneVFS(l.remoteName)
+	if err != nil {
+		return nil, err
 	}
 	p := "/" + l.path
 	log.WithFields(log.Fields{"path": p, "remoteName": l.remoteName}).Debugln("Checking if Rclone path exists")
-	node, err := vfsCache[l.remoteName].Stat(p)
+	node, err := v.Stat(p)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +190,10 @@ func (n *RcloneNode) Size() int64 {
 	return n.Node.Size()
 }
 
+func (n *RcloneNode) ModTime() time.Time {
+	return n.Node.ModTime()
+}
+
 func (n *RcloneNode) IsDir() bool {
 	return n.Node.IsDir()
 }
@@ -87,6 +204,8 @@ func (n *RcloneNode) BackendType() BackendType {
 func (n *RcloneNode) FileLocator() FileLocator {
 	// This is a bit of a hack because it seems to be impossible to get the
 	// rclone remote name from vfs.Node
+	rcloneMutex.Lock()
+	defer rcloneMutex.Unlock()
 	for name, v := range vfsCache {
 		if v == n.Node.VFS() {
 			return FileLocator{
@@ -98,15 +217,55 @@ func (n *RcloneNode) FileLocator() FileLocator {
 	panic("VFS for given Node not found in cache")
 }
 
+func (n *RcloneNode) Open() (File, error) {
+	f, ok := n.Node.(*vfs.File)
+	if !ok {
+		return nil, fmt.Errorf("%s is a directory", n.Path())
+	}
+	return f.Open(os.O_RDONLY)
+}
+
+// readRcloneFile returns the content of the given file, or an empty string if it can't be read.
+func readRcloneFile(node vfs.Node) string {
+	f, ok := node.(*vfs.File)
+	if !ok {
+		return ""
+	}
+	handle, err := f.Open(os.O_RDONLY)
+	if err != nil {
+		return ""
+	}
+	defer handle.Close()
+	content, err := ioutil.ReadAll(handle)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// Walk calls walkFn for the node and everything below it, skipping paths that are ignored by
+// IgnoreFileName files in the directories containing them.
 func (n *RcloneNode) Walk(walkFn WalkFunc, followFileSymlinks bool) error {
+	v := n.Node.VFS()
+	rules, ok := ancestorIgnoreRules(n.Path(), n.IsDir(), func(dir string) string {
+		node, err := v.Stat(path.Join(dir, IgnoreFileName))
+		if err != nil {
+			return ""
+		}
+		return readRcloneFile(node)
+	})
+	if !ok {
+		return nil
+	}
+
 	if n.Node.IsDir() {
-		return walk(n.Node.(*vfs.Dir), walkFn)
+		return walk(n.Node.(*vfs.Dir), rules, walkFn)
 	} else {
 		return walkFn(n.Path(), n, nil)
 	}
 }
 
-func walk(root *vfs.Dir, walkFn WalkFunc) error {
+func walk(root *vfs.Dir, rules IgnoreRules, walkFn WalkFunc) error {
 	entries, err := root.ReadDirAll()
 	if err != nil {
 		err = walkFn(root.Path(), &RcloneNode{root}, err)
@@ -115,8 +274,18 @@ func walk(root *vfs.Dir, walkFn WalkFunc) error {
 		}
 	} else {
 		for _, n := range entries {
+			if n.Name() == IgnoreFileName {
+				rules = rules.With(root.Path(), readRcloneFile(n))
+			}
+		}
+
+		for _, n := range entries {
+			if rules.Ignored(n.Path(), n.IsDir()) {
+				continue
+			}
+
 			if n.IsDir() {
-				err = walk(n.(*vfs.Dir), walkFn)
+				err = walk(n.(*vfs.Dir), rules, walkFn)
 			} else {
 				err = walkFn(n.Path(), &RcloneNode{n}, nil)
 			}
@@ -128,3 +297,57 @@ func walk(root *vfs.Dir, walkFn WalkFunc) error {
 	}
 	return nil
 }
+
+// RcloneChangeNotify calls notify with the path of every file or directory on the given remote
+// that the remote reports as changed, until stop is closed. Paths are in the form
+// RcloneNodeFromPath takes. interval is how often the remote is asked for changes if it has to
+// poll itself. Returns false if the remote doesn't support change notifications.
+func RcloneChangeNotify(
+	remoteName string,
+	interval time.Duration,
+	notify func(pathStr string, isDir bool),
+	stop <-chan struct{}) (bool, error) {
+
+	v, err := getRcloneVFS(remoteName)
+	if err != nil {
+		return false, err
+	}
+	rcloneMutex.Lock()
+	changeNotify := fsCache[remoteName].Features().ChangeNotify
+	rcloneMutex.Unlock()
+	if changeNotify == nil {
+		return false, nil
+	}
+	root, err := v.Root()
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pollChan := make(chan time.Duration)
+	changeNotify(ctx, func(p string, entryType fs.EntryType) {
+		// The VFS gets the same notification, but we might be first. Make sure we don't get a
+		// stale directory listing when looking at the change.
+		root.ForgetPath(p, entryType)
+		notify(path.Join("/", remoteName, p), entryType == fs.EntryDirectory)
+	}, pollChan)
+	pollChan <- interval
+
+	go func() {
+		<-stop
+		cancel()
+		close(pollChan)
+	}()
+	return true, nil
+}
+
+// FlushRcloneDirCache forgets all cached directory listings of the given remote so that the next
+// walk sees its current state.
+func FlushRcloneDirCache(remoteName string) {
+	rcloneMutex.Lock()
+	v, inCache := vfsCache[remoteName]
+	rcloneMutex.Unlock()
+	if inCache {
+		v.FlushDirCache()
+	}
+}
@@ -0,0 +1,22 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRcloneVFSOptionsConflict(t *testing.T) {
+	full := DefaultVFSOptions
+	full.CacheMode = "full"
+
+	assert.NoError(t, SetRcloneVFSOptions("gdrive", 1, DefaultVFSOptions))
+	assert.NoError(t, SetRcloneVFSOptions("gdrive", 2, DefaultVFSOptions))
+	assert.Error(t, CheckRcloneVFSOptions("gdrive", 2, full))
+	assert.Error(t, SetRcloneVFSOptions("gdrive", 2, full))
+	assert.NoError(t, CheckRcloneVFSOptions("dropbox", 2, full))
+
+	// A library may change its options once it's the only one on the remote.
+	ReleaseRcloneVFSOptions("gdrive", 1)
+	assert.NoError(t, SetRcloneVFSOptions("gdrive", 2, full))
+	assert.Equal(t, full, vfsOptions["gdrive"][2])
+}
@@ -0,0 +1,70 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// StrmExtension is the extension of files that contain the URL of a video hosted elsewhere, which
+// is indexed and streamed in their place.
+const StrmExtension = ".strm"
+
+// .strm files are tiny, anything bigger isn't one.
+const maxStrmFileSize = 64 << 10
+
+// IsStrmFile returns whether the given node is a .strm file.
+func IsStrmFile(n Node) bool {
+	return !n.IsDir() && strings.EqualFold(path.Ext(n.Name()), StrmExtension)
+}
+
+// ReadStrmFile returns the URL in the given .strm file. That is its first line that is neither
+// empty nor a comment.
+func ReadStrmFile(n Node) (string, error) {
+	if n.Size() > maxStrmFileSize {
+		return "", fmt.Errorf("%s is too big to be a %s file", n.Path(), StrmExtension)
+	}
+	f, err := n.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, maxStrmFileSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", fmt.Errorf("%s doesn't contain an HTTP(S) URL", n.Path())
+		}
+		return line, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s is empty", n.Path())
+}
+
+// ResolveFileLocator returns the locator of the URL in the given file if it is a .strm file, and
+// the given locator otherwise.
+func ResolveFileLocator(l FileLocator) (FileLocator, error) {
+	if l.Backend == BackendHTTP || !strings.EqualFold(path.Ext(l.Path), StrmExtension) {
+		return l, nil
+	}
+
+	n, err := getNode(l)
+	if err != nil {
+		return FileLocator{}, err
+	}
+	u, err := ReadStrmFile(n)
+	if err != nil {
+		return FileLocator{}, err
+	}
+	return FileLocator{Backend: BackendHTTP, Path: u}, nil
+}
@@ -83,6 +83,17 @@ func CacheDir() string {
 	return path.Join(cacheDir, "olaris")
 }
 
+// ImageCachePath returns the path where artwork images are cached.
+func ImageCachePath() string {
+	return path.Join(CacheDir(), "images")
+}
+
+// LocalImagePath returns the path where images generated by olaris itself (as opposed to
+// downloaded from a metadata provider) are stored.
+func LocalImagePath() string {
+	return path.Join(ImageCachePath(), "local")
+}
+
 // LogPath returns the path to our logfolder.
 func LogPath() string {
 	logPath := path.Join(CacheDir(), "log")
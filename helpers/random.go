@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	crand "crypto/rand"
 	"math/rand"
 	"time"
 )
@@ -23,3 +24,22 @@ func RandAlphaString(n int) string {
 	}
 	return string(b)
 }
+
+// SecureRandAlphaString is like RandAlphaString but reads from crypto/rand, for strings that must
+// not be guessable, like tokens that grant access.
+func SecureRandAlphaString(n int) (string, error) {
+	b := make([]byte, n)
+	buf := make([]byte, n)
+	for i := 0; i < n; {
+		if _, err := crand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, r := range buf {
+			if idx := int(r & letterIdxMask); idx < len(letterBytes) && i < n {
+				b[i] = letterBytes[idx]
+				i++
+			}
+		}
+	}
+	return string(b), nil
+}
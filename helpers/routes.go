@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"net/url"
+)
+
+// Where the streaming and metadata servers are mounted, see the serve command.
+const (
+	streamingPathPrefix = "/olaris/s"
+	metadataPathPrefix  = "/olaris/m"
+)
+
+// StreamingPath returns the path of the streaming server endpoint made up of the given elements,
+// which are escaped.
+func StreamingPath(elems ...string) string {
+	return joinPathElems(streamingPathPrefix, elems)
+}
+
+// MetadataPath returns the path of the metadata server endpoint made up of the given elements,
+// which are escaped.
+func MetadataPath(elems ...string) string {
+	return joinPathElems(metadataPathPrefix, elems)
+}
+
+func joinPathElems(prefix string, elems []string) string {
+	p := prefix
+	for _, elem := range elems {
+		p += "/" + url.PathEscape(elem)
+	}
+	return p
+}
@@ -18,3 +18,11 @@ type MetadataRetrievalAgent interface {
 	TmdbSearchMovie(name string, options map[string]string) (*tmdb.MovieSearchResults, error)
 	TmdbSearchTv(name string, options map[string]string) (*tmdb.TvSearchResults, error)
 }
+
+// LocalizedAgent is implemented by agents that can retrieve metadata in other languages than
+// their default one.
+type LocalizedAgent interface {
+	// Localized returns an agent that retrieves metadata in the given ISO 639-1 language,
+	// preferring the release information of the given ISO 3166-1 region. Both may be empty.
+	Localized(language string, region string) MetadataRetrievalAgent
+}
@@ -13,17 +13,49 @@ const tmdbAPIKey = "0cdacd9ab172ac6ff69c8d84b2c938a8"
 // TmdbAgent is a wrapper around themoviedb
 type TmdbAgent struct {
 	Tmdb *tmdb.TMDb
+
+	// Language and Region are passed to all requests if set.
+	Language string
+	Region   string
 }
 
 // NewTmdbAgent creates a new themoviedb agent.
 func NewTmdbAgent() *TmdbAgent {
-	return &TmdbAgent{tmdb.Init(tmdb.Config{
+	return &TmdbAgent{Tmdb: tmdb.Init(tmdb.Config{
 		APIKey:   tmdbAPIKey,
 		Proxies:  nil,
 		UseProxy: false,
 	})}
 }
 
+// Localized returns an agent that retrieves metadata in the given language, preferring the
+// release information of the given region.
+func (a *TmdbAgent) Localized(language string, region string) MetadataRetrievalAgent {
+	return &TmdbAgent{Tmdb: a.Tmdb, Language: language, Region: region}
+}
+
+// withLocale adds the language and region of the agent to the given request options.
+func (a *TmdbAgent) withLocale(options map[string]string) map[string]string {
+	if a.Language == "" && a.Region == "" {
+		return options
+	}
+
+	localized := map[string]string{}
+	for k, v := range options {
+		localized[k] = v
+	}
+	if a.Language != "" {
+		localized["language"] = a.Language
+		if a.Region != "" {
+			localized["language"] = a.Language + "-" + a.Region
+		}
+	}
+	if a.Region != "" {
+		localized["region"] = a.Region
+	}
+	return localized
+}
+
 // ParseTmdbDate parses a date string returned from the TMDB API
 func ParseTmdbDate(tmdbDate string) (time.Time, error) {
 	return time.Parse("2006-01-02", tmdbDate)
@@ -34,7 +66,7 @@ func (a *TmdbAgent) UpdateEpisodeMD(
 	episode *db.Episode, seriesTmdbID int, seasonNum int, episodeNum int,
 ) error {
 	fullEpisode, err := a.Tmdb.GetTvEpisodeInfo(
-		seriesTmdbID, seasonNum, episodeNum, nil)
+		seriesTmdbID, seasonNum, episodeNum, a.withLocale(nil))
 	if err != nil {
 		return errors.Wrap(err, "Could not retrieve episode data from TMDB")
 	}
@@ -59,7 +91,7 @@ func (a *TmdbAgent) UpdateSeasonMD(season *db.Season, seriesTmdbID int, seasonNu
 			"seriesTmdbID": seriesTmdbID}).
 		Debugln("Looking for season metadata.")
 
-	fullSeason, err := a.Tmdb.GetTvSeasonInfo(seriesTmdbID, seasonNum, nil)
+	fullSeason, err := a.Tmdb.GetTvSeasonInfo(seriesTmdbID, seasonNum, a.withLocale(nil))
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).Warnln("Could not grab season information.")
 		return err
@@ -77,7 +109,7 @@ func (a *TmdbAgent) UpdateSeasonMD(season *db.Season, seriesTmdbID int, seasonNu
 
 // UpdateSeriesMD updates the metadata information for the given series.
 func (a *TmdbAgent) UpdateSeriesMD(series *db.Series, tmdbID int) error {
-	fullTv, err := a.Tmdb.GetTvInfo(tmdbID, nil)
+	fullTv, err := a.Tmdb.GetTvInfo(tmdbID, a.withLocale(nil))
 
 	if err != nil {
 		log.
@@ -104,7 +136,7 @@ func (a *TmdbAgent) UpdateSeriesMD(series *db.Series, tmdbID int) error {
 
 // UpdateMovieMD updates
 func (a *TmdbAgent) UpdateMovieMD(movie *db.Movie, tmdbID int) error {
-	r, err := a.Tmdb.GetMovieInfo(tmdbID, nil)
+	r, err := a.Tmdb.GetMovieInfo(tmdbID, a.withLocale(nil))
 
 	if err != nil {
 		return errors.Wrap(err, "Failed to query TMDB for movie metadata")
@@ -130,7 +162,7 @@ func (a *TmdbAgent) TmdbSearchMovie(
 	name string,
 	options map[string]string,
 ) (*tmdb.MovieSearchResults, error) {
-	return a.Tmdb.SearchMovie(name, options)
+	return a.Tmdb.SearchMovie(name, a.withLocale(options))
 }
 
 // TmdbSearchTv directly exposes the TMDb search interface
@@ -138,5 +170,5 @@ func (a *TmdbAgent) TmdbSearchTv(
 	name string,
 	options map[string]string,
 ) (*tmdb.TvSearchResults, error) {
-	return a.Tmdb.SearchTv(name, options)
+	return a.Tmdb.SearchTv(name, a.withLocale(options))
 }
@@ -25,10 +25,22 @@ type MetadataContext struct {
 	MetadataManager        *metadata.MetadataManager
 
 	ExitChan chan bool
+
+	// cleanupFuncs are called by Cleanup while the database is still open.
+	cleanupFuncs []func()
+}
+
+// OnCleanup registers a function that stops something working on the context, e.g. a background
+// manager. It is called by Cleanup before the database is closed.
+func (m *MetadataContext) OnCleanup(f func()) {
+	m.cleanupFuncs = append(m.cleanupFuncs, f)
 }
 
 // Cleanup cleans up any running threads / processes for the context.
 func (m *MetadataContext) Cleanup() {
+	for _, f := range m.cleanupFuncs {
+		f()
+	}
 	m.ExitChan <- true
 	m.Db.Close()
 	log.Infoln("Closed all metadata context")
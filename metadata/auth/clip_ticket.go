@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// clipAudience marks clip JWTs so that they can't be mistaken for other kinds of JWTs.
+const clipAudience = "clip"
+
+// ClipClaims is a custom JWT that allows anyone to fetch a finished clip until it expires.
+type ClipClaims struct {
+	ClipUUID string
+	jwt.StandardClaims
+}
+
+// CreateClipJWT creates a new JWT that gives permission to fetch the given clip until expiresAt.
+func CreateClipJWT(clipUUID string, expiresAt time.Time) (string, error) {
+	claims := ClipClaims{
+		clipUUID,
+		jwt.StandardClaims{ExpiresAt: expiresAt.Unix(), Issuer: "bss", Audience: clipAudience},
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return t.SignedString([]byte(secret))
+}
+
+// ValidateClipJWT validates whether a clip JWT is still valid.
+func ValidateClipJWT(tokenStr string) (*ClipClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &ClipClaims{}, jwtSecretFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*ClipClaims); ok && token.Valid &&
+		claims.VerifyAudience(clipAudience, true) {
+		log.WithFields(log.Fields{"clip": claims.ClipUUID, "expires": claims.ExpiresAt}).Debugf("Validate clip ticket")
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("could not validate ticket")
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// downloadAudience marks download JWTs so that they can't be mistaken for other kinds of JWTs.
+const downloadAudience = "download"
+
+// DownloadClaims is a custom JWT that allows a user to fetch a finished download until it expires.
+type DownloadClaims struct {
+	UserID       uint
+	DownloadUUID string
+	jwt.StandardClaims
+}
+
+// CreateDownloadJWT creates a new JWT that gives permission to fetch the given download until expiresAt.
+func CreateDownloadJWT(userID uint, downloadUUID string, expiresAt time.Time) (string, error) {
+	claims := DownloadClaims{
+		userID,
+		downloadUUID,
+		jwt.StandardClaims{ExpiresAt: expiresAt.Unix(), Issuer: "bss", Audience: downloadAudience},
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return t.SignedString([]byte(secret))
+}
+
+// ValidateDownloadJWT validates whether a download JWT is still valid.
+func ValidateDownloadJWT(tokenStr string) (*DownloadClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &DownloadClaims{}, jwtSecretFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*DownloadClaims); ok && token.Valid &&
+		claims.VerifyAudience(downloadAudience, true) {
+		log.WithFields(log.Fields{"user": claims.UserID, "download": claims.DownloadUUID, "expires": claims.ExpiresAt}).Debugf("Validate download ticket")
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("could not validate ticket")
+}
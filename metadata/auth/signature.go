@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// Sign returns an HMAC of the given data keyed with the server's secret. It is used to hand out
+// data to clients that the server has to be able to trust when it comes back.
+func Sign(data []byte) ([]byte, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// VerifySignature returns whether signature was produced by Sign for the given data. Truncated
+// signatures are accepted as long as they are at least 16 bytes long.
+func VerifySignature(data []byte, signature []byte) bool {
+	expected, err := Sign(data)
+	if err != nil || len(signature) < 16 || len(signature) > len(expected) {
+		return false
+	}
+	return hmac.Equal(expected[:len(signature)], signature)
+}
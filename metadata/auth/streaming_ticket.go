@@ -3,7 +3,9 @@ package auth
 import (
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 	"time"
 )
 
@@ -11,17 +13,67 @@ import (
 type StreamingClaims struct {
 	UserID   uint
 	FilePath string
+	// ShareToken is set if the ticket was handed out through a share link.
+	ShareToken string `json:",omitempty"`
+	// GuestID identifies the guest a share link ticket was handed out to. Guests play as
+	// themselves rather than as the link's creator, who only vouches for them.
+	GuestID string `json:",omitempty"`
 	jwt.StandardClaims
 }
 
+// Tickets are valid for this long unless they were handed out through a share link expiring sooner.
+const streamingTicketValidity = 8 * time.Hour
+
 // CreateStreamingJWT creates a new JWT that will give permission to stream certain media for a certain timespan.
+// Tickets of users are registered by their JTI so that they can be revoked. Tickets for user 0 are
+// only used internally, e.g. by ffmpeg, and are not registered.
 func CreateStreamingJWT(userID uint, fileLocator string) (string, error) {
-	expiresAt := time.Now().Add(time.Hour * 8).Unix()
+	return createStreamingJWT(userID, fileLocator, "", "", time.Now().Add(streamingTicketValidity))
+}
+
+// CreateShareStreamingJWT creates a ticket for the given guest for a file of the media item the
+// given share link points to. It is only valid as long as the link is and is registered to the
+// link's creator so that it is revoked with their tickets.
+func CreateShareStreamingJWT(link *db.ShareLink, guestID string, fileLocator string) (string, error) {
+	expiresAt := time.Now().Add(streamingTicketValidity)
+	if link.ExpiresAt.Before(expiresAt) {
+		expiresAt = link.ExpiresAt
+	}
+	return createStreamingJWT(link.UserID, fileLocator, link.Token, guestID, expiresAt)
+}
+
+// CreateDerivedStreamingJWT creates a ticket for another file, e.g. a subtitle, on behalf of the
+// holder of the given ticket. It has the same restrictions as the given ticket, which may be nil
+// for internal requests.
+func CreateDerivedStreamingJWT(claims *StreamingClaims, fileLocator string) (string, error) {
+	if claims == nil {
+		return CreateStreamingJWT(0, fileLocator)
+	}
+	if claims.ShareToken != "" {
+		link, err := db.FindShareLinkByToken(claims.ShareToken)
+		if err != nil {
+			return "", err
+		}
+		return CreateShareStreamingJWT(link, claims.GuestID, fileLocator)
+	}
+	return CreateStreamingJWT(claims.UserID, fileLocator)
+}
+
+func createStreamingJWT(
+	userID uint,
+	fileLocator string,
+	shareToken string,
+	guestID string,
+	expiresAt time.Time) (string, error) {
+
+	jti := uuid.New().String()
 
 	claims := StreamingClaims{
 		userID,
 		fileLocator,
-		jwt.StandardClaims{ExpiresAt: expiresAt, Issuer: "bss"},
+		shareToken,
+		guestID,
+		jwt.StandardClaims{ExpiresAt: expiresAt.Unix(), Issuer: "bss", Id: jti},
 	}
 
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -35,6 +87,18 @@ func CreateStreamingJWT(userID uint, fileLocator string) (string, error) {
 		return "", err
 	}
 
+	if userID != 0 {
+		err = db.CreateStreamingTicket(&db.StreamingTicket{
+			JTI:       jti,
+			UserID:    userID,
+			FilePath:  fileLocator,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
 	return ss, nil
 }
 
@@ -45,12 +109,38 @@ func ValidateStreamingJWT(tokenStr string) (*StreamingClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*StreamingClaims); ok && token.Valid {
-		log.WithFields(log.Fields{"user": claims.UserID, "file": claims.FilePath, "expires": claims.ExpiresAt}).Debugf("Validate streaming ticket")
-		return claims, nil
+	// Download and clip JWTs are signed with the same secret, but only streaming tickets have no
+	// audience and carry a file path.
+	claims, ok := token.Claims.(*StreamingClaims)
+	if !ok || !token.Valid || claims.Audience != "" || claims.FilePath == "" {
+		return nil, fmt.Errorf("could not validate ticket")
+	}
+
+	if claims.UserID != 0 {
+		ticket, err := db.FindStreamingTicketByJTI(claims.Id)
+		if err != nil || ticket.UserID != claims.UserID {
+			return nil, fmt.Errorf("unknown ticket")
+		}
+		if ticket.Revoked {
+			return nil, fmt.Errorf("ticket was revoked")
+		}
+	}
+
+	if claims.ShareToken != "" {
+		if claims.GuestID == "" {
+			return nil, fmt.Errorf("share link ticket without guest")
+		}
+		link, err := db.FindShareLinkByToken(claims.ShareToken)
+		if err != nil {
+			return nil, fmt.Errorf("unknown share link")
+		}
+		if err := link.Usable(); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil, fmt.Errorf("could not validate ticket")
+	log.WithFields(log.Fields{"user": claims.UserID, "file": claims.FilePath, "expires": claims.ExpiresAt}).Debugf("Validate streaming ticket")
+	return claims, nil
 }
 
 func jwtSecretFunc(token *jwt.Token) (interface{}, error) {
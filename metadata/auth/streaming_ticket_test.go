@@ -2,10 +2,15 @@ package auth
 
 import (
 	"fmt"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 	"testing"
+	"time"
 )
 
 func TestStreamingTicket(t *testing.T) {
+	dbc := db.NewDb(db.InMemory, false)
+	defer dbc.Close()
+
 	path := "/users/maran/does/not/exist.mkv"
 	secret, err := tokenSecret()
 	if err != nil {
@@ -37,3 +42,131 @@ func TestStreamingTicket(t *testing.T) {
 	}
 
 }
+
+func TestDownloadTicketIsNoStreamingTicket(t *testing.T) {
+	dbc := db.NewDb(db.InMemory, false)
+	defer dbc.Close()
+
+	token, err := CreateDownloadJWT(1, "some-uuid", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got error instead: %s", err)
+	}
+
+	claims, err := ValidateDownloadJWT(token)
+	if err != nil {
+		t.Fatalf("Could not validate created token: %s", err)
+	}
+	if claims.DownloadUUID != "some-uuid" || claims.UserID != 1 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := ValidateStreamingJWT(token); err == nil {
+		t.Errorf("Download ticket was accepted as a streaming ticket")
+	}
+
+	streamingToken, _ := CreateStreamingJWT(1, "/does/not/exist.mkv")
+	if _, err := ValidateDownloadJWT(streamingToken); err == nil {
+		t.Errorf("Streaming ticket was accepted as a download ticket")
+	}
+}
+
+func TestClipTicketIsNoStreamingTicket(t *testing.T) {
+	dbc := db.NewDb(db.InMemory, false)
+	defer dbc.Close()
+
+	token, err := CreateClipJWT("some-uuid", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got error instead: %s", err)
+	}
+	if _, err := ValidateStreamingJWT(token); err == nil {
+		t.Errorf("Clip ticket was accepted as a streaming ticket")
+	}
+
+	// Tickets without a user aren't looked up in the database, so only their claims can tell.
+	downloadToken, _ := CreateDownloadJWT(0, "some-uuid", time.Now().Add(time.Hour))
+	if _, err := ValidateStreamingJWT(downloadToken); err == nil {
+		t.Errorf("Download ticket was accepted as a streaming ticket")
+	}
+	if token, _ := CreateStreamingJWT(0, ""); token != "" {
+		if _, err := ValidateStreamingJWT(token); err == nil {
+			t.Errorf("Streaming ticket without a file was accepted")
+		}
+	}
+}
+
+func TestRevokeStreamingTicket(t *testing.T) {
+	dbc := db.NewDb(db.InMemory, false)
+	defer dbc.Close()
+
+	user, _ := db.CreateUser("revoked", "testtest", false)
+	token, _ := CreateStreamingJWT(user.ID, "/does/not/exist.mkv")
+	other, _ := CreateStreamingJWT(user.ID, "/does/not/exist.mkv")
+
+	claims, err := ValidateStreamingJWT(token)
+	if err != nil {
+		t.Fatalf("Could not validate created token: %s", err)
+	}
+
+	db.RevokeStreamingTicket(claims.Id)
+	if _, err := ValidateStreamingJWT(token); err == nil {
+		t.Errorf("Revoked ticket was accepted")
+	}
+	if _, err := ValidateStreamingJWT(other); err != nil {
+		t.Errorf("Ticket was revoked along with another one: %s", err)
+	}
+
+	db.DeleteUser(user.ID)
+	if _, err := ValidateStreamingJWT(other); err == nil {
+		t.Errorf("Ticket of deleted user was accepted")
+	}
+}
+
+func TestShareStreamingTicket(t *testing.T) {
+	dbc := db.NewDb(db.InMemory, false)
+	defer dbc.Close()
+
+	user, _ := db.CreateUser("sharer", "testtest", false)
+	link := &db.ShareLink{
+		Token:     "share-token",
+		MediaUUID: "some-uuid",
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+		MaxPlays:  1,
+	}
+	db.CreateShareLink(link)
+
+	token, err := CreateShareStreamingJWT(link, "guest", "/does/not/exist.mkv")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got error instead: %s", err)
+	}
+	claims, err := ValidateStreamingJWT(token)
+	if err != nil {
+		t.Fatalf("Could not validate created token: %s", err)
+	}
+	if claims.ShareToken != "share-token" || claims.GuestID != "guest" || claims.ExpiresAt > link.ExpiresAt.Unix() {
+		t.Errorf("Share ticket is not restricted to the link: %+v", claims)
+	}
+
+	// Tickets for subtitles etc. inherit the restrictions.
+	derived, _ := CreateDerivedStreamingJWT(claims, "/does/not/exist.srt")
+	if derivedClaims, err := ValidateStreamingJWT(derived); err != nil || derivedClaims.ShareToken != "share-token" || derivedClaims.GuestID != "guest" {
+		t.Errorf("Derived ticket lost its share link: %+v, %v", derivedClaims, err)
+	}
+
+	withoutGuest, _ := createStreamingJWT(user.ID, "/does/not/exist.mkv", link.Token, "", link.ExpiresAt)
+	if _, err := ValidateStreamingJWT(withoutGuest); err == nil {
+		t.Errorf("Share ticket without a guest was accepted")
+	}
+
+	if _, err := db.UseShareLink("share-token"); err != nil {
+		t.Errorf("Expected the first play to be allowed: %s", err)
+	}
+	if _, err := db.UseShareLink("share-token"); err == nil {
+		t.Errorf("Expected no plays to be left")
+	}
+
+	db.RevokeShareLink("share-token")
+	if _, err := ValidateStreamingJWT(token); err == nil {
+		t.Errorf("Ticket of a revoked share link was accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+// Clip is a short part of a movie or episode cut into a standalone MP4 file to share it.
+type Clip struct {
+	gorm.Model
+	UUIDable
+	UserID uint
+
+	// MediaFileUUID is the UUID of the MovieFile or EpisodeFile the clip is cut from.
+	MediaFileUUID string
+	FileName      string
+
+	// Start and End of the clip in seconds.
+	Start float64
+	End   float64
+	// SubtitleStreamID is the ffmpeg stream ID of the subtitle stream burned into the clip, -1
+	// for none.
+	SubtitleStreamID int64
+
+	// FilePath is the local path of the clip, empty until it is done.
+	FilePath  string
+	Size      int64
+	ExpiresAt time.Time
+
+	State string
+	// Progress of the transcoding job in percent.
+	Progress float64
+	Error    string
+}
+
+// CreateClip persists a new Clip in the database.
+func CreateClip(clip *Clip) error {
+	return db.Create(clip).Error
+}
+
+// FindClipByUUID finds the Clip with the given UUID.
+func FindClipByUUID(uuid string) (*Clip, error) {
+	var c Clip
+	if err := db.Take(&c, "uuid = ?", uuid).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindClipsForUser returns all clips of the given user, newest first.
+func FindClipsForUser(userID uint) (clips []Clip) {
+	db.Where("user_id = ?", userID).Order("created_at DESC").Find(&clips)
+	return clips
+}
+
+// FindClipsInState returns all clips in the given state, oldest first.
+func FindClipsInState(state string) (clips []Clip) {
+	db.Where("state = ?", state).Order("created_at ASC").Find(&clips)
+	return clips
+}
+
+// FindExpiredClips returns all finished clips that expired before the given time.
+func FindExpiredClips(before time.Time) (clips []Clip) {
+	db.Where("state = ? AND expires_at < ?", JobStateDone, before).Find(&clips)
+	return clips
+}
+
+// CountActiveClipsForUser counts the clips of the given user that are queued, being transcoded
+// or done, i.e. that count towards their quota.
+func CountActiveClipsForUser(userID uint) int {
+	count := 0
+	db.Model(&Clip{}).
+		Where("user_id = ? AND state IN (?)", userID,
+			[]string{JobStateQueued, JobStateTranscoding, JobStateDone}).
+		Count(&count)
+	return count
+}
+
+// UpdateClipProgress only updates the progress of the given Clip so that a concurrent deletion
+// is not overwritten.
+func UpdateClipProgress(c *Clip, progress float64) error {
+	return db.Model(c).UpdateColumn("progress", progress).Error
+}
+
+// SaveClip saves a Clip.
+func SaveClip(c *Clip) error {
+	return db.Save(c).Error
+}
+
+// DeleteClip removes the clip and its file.
+func DeleteClip(c *Clip) {
+	if c.FilePath != "" {
+		if err := os.Remove(c.FilePath); err != nil && !os.IsNotExist(err) {
+			log.WithFields(log.Fields{"error": err, "path": c.FilePath}).
+				Warnln("Failed to remove clip")
+		}
+	}
+	db.Unscoped().Delete(c)
+}
@@ -0,0 +1,34 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestClips(t *testing.T) {
+	defer setupTest(t)()
+
+	expired := db.Clip{UserID: 1, State: db.JobStateDone, ExpiresAt: time.Now().Add(-time.Hour)}
+	valid := db.Clip{UserID: 1, State: db.JobStateDone, ExpiresAt: time.Now().Add(time.Hour)}
+	failed := db.Clip{UserID: 1, State: db.JobStateFailed}
+	queued := db.Clip{UserID: 2, State: db.JobStateQueued}
+	db.CreateClip(&expired)
+	db.CreateClip(&valid)
+	db.CreateClip(&failed)
+	db.CreateClip(&queued)
+
+	clips := db.FindExpiredClips(time.Now())
+	if assert.Len(t, clips, 1) {
+		assert.Equal(t, expired.UUID, clips[0].UUID)
+	}
+
+	assert.Equal(t, 2, db.CountActiveClipsForUser(1))
+	assert.Equal(t, 1, db.CountActiveClipsForUser(2))
+
+	assert.Len(t, db.FindClipsForUser(1), 3)
+	db.DeleteClip(&expired)
+	assert.Len(t, db.FindClipsForUser(1), 2)
+	assert.Equal(t, 1, db.CountActiveClipsForUser(1))
+}
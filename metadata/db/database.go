@@ -54,7 +54,9 @@ func NewDb(dbPath string, dbLogMode bool) *gorm.DB {
 
 var allModels = []interface{}{
 	&Movie{}, &MovieFile{}, &Library{}, &Series{}, &Season{}, &Episode{},
-	&EpisodeFile{}, &User{}, &Invite{}, &PlayState{}, &Stream{},
+	&EpisodeFile{}, &User{}, &Invite{}, &PlayState{}, &Stream{}, &OptimizedVersion{},
+	&Download{}, &StreamingTicket{}, &ShareLink{}, &Clip{}, &SeenFile{}, &LibrarySettings{},
+	&LibraryRoot{},
 }
 
 func initSchema(tx *gorm.DB) error {
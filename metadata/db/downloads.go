@@ -0,0 +1,123 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Download is a file transcoded for a user to take offline.
+type Download struct {
+	gorm.Model
+	UUIDable
+	UserID uint
+
+	// MediaFileUUID is the UUID of the MovieFile or EpisodeFile that is transcoded.
+	MediaFileUUID string
+	FileName      string
+
+	// Preset is the name of the video encoder preset, e.g. "720-5000k-video".
+	Preset string
+	// AudioStreamIDs and SubtitleStreamIDs are comma-separated lists of ffmpeg stream IDs to
+	// include in the download.
+	AudioStreamIDs    string
+	SubtitleStreamIDs string
+
+	// FilePath is the local path of the transcoded file, empty until it is done.
+	FilePath  string
+	Size      int64
+	ExpiresAt time.Time
+
+	State string
+	// Progress of the transcoding job in percent.
+	Progress float64
+	Error    string
+}
+
+// StreamIDsToString serializes a list of stream IDs for storage in a Download.
+func StreamIDsToString(ids []int64) string {
+	strs := []string{}
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(strs, ",")
+}
+
+// StreamIDsFromString parses a list of stream IDs stored in a Download.
+func StreamIDsFromString(str string) []int64 {
+	ids := []int64{}
+	for _, s := range strings.Split(str, ",") {
+		if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CreateDownload persists a new Download in the database.
+func CreateDownload(download *Download) error {
+	return db.Create(download).Error
+}
+
+// FindDownloadByUUID finds the Download with the given UUID.
+func FindDownloadByUUID(uuid string) (*Download, error) {
+	var d Download
+	if err := db.Take(&d, "uuid = ?", uuid).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FindDownloadsForUser returns all downloads of the given user, newest first.
+func FindDownloadsForUser(userID uint) (downloads []Download) {
+	db.Where("user_id = ?", userID).Order("created_at DESC").Find(&downloads)
+	return downloads
+}
+
+// CountPendingDownloadsForUser counts the downloads of the given user that are queued or being
+// transcoded.
+func CountPendingDownloadsForUser(userID uint) int {
+	count := 0
+	db.Model(&Download{}).
+		Where("user_id = ? AND state IN (?)", userID,
+			[]string{JobStateQueued, JobStateTranscoding}).
+		Count(&count)
+	return count
+}
+
+// FindDownloadsInState returns all downloads in the given state, oldest first.
+func FindDownloadsInState(state string) (downloads []Download) {
+	db.Where("state = ?", state).Order("created_at ASC").Find(&downloads)
+	return downloads
+}
+
+// FindExpiredDownloads returns all finished downloads that expired before the given time.
+func FindExpiredDownloads(before time.Time) (downloads []Download) {
+	db.Where("state = ? AND expires_at < ?", JobStateDone, before).Find(&downloads)
+	return downloads
+}
+
+// UpdateDownloadProgress only updates the progress of the given Download so that a concurrent
+// change of its state, e.g. a cancellation, is not overwritten.
+func UpdateDownloadProgress(d *Download, progress float64) error {
+	return db.Model(d).UpdateColumn("progress", progress).Error
+}
+
+// SaveDownload saves a Download.
+func SaveDownload(d *Download) error {
+	return db.Save(d).Error
+}
+
+// DeleteDownload removes the download and its file.
+func DeleteDownload(d *Download) {
+	if d.FilePath != "" {
+		if err := os.Remove(d.FilePath); err != nil && !os.IsNotExist(err) {
+			log.WithFields(log.Fields{"error": err, "path": d.FilePath}).
+				Warnln("Failed to remove download")
+		}
+	}
+	db.Unscoped().Delete(d)
+}
@@ -0,0 +1,38 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestStreamIDsString(t *testing.T) {
+	assert.Equal(t, "", db.StreamIDsToString(nil))
+	assert.Equal(t, "1,3", db.StreamIDsToString([]int64{1, 3}))
+	assert.Equal(t, []int64{}, db.StreamIDsFromString(""))
+	assert.Equal(t, []int64{1, 3}, db.StreamIDsFromString("1,3"))
+}
+
+func TestFindExpiredDownloads(t *testing.T) {
+	defer setupTest(t)()
+
+	expired := db.Download{UserID: 1, State: db.JobStateDone, ExpiresAt: time.Now().Add(-time.Hour)}
+	valid := db.Download{UserID: 1, State: db.JobStateDone, ExpiresAt: time.Now().Add(time.Hour)}
+	queued := db.Download{UserID: 2, State: db.JobStateQueued}
+	db.CreateDownload(&expired)
+	db.CreateDownload(&valid)
+	db.CreateDownload(&queued)
+
+	downloads := db.FindExpiredDownloads(time.Now())
+	if assert.Len(t, downloads, 1) {
+		assert.Equal(t, expired.UUID, downloads[0].UUID)
+	}
+
+	assert.Len(t, db.FindDownloadsForUser(1), 2)
+	db.DeleteDownload(&expired)
+	assert.Len(t, db.FindDownloadsForUser(1), 1)
+
+	assert.Equal(t, 0, db.CountPendingDownloadsForUser(1))
+	assert.Equal(t, 1, db.CountPendingDownloadsForUser(2))
+}
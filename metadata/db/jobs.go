@@ -0,0 +1,10 @@
+package db
+
+// States of background transcoding jobs such as OptimizedVersions and Downloads.
+const (
+	JobStateQueued      = "queued"
+	JobStateTranscoding = "transcoding"
+	JobStateDone        = "done"
+	JobStateFailed      = "failed"
+	JobStateCancelled   = "cancelled"
+)
@@ -4,9 +4,6 @@ import (
 	"fmt"
 	"github.com/jinzhu/gorm"
 	log "github.com/sirupsen/logrus"
-	"gitlab.com/olaris/olaris-server/filesystem"
-	"gitlab.com/olaris/olaris-server/helpers"
-	"path"
 	"time"
 )
 
@@ -36,6 +33,15 @@ type Library struct {
 	Healthy            bool `gorm:"default:'1'"`
 	RefreshStartedAt   time.Time
 	RefreshCompletedAt time.Time
+
+	// ExcludePatterns are patterns in .olarisignore syntax, one per line, of paths in the library
+	// that are not scanned.
+	ExcludePatterns string
+	// Extensions is a comma-separated list of the file extensions that are scanned, e.g.
+	// ".mkv,.mp4". Empty means the default list.
+	Extensions string
+	// MinFileSize is the size in bytes below which files are not scanned. 0 means the default.
+	MinFileSize int64
 }
 
 // IsLocal returns true when a library is based on a local filesystem
@@ -80,6 +86,9 @@ func DeleteLibrary(id int) (Library, error) {
 			} else if library.Kind == MediaTypeSeries {
 				DeleteEpisodesFromLibrary(library.ID)
 			}
+			DeleteSeenFilesFromLibrary(library.ID)
+			DeleteLibrarySettings(library.ID)
+			DeleteLibraryRoots(library.ID)
 		}
 		return library, obj.Error
 	}
@@ -87,24 +96,19 @@ func DeleteLibrary(id int) (Library, error) {
 	return library, fmt.Errorf("library not found, could not be deleted")
 }
 
-// AddLibrary adds a filesystem folder and starts tracking media inside the folders.
+// AddLibrary adds a filesystem folder and starts tracking media inside the folders. The folder
+// becomes the first root of the library, see AddLibraryRoot for adding more.
 func AddLibrary(lib *Library) error {
-	if lib.Backend == BackendLocal && !helpers.FileExists(lib.FilePath) {
-		return fmt.Errorf("supplied library path does not exist")
+	root := rootOfLibrary(lib)
+	if err := checkRoot(&root); err != nil {
+		return err
 	}
 
-	if lib.Backend == BackendRclone {
-		if lib.RcloneName == "" {
-			return fmt.Errorf("backend is set to rclone but no Rclone name has been specified")
-		}
-
-		_, err := filesystem.RcloneNodeFromPath(path.Join(lib.RcloneName, lib.FilePath))
-		if err != nil {
-			return fmt.Errorf("could not find path on rclone remote or remote threw an error: '%s'", err)
-		}
+	log.WithFields(log.Fields{"name": lib.Name, "path": lib.FilePath, "kind": lib.Kind}).Infoln("Adding library")
+	if err := db.Create(&lib).Error; err != nil {
+		return err
 	}
 
-	log.WithFields(log.Fields{"name": lib.Name, "path": lib.FilePath, "kind": lib.Kind}).Infoln("Adding library")
-	dbObj := db.Create(&lib)
-	return dbObj.Error
+	root.LibraryID = lib.ID
+	return db.Create(&root).Error
 }
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LibraryRoot is one of the directories whose files make up a library. The roots of a library can
+// be on different backends, e.g. a local disk and an rclone remote.
+type LibraryRoot struct {
+	gorm.Model
+	LibraryID  uint   `gorm:"index"`
+	Backend    int    `gorm:"unique_index:idx_library_root"`
+	RcloneName string `gorm:"unique_index:idx_library_root"`
+	FilePath   string `gorm:"unique_index:idx_library_root"`
+	Healthy    bool   `gorm:"default:'1'"`
+}
+
+// LogFields defines some standard fields to include in logs.
+func (root *LibraryRoot) LogFields() log.Fields {
+	return log.Fields{"libraryID": root.LibraryID, "path": root.FilePath, "backend": root.Backend, "rcloneName": root.RcloneName}
+}
+
+// IsLocal returns true when the root is on a local filesystem
+func (root *LibraryRoot) IsLocal() bool {
+	return root.Backend == BackendLocal
+}
+
+// IsRclone returns true when the root is on a rclone remote
+func (root *LibraryRoot) IsRclone() bool {
+	return root.Backend == BackendRclone
+}
+
+// FileLocator returns the locator of the root directory.
+func (root *LibraryRoot) FileLocator() filesystem.FileLocator {
+	if root.IsRclone() {
+		return filesystem.FileLocator{
+			Backend: filesystem.BackendRclone,
+			Path:    path.Join("/", root.RcloneName, root.FilePath),
+		}
+	}
+	return filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: filepath.Clean(root.FilePath)}
+}
+
+// Contains returns whether the given file locator is the root directory or below it.
+func (root *LibraryRoot) Contains(fileLocator filesystem.FileLocator) bool {
+	rootLocator := root.FileLocator()
+	if rootLocator.Backend != fileLocator.Backend {
+		return false
+	}
+	return fileLocator.Path == rootLocator.Path ||
+		strings.HasPrefix(fileLocator.Path, strings.TrimSuffix(rootLocator.Path, "/")+"/")
+}
+
+// Overlaps returns whether one of the roots contains the other.
+func (root *LibraryRoot) Overlaps(other *LibraryRoot) bool {
+	return root.Contains(other.FileLocator()) || other.Contains(root.FileLocator())
+}
+
+// rootOfLibrary returns the root given by the library's own FilePath, Backend and RcloneName.
+func rootOfLibrary(lib *Library) LibraryRoot {
+	return LibraryRoot{
+		LibraryID:  lib.ID,
+		Backend:    lib.Backend,
+		RcloneName: lib.RcloneName,
+		FilePath:   lib.FilePath,
+		Healthy:    lib.Healthy,
+	}
+}
+
+// checkRoot returns an error if the given root can't be accessed.
+func checkRoot(root *LibraryRoot) error {
+	switch root.Backend {
+	case BackendLocal:
+		if !helpers.FileExists(root.FilePath) {
+			return fmt.Errorf("supplied library path does not exist")
+		}
+	case BackendRclone:
+		if root.RcloneName == "" {
+			return fmt.Errorf("backend is set to rclone but no Rclone name has been specified")
+		}
+
+		_, err := filesystem.RcloneNodeFromPath(path.Join(root.RcloneName, root.FilePath))
+		if err != nil {
+			return fmt.Errorf("could not find path on rclone remote or remote threw an error: '%s'", err)
+		}
+	default:
+		return fmt.Errorf("unknown backend %d", root.Backend)
+	}
+	return nil
+}
+
+// FindLibraryRoots returns the roots of the given library, starting with the one the library was
+// created with. Libraries created before they could have several roots get their root stored here.
+func FindLibraryRoots(lib *Library) (roots []LibraryRoot) {
+	db.Where("library_id = ?", lib.ID).Order("id").Find(&roots)
+	if len(roots) == 0 && lib.ID != 0 {
+		root := rootOfLibrary(lib)
+		if err := db.Create(&root).Error; err != nil {
+			log.WithFields(root.LogFields()).WithField("error", err).
+				Warnln("Failed to store root of library")
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// FindLibraryRoot finds a library root by its ID.
+func FindLibraryRoot(id uint) (*LibraryRoot, error) {
+	var root LibraryRoot
+	if err := db.Take(&root, id).Error; err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// SaveLibraryRoot persists a library root in the database.
+func SaveLibraryRoot(root *LibraryRoot) error {
+	return db.Save(root).Error
+}
+
+// AddLibraryRoot adds another root directory to a library.
+func AddLibraryRoot(root *LibraryRoot) error {
+	lib := FindLibrary(int(root.LibraryID))
+	if lib.ID == 0 {
+		return fmt.Errorf("library not found")
+	}
+	if err := checkRoot(root); err != nil {
+		return err
+	}
+	for _, other := range FindLibraryRoots(&lib) {
+		if other.Overlaps(root) {
+			return fmt.Errorf("library root overlaps with existing root '%s'", other.FileLocator())
+		}
+	}
+
+	log.WithFields(root.LogFields()).Infoln("Adding library root")
+	return db.Create(root).Error
+}
+
+// DeleteLibraryRoot removes a root from its library. The files in it are moved to the trash, so
+// that their metadata and play states are kept if the root is added again before they are purged.
+// The last root of a library can't be removed, the library has to be deleted instead.
+func DeleteLibraryRoot(root *LibraryRoot) error {
+	lib := FindLibrary(int(root.LibraryID))
+	var remaining []LibraryRoot
+	for _, other := range FindLibraryRoots(&lib) {
+		if other.ID != root.ID {
+			remaining = append(remaining, other)
+		}
+	}
+	if len(remaining) == 0 {
+		return fmt.Errorf("can't remove the only root of a library")
+	}
+
+	if err := db.Unscoped().Delete(root).Error; err != nil {
+		return err
+	}
+
+	fileLocator := root.FileLocator().String()
+	movieFiles := []MovieFile{}
+	db.Scopes(inPath(fileLocator)).Where("library_id = ?", lib.ID).Find(&movieFiles)
+	for _, file := range movieFiles {
+		if err := TrashMediaFile(file); err != nil {
+			return err
+		}
+	}
+	episodeFiles := []EpisodeFile{}
+	db.Scopes(inPath(fileLocator)).Where("library_id = ?", lib.ID).Find(&episodeFiles)
+	for _, file := range episodeFiles {
+		if err := TrashMediaFile(file); err != nil {
+			return err
+		}
+	}
+	db.Unscoped().Scopes(inPath(fileLocator)).Where("library_id = ?", lib.ID).Delete(SeenFile{})
+
+	// The library's own fields always describe one of its roots.
+	if own := rootOfLibrary(&lib); own.FileLocator() == root.FileLocator() {
+		lib.Backend = remaining[0].Backend
+		lib.RcloneName = remaining[0].RcloneName
+		lib.FilePath = remaining[0].FilePath
+		return db.Save(&lib).Error
+	}
+	return nil
+}
+
+// DeleteLibraryRoots deletes all roots of the given library.
+func DeleteLibraryRoots(libraryID uint) {
+	db.Unscoped().Where("library_id = ?", libraryID).Delete(LibraryRoot{})
+}
@@ -0,0 +1,77 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLibraryRoots(t *testing.T) {
+	defer setupTest(t)()
+
+	dir, err := ioutil.TempDir("", "olaris-roots-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	disk1 := filepath.Join(dir, "disk_1")
+	disk2 := filepath.Join(dir, "disk2")
+	assert.NoError(t, os.MkdirAll(filepath.Join(disk1, "Movies"), 0755))
+	assert.NoError(t, os.MkdirAll(disk2, 0755))
+
+	lib := db.Library{Name: "Movies", FilePath: disk1, Kind: db.MediaTypeMovie}
+	assert.NoError(t, db.AddLibrary(&lib))
+	roots := db.FindLibraryRoots(&lib)
+	if assert.Len(t, roots, 1) {
+		assert.Equal(t, disk1, roots[0].FilePath)
+	}
+
+	assert.NoError(t, db.AddLibraryRoot(&db.LibraryRoot{LibraryID: lib.ID, FilePath: disk2}))
+	assert.Error(t, db.AddLibraryRoot(&db.LibraryRoot{LibraryID: lib.ID, FilePath: filepath.Join(disk1, "Movies")}))
+	assert.Error(t, db.AddLibraryRoot(&db.LibraryRoot{LibraryID: lib.ID, FilePath: filepath.Join(dir, "disk3")}))
+	roots = db.FindLibraryRoots(&lib)
+	assert.Len(t, roots, 2)
+
+	movie := db.Movie{Title: "Heat", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#" + filepath.Join(disk1, "Heat.mkv"), LibraryID: lib.ID}},
+	}}
+	db.CreateMovie(&movie)
+	// Files in directories that only match the root's path as a LIKE pattern stay.
+	other := db.Movie{Title: "Ronin", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#" + filepath.Join(dir, "disk-1", "Ronin.mkv"), LibraryID: lib.ID}},
+	}}
+	db.CreateMovie(&other)
+
+	// Removing the root the library was created with moves the library's path to the other one
+	// and takes the files in it along to the trash.
+	assert.NoError(t, db.DeleteLibraryRoot(&roots[0]))
+	if files := db.FindMovieFilesInLibrary(lib.ID); assert.Len(t, files, 1) {
+		assert.Equal(t, other.MovieFiles[0].FilePath, files[0].FilePath)
+	}
+	if missing := db.FindMissingMediaFiles(); assert.Len(t, missing, 1) {
+		assert.Equal(t, "local#"+filepath.Join(disk1, "Heat.mkv"), missing[0].GetFilePath())
+	}
+	lib = db.FindLibrary(int(lib.ID))
+	assert.Equal(t, disk2, lib.FilePath)
+	assert.Error(t, db.DeleteLibraryRoot(&roots[1]))
+
+	_, err = db.DeleteLibrary(int(lib.ID))
+	assert.NoError(t, err)
+	_, err = db.FindLibraryRoot(roots[1].ID)
+	assert.Error(t, err)
+}
+
+func TestLibraryRootContains(t *testing.T) {
+	local := db.LibraryRoot{FilePath: "/mnt/disk1/"}
+	rclone := db.LibraryRoot{Backend: db.BackendRclone, RcloneName: "drive", FilePath: "Movies"}
+
+	assert.True(t, local.Contains(local.FileLocator()))
+	assert.True(t, local.Contains(filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: "/mnt/disk1/Heat/Heat.mkv"}))
+	assert.False(t, local.Contains(filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: "/mnt/disk10/Heat.mkv"}))
+	assert.False(t, local.Contains(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: "/mnt/disk1/Heat.mkv"}))
+	assert.True(t, rclone.Contains(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: "/drive/Movies/Heat.mkv"}))
+	assert.False(t, rclone.Contains(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: "/other/Movies/Heat.mkv"}))
+	assert.False(t, rclone.Overlaps(&local))
+}
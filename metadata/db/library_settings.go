@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"time"
+)
+
+const (
+	// AgentTmdb identifies files and retrieves their metadata from themoviedb.
+	AgentTmdb = "tmdb"
+	// AgentNone doesn't retrieve any metadata, files stay unidentified until they are identified
+	// by hand.
+	AgentNone = "none"
+)
+
+// DefaultProbeConcurrency is how many files of a library are probed at the same time by default.
+const DefaultProbeConcurrency = 4
+
+// LibrarySettings holds the settings of a library that control how its files are scanned and
+// identified.
+type LibrarySettings struct {
+	gorm.Model
+	LibraryID uint `gorm:"unique_index:idx_library_settings_library_id"`
+
+	// MetadataLanguage is the ISO 639-1 code of the language metadata is retrieved in, e.g. "de".
+	// Empty means the agent's default.
+	MetadataLanguage string
+	// MetadataRegion is the ISO 3166-1 code of the country whose release information is
+	// preferred, e.g. "DE". Empty means the agent's default.
+	MetadataRegion string
+	// Agent is the metadata agent files are identified with.
+	Agent string
+	// RescanInterval is how often the library is rescanned automatically, 0 means never.
+	RescanInterval time.Duration
+
+	// VFSCacheMode, VFSCacheMaxSize and VFSChunkSize are the options of the rclone VFS, see
+	// filesystem.VFSOptions. They are ignored for local libraries.
+	VFSCacheMode    string
+	VFSCacheMaxSize int64
+	VFSChunkSize    int64
+
+	// ProbeConcurrency is how many files are probed at the same time.
+	ProbeConcurrency int
+}
+
+// DefaultLibrarySettings returns the settings of a library that hasn't changed any.
+func DefaultLibrarySettings(libraryID uint) *LibrarySettings {
+	return &LibrarySettings{
+		LibraryID:        libraryID,
+		Agent:            AgentTmdb,
+		VFSCacheMode:     filesystem.DefaultVFSOptions.CacheMode,
+		VFSCacheMaxSize:  filesystem.DefaultVFSOptions.CacheMaxSize,
+		VFSChunkSize:     filesystem.DefaultVFSOptions.ChunkSize,
+		ProbeConcurrency: DefaultProbeConcurrency,
+	}
+}
+
+// VFSOptions returns the rclone VFS options of the library.
+func (s *LibrarySettings) VFSOptions() filesystem.VFSOptions {
+	return filesystem.VFSOptions{
+		CacheMode:    s.VFSCacheMode,
+		CacheMaxSize: s.VFSCacheMaxSize,
+		ChunkSize:    s.VFSChunkSize,
+	}
+}
+
+// Validate returns an error if one of the settings has an invalid value.
+func (s *LibrarySettings) Validate() error {
+	if s.Agent != AgentTmdb && s.Agent != AgentNone {
+		return fmt.Errorf("unknown metadata agent '%s'", s.Agent)
+	}
+	if len(s.MetadataLanguage) != 0 && len(s.MetadataLanguage) != 2 {
+		return fmt.Errorf("metadata language '%s' is not a two-letter ISO 639-1 code", s.MetadataLanguage)
+	}
+	if len(s.MetadataRegion) != 0 && len(s.MetadataRegion) != 2 {
+		return fmt.Errorf("metadata region '%s' is not a two-letter ISO 3166-1 code", s.MetadataRegion)
+	}
+	if s.RescanInterval < 0 {
+		return fmt.Errorf("rescan interval can't be negative")
+	}
+	if s.RescanInterval > 0 && s.RescanInterval < time.Minute {
+		return fmt.Errorf("rescan interval has to be at least a minute")
+	}
+	if s.ProbeConcurrency < 1 {
+		return fmt.Errorf("probe concurrency has to be at least 1")
+	}
+	return s.VFSOptions().Validate()
+}
+
+// FindLibrarySettings returns the settings of the given library, or the default settings if it
+// doesn't have any yet.
+func FindLibrarySettings(libraryID uint) *LibrarySettings {
+	settings := LibrarySettings{}
+	if db.Where("library_id = ?", libraryID).Take(&settings).RecordNotFound() {
+		return DefaultLibrarySettings(libraryID)
+	}
+	return &settings
+}
+
+// SaveLibrarySettings validates and persists the settings of a library.
+func SaveLibrarySettings(settings *LibrarySettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	return db.Save(settings).Error
+}
+
+// DeleteLibrarySettings deletes the settings of the given library.
+func DeleteLibrarySettings(libraryID uint) {
+	db.Unscoped().Where("library_id = ?", libraryID).Delete(LibrarySettings{})
+}
@@ -0,0 +1,47 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestLibrarySettings(t *testing.T) {
+	defer setupTest(t)()
+
+	settings := db.FindLibrarySettings(1)
+	assert.Equal(t, db.DefaultLibrarySettings(1), settings)
+	assert.NoError(t, settings.Validate())
+
+	settings.MetadataLanguage = "de"
+	settings.MetadataRegion = "AT"
+	settings.RescanInterval = 6 * time.Hour
+	settings.VFSCacheMode = "full"
+	settings.ProbeConcurrency = 2
+	assert.NoError(t, db.SaveLibrarySettings(settings))
+
+	saved := db.FindLibrarySettings(1)
+	assert.Equal(t, "de", saved.MetadataLanguage)
+	assert.Equal(t, "AT", saved.MetadataRegion)
+	assert.Equal(t, 6*time.Hour, saved.RescanInterval)
+	assert.Equal(t, "full", saved.VFSCacheMode)
+	assert.Equal(t, 2, saved.ProbeConcurrency)
+	assert.Equal(t, db.DefaultLibrarySettings(2), db.FindLibrarySettings(2))
+
+	for _, invalid := range []func(s *db.LibrarySettings){
+		func(s *db.LibrarySettings) { s.Agent = "imdb" },
+		func(s *db.LibrarySettings) { s.MetadataLanguage = "german" },
+		func(s *db.LibrarySettings) { s.RescanInterval = time.Second },
+		func(s *db.LibrarySettings) { s.VFSCacheMode = "everything" },
+		func(s *db.LibrarySettings) { s.ProbeConcurrency = 0 },
+	} {
+		s := *saved
+		invalid(&s)
+		assert.Error(t, db.SaveLibrarySettings(&s))
+	}
+	assert.Equal(t, "de", db.FindLibrarySettings(1).MetadataLanguage)
+
+	db.DeleteLibrarySettings(1)
+	assert.Equal(t, db.DefaultLibrarySettings(1), db.FindLibrarySettings(1))
+}
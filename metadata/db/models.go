@@ -4,6 +4,13 @@ import (
 	"fmt"
 	"github.com/jinzhu/gorm"
 	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Defines various mediatypes, only Movie and Series support atm.
@@ -50,6 +57,9 @@ type MediaFile interface {
 	GetFileName() string
 	GetLibrary() *Library
 	GetStreams() []Stream
+	GetFingerprint() string
+	// GetMissingSince returns when the file went missing, or nil if it is available.
+	GetMissingSince() *time.Time
 	DeleteSelfAndMD()
 }
 
@@ -61,6 +71,26 @@ type MediaItem struct {
 	Size      int64
 	Library   Library
 	LibraryID uint
+	// FrameGrabPath is the ID of a frame grabbed from this file, served by the "local" image
+	// provider. It is used as fallback artwork when the metadata agent has none.
+	FrameGrabPath string
+	// FrameGrabFailed is set when no frame could be grabbed from this file, so that it isn't
+	// retried on every scan. It is reset when the file's content changes.
+	FrameGrabFailed bool
+	// Fingerprint identifies the file's content, see filesystem.Fingerprint. It is used to
+	// recognize the file when it is moved or renamed.
+	Fingerprint string `gorm:"index"`
+}
+
+// removeFrameGrab deletes the frame grabbed from this file from the image cache, if any.
+func (mi *MediaItem) removeFrameGrab() {
+	if mi.FrameGrabPath == "" {
+		return
+	}
+	if err := os.Remove(path.Join(helpers.LocalImagePath(), path.Base(mi.FrameGrabPath))); err != nil {
+		log.WithFields(log.Fields{"error": err, "path": mi.FrameGrabPath}).
+			Debugln("Failed to remove frame grab")
+	}
 }
 
 // FindContentByUUID can retrieve episode or movie data based on a UUID.
@@ -83,6 +113,153 @@ func FindContentByUUID(uuid string) MediaFile {
 	return nil
 }
 
+// inPath restricts a query to records whose file_path is the given file locator or below it if it
+// is a directory. Unlike LIKE, comparing the prefix is exact, so that neither wildcards in paths
+// nor their case can make it match e.g. "/disk-1/" for "/disk_1".
+func inPath(filePath string) func(*gorm.DB) *gorm.DB {
+	dirPrefix := strings.TrimSuffix(filePath, "/") + "/"
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("file_path = ? OR substr(file_path, 1, ?) = ?",
+			filePath, utf8.RuneCountInString(dirPrefix), dirPrefix)
+	}
+}
+
+// FindMediaFilesInPath returns the movie and episode files of the given library that are stored at
+// the given file locator or below it if it is a directory.
+func FindMediaFilesInPath(libraryID uint, filePath string) (files []MediaFile) {
+	var movieFiles []MovieFile
+	db.Scopes(inPath(filePath)).Where("library_id = ?", libraryID).
+		Preload("Library").Find(&movieFiles)
+	for _, f := range movieFiles {
+		files = append(files, f)
+	}
+
+	var episodeFiles []EpisodeFile
+	db.Scopes(inPath(filePath)).Where("library_id = ?", libraryID).
+		Preload("Library").Find(&episodeFiles)
+	for _, f := range episodeFiles {
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// FindMediaFileByPath returns the movie or episode file of the given library with exactly the
+// given file locator, including missing files, or nil if there is none.
+func FindMediaFileByPath(libraryID uint, filePath string) MediaFile {
+	var movieFile MovieFile
+	if err := db.Unscoped().Where("library_id = ? AND file_path = ?", libraryID, filePath).
+		Preload("Library").Take(&movieFile).Error; err == nil {
+		return movieFile
+	}
+
+	var episodeFile EpisodeFile
+	if err := db.Unscoped().Where("library_id = ? AND file_path = ?", libraryID, filePath).
+		Preload("Library").Take(&episodeFile).Error; err == nil {
+		return episodeFile
+	}
+
+	return nil
+}
+
+// FindMediaFilesByFingerprint returns the movie and episode files of the given library with the
+// given fingerprint.
+func FindMediaFilesByFingerprint(libraryID uint, fingerprint string) (files []MediaFile) {
+	var movieFiles []MovieFile
+	db.Where("library_id = ? AND fingerprint = ?", libraryID, fingerprint).
+		Preload("Library").Find(&movieFiles)
+	for _, f := range movieFiles {
+		files = append(files, f)
+	}
+
+	var episodeFiles []EpisodeFile
+	db.Where("library_id = ? AND fingerprint = ?", libraryID, fingerprint).
+		Preload("Library").Find(&episodeFiles)
+	for _, f := range episodeFiles {
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// MediaFileNeedsFingerprint returns whether there is a movie or episode file with the given path
+// in the library that has no fingerprint yet, e.g. because it was added by an older version.
+func MediaFileNeedsFingerprint(libraryID uint, filePath string) bool {
+	count := 0
+	db.Model(&MovieFile{}).
+		Where("library_id = ? AND file_path = ? AND fingerprint = ''", libraryID, filePath).Count(&count)
+	if count > 0 {
+		return true
+	}
+	db.Model(&EpisodeFile{}).
+		Where("library_id = ? AND file_path = ? AND fingerprint = ''", libraryID, filePath).Count(&count)
+	return count > 0
+}
+
+// SetMediaFileFingerprint stores the fingerprint of the movie or episode file with the given path.
+func SetMediaFileFingerprint(libraryID uint, filePath string, fingerprint string) error {
+	if err := db.Model(&MovieFile{}).
+		Where("library_id = ? AND file_path = ?", libraryID, filePath).
+		Update("fingerprint", fingerprint).Error; err != nil {
+		return err
+	}
+	return db.Model(&EpisodeFile{}).
+		Where("library_id = ? AND file_path = ?", libraryID, filePath).
+		Update("fingerprint", fingerprint).Error
+}
+
+// RelocateMediaFile points a movie or episode file that was moved or renamed to its new path. The
+// file keeps its UUID, so its metadata, play states and optimized versions are preserved. Its
+// streams are replaced since they may depend on the path, e.g. for external subtitles.
+func RelocateMediaFile(file MediaFile, filePath string, fileName string, streams []Stream) error {
+	log.WithFields(log.Fields{"from": file.GetFilePath(), "to": filePath}).Println("Relocating moved file")
+	return updateMediaFile(file, map[string]interface{}{"file_path": filePath, "file_name": fileName}, streams)
+}
+
+// UpdateMediaFileContent updates a movie or episode file whose content changed, e.g. because it was
+// replaced or was still being written when it was probed, with the result of probing it again.
+func UpdateMediaFileContent(file MediaFile, size int64, fingerprint string, streams []Stream) error {
+	log.WithFields(log.Fields{"path": file.GetFilePath()}).Println("Updating changed file")
+	return updateMediaFile(file, map[string]interface{}{"size": size, "fingerprint": fingerprint, "frame_grab_failed": false}, streams)
+}
+
+// updateMediaFile updates the given columns of a movie or episode file and replaces its streams.
+// Missing files are restored.
+func updateMediaFile(file MediaFile, columns map[string]interface{}, streams []Stream) error {
+	var model interface{}
+	var ownerID uint
+	var ownerType string
+
+	switch f := file.(type) {
+	case MovieFile:
+		model, ownerID, ownerType = &MovieFile{}, f.ID, "movie_files"
+	case EpisodeFile:
+		model, ownerID, ownerType = &EpisodeFile{}, f.ID, "episode_files"
+	default:
+		return fmt.Errorf("unsupported media file %T", file)
+	}
+
+	if err := db.Unscoped().Model(model).Where("id = ?", ownerID).Updates(columns).Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Delete(Stream{}, "owner_id = ? AND owner_type = ?", ownerID, ownerType).Error; err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		stream.OwnerID = ownerID
+		stream.OwnerType = ownerType
+		if err := db.Create(&stream).Error; err != nil {
+			return err
+		}
+	}
+
+	// The file is evidently there (again).
+	if file.GetMissingSince() != nil {
+		return RestoreMediaFile(file)
+	}
+	return nil
+}
+
 // RecentlyAddedMovies returns a list of the latest 10 movies added to the database.
 func RecentlyAddedMovies(userID uint) (movies []*Movie) {
 	db.Select("movies.*,play_states.*").Preload("MovieFiles.Streams").Joins("LEFT JOIN play_states ON play_states.media_uuid = movies.uuid").Where("play_states.user_id = ? OR play_states.user_id IS NULL", userID).Where("tmdb_id != 0").Order("created_at DESC").Limit(10).Find(&movies)
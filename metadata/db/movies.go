@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"strconv"
+	"time"
 )
 
 // MovieFile is used to store fileinformation about a movie.
@@ -37,7 +38,8 @@ func (movie *Movie) LogFields() log.Fields {
 // IsSingleFile returns true if this is the only file for this movie.
 func (file *MovieFile) IsSingleFile() bool {
 	count := 0
-	db.Model(&MovieFile{}).Where("movie_id = ?", file.MovieID).Count(&count)
+	// Files that are missing still count, the movie is kept for them until they are purged.
+	db.Unscoped().Model(&MovieFile{}).Where("movie_id = ?", file.MovieID).Count(&count)
 	if count <= 1 {
 		return true
 	}
@@ -54,6 +56,16 @@ func (file MovieFile) GetFilePath() string {
 	return file.FilePath
 }
 
+// GetFingerprint is a wrapper for the MediaFile interface
+func (file MovieFile) GetFingerprint() string {
+	return file.Fingerprint
+}
+
+// GetMissingSince is a wrapper for the MediaFile interface
+func (file MovieFile) GetMissingSince() *time.Time {
+	return file.DeletedAt
+}
+
 // GetLibrary is a wrapper for the MediaFile interface
 func (file MovieFile) GetLibrary() *Library {
 	var library Library
@@ -75,7 +87,7 @@ func (file MovieFile) DeleteSelfAndMD() {
 	// Delete all stream information since it's only for this file
 	db.Unscoped().Delete(Stream{}, "owner_id = ? AND owner_type = 'movies'", &file.ID)
 
-	db.Where("id = ?", file.MovieID).Find(&file.Movie)
+	db.Unscoped().Where("id = ?", file.MovieID).Find(&file.Movie)
 
 	if file.IsSingleFile() {
 		// TODO: Figure out if we can use gorm associations for this
@@ -87,6 +99,9 @@ func (file MovieFile) DeleteSelfAndMD() {
 		}
 	}
 
+	file.removeFrameGrab()
+	deleteOptimizedVersionsForFile(file)
+
 	// Delete all file information
 	db.Unscoped().Delete(&file)
 
@@ -216,7 +231,7 @@ func SearchMovieByTitle(name string) (movies []Movie) {
 // DeleteMoviesFromLibrary removes all movies from the given library.
 func DeleteMoviesFromLibrary(libraryID uint) {
 	files := []MovieFile{}
-	db.Where("library_id = ?", libraryID).Find(&files)
+	db.Unscoped().Where("library_id = ?", libraryID).Find(&files)
 	for _, file := range files {
 		file.DeleteSelfAndMD()
 	}
@@ -233,6 +248,38 @@ func FindMoviesInLibrary(libraryID uint) (movies []Movie) {
 
 }
 
+// FindLibraryIDForMovie returns the ID of the library the files of the given movie are in, 0 if
+// it has none.
+func FindLibraryIDForMovie(movieID uint) uint {
+	var file MovieFile
+	db.Select("library_id").Where("movie_id = ?", movieID).Take(&file)
+	return file.LibraryID
+}
+
+// FindMovieFilesWithoutArtwork finds all MovieFiles in the given library that have no frame grab
+// yet and that are either unidentified or belong to a Movie without a poster or backdrop.
+func FindMovieFilesWithoutArtwork(libraryID uint) (files []MovieFile) {
+	db.Select("movie_files.*").
+		Preload("Streams").
+		Joins("LEFT JOIN movies ON movies.id = movie_files.movie_id").
+		Where("movie_files.library_id = ?", libraryID).
+		Where("COALESCE(movie_files.frame_grab_path, '') = ''").
+		Where("COALESCE(movie_files.frame_grab_failed, 0) = 0").
+		Where("movies.id IS NULL OR movies.poster_path = '' OR movies.backdrop_path = ''").
+		Find(&files)
+	return files
+}
+
+// UpdateMovieFileFrameGrabPath stores the path of the frame grabbed from the given MovieFile.
+func UpdateMovieFileFrameGrabPath(file *MovieFile, frameGrabPath string) error {
+	return db.Model(file).UpdateColumn("frame_grab_path", frameGrabPath).Error
+}
+
+// MarkMovieFileFrameGrabFailed records that no frame could be grabbed from the given MovieFile.
+func MarkMovieFileFrameGrabFailed(file *MovieFile) error {
+	return db.Model(file).UpdateColumn("frame_grab_failed", true).Error
+}
+
 // FindMovieFilesInLibrary finds all movies in the given library.
 func FindMovieFilesInLibrary(libraryID uint) (movies []MovieFile) {
 	db.Where("library_id =?", libraryID).Find(&movies)
@@ -292,6 +339,15 @@ func FindMovieFileByUUID(uuid string) (*MovieFile, error) {
 	return &movieFile, nil
 }
 
+// FindMovieFileByPath finds a MovieFile by its file locator.
+func FindMovieFileByPath(filePath string) (*MovieFile, error) {
+	var movieFile MovieFile
+	if err := db.First(&movieFile, "file_path = ?", filePath).Error; err != nil {
+		return nil, err
+	}
+	return &movieFile, nil
+}
+
 func FindMovieForMovieFile(movieFile *MovieFile) (*Movie, error) {
 	var movie Movie
 	if err := db.Model(movieFile).Related(&movie).Error; err != nil {
@@ -78,3 +78,77 @@ func TestCollectMovie(t *testing.T) {
 	assert.Len(t, mov.MovieFiles, 1)
 	assert.Len(t, mov.MovieFiles[0].Streams, 1)
 }
+
+func TestFindMovieFilesWithoutArtwork(t *testing.T) {
+	defer setupTest(t)()
+
+	createMovieData()
+	libraryID := movie.MovieFiles[0].LibraryID
+
+	files := db.FindMovieFilesWithoutArtwork(libraryID)
+	assert.Len(t, files, 1)
+	assert.Len(t, files[0].Streams, 1)
+
+	db.MarkMovieFileFrameGrabFailed(&files[0])
+	assert.Len(t, db.FindMovieFilesWithoutArtwork(libraryID), 0)
+	assert.NoError(t, db.UpdateMediaFileContent(files[0], files[0].Size, files[0].Fingerprint, files[0].Streams))
+	assert.Len(t, db.FindMovieFilesWithoutArtwork(libraryID), 1)
+
+	db.UpdateMovieFileFrameGrabPath(&files[0], "/test.jpg")
+	assert.Len(t, db.FindMovieFilesWithoutArtwork(libraryID), 0)
+}
+
+func TestFindMediaFilesInPath(t *testing.T) {
+	defer setupTest(t)()
+
+	for _, p := range []string{
+		"rclone#/drive/Movies/Heat/Heat.mkv",
+		"rclone#/drive/Movies/Heat (1995)/Heat.mkv",
+		"rclone#/drive/Movies/Ronin.mkv",
+	} {
+		db.CreateMovieFile(&db.MovieFile{MediaItem: db.MediaItem{FilePath: p, LibraryID: 1}})
+	}
+	db.CreateMovieFile(&db.MovieFile{
+		MediaItem: db.MediaItem{FilePath: "rclone#/drive/Movies/Heat/Heat.mkv", LibraryID: 2}})
+
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/Movies/Heat"), 1)
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/Movies/Heat/Heat.mkv"), 1)
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/Movies/"), 3)
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/Series"), 0)
+	// Neither LIKE wildcards nor case match other directories.
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/Movies/He_t"), 0)
+	assert.Len(t, db.FindMediaFilesInPath(1, "rclone#/drive/movies"), 0)
+}
+
+func TestRelocateMediaFile(t *testing.T) {
+	defer setupTest(t)()
+	createMovieData()
+
+	mf := movie.MovieFiles[0]
+	assert.True(t, db.MediaFileNeedsFingerprint(0, mf.FilePath))
+	assert.NoError(t, db.SetMediaFileFingerprint(0, mf.FilePath, "123-abc"))
+	assert.False(t, db.MediaFileNeedsFingerprint(0, mf.FilePath))
+
+	files := db.FindMediaFilesByFingerprint(0, "123-abc")
+	if assert.Len(t, files, 1) {
+		assert.NoError(t, db.RelocateMediaFile(
+			files[0], "/tmp/moved/test.mkv", "test.mkv", []db.Stream{{CodecName: "moved"}}))
+	}
+	assert.Len(t, db.FindMediaFilesByFingerprint(1, "123-abc"), 0)
+
+	relocated := db.FindContentByUUID(mf.UUID)
+	if assert.NotNil(t, relocated) {
+		assert.Equal(t, "/tmp/moved/test.mkv", relocated.GetFilePath())
+		assert.Equal(t, "test.mkv", relocated.GetFileName())
+		if assert.Len(t, relocated.GetStreams(), 1) {
+			assert.Equal(t, "moved", relocated.GetStreams()[0].CodecName)
+		}
+	}
+
+	assert.Len(t, db.FindMediaFilesInPath(0, "/tmp/test.mkv"), 0)
+
+	playState, err := db.FindPlayState(movie.UUID, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(33), playState.Playtime)
+	}
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"os"
+)
+
+// OptimizedVersion is a copy of a MovieFile or EpisodeFile that was transcoded ahead of time to
+// one of the video encoder presets so that it can be streamed without transcoding on the fly.
+type OptimizedVersion struct {
+	gorm.Model
+	UUIDable
+	// OwnerID and OwnerType point to the original MovieFile or EpisodeFile.
+	OwnerID   uint
+	OwnerType string
+
+	// Preset is the name of the video encoder preset, e.g. "720-5000k-video".
+	Preset string
+	// FilePath is the file locator of the transcoded file, empty until it is done.
+	FilePath string
+	Size     int64
+
+	State string
+	// Progress of the transcoding job in percent.
+	Progress float64
+	Error    string
+}
+
+// GetSourceFile returns the MovieFile or EpisodeFile this is an optimized version of.
+func (v *OptimizedVersion) GetSourceFile() (MediaFile, error) {
+	switch v.OwnerType {
+	case "movie_files":
+		var file MovieFile
+		if err := db.Preload("Library").Take(&file, v.OwnerID).Error; err != nil {
+			return nil, err
+		}
+		return file, nil
+	case "episode_files":
+		var file EpisodeFile
+		if err := db.Preload("Library").Take(&file, v.OwnerID).Error; err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return nil, fmt.Errorf("unknown owner type %s", v.OwnerType)
+}
+
+func optimizedVersionOwner(file MediaFile) (uint, string, error) {
+	switch f := file.(type) {
+	case MovieFile:
+		return f.ID, "movie_files", nil
+	case *MovieFile:
+		return f.ID, "movie_files", nil
+	case EpisodeFile:
+		return f.ID, "episode_files", nil
+	case *EpisodeFile:
+		return f.ID, "episode_files", nil
+	}
+	return 0, "", fmt.Errorf("cannot create optimized version of %T", file)
+}
+
+// QueueOptimizedVersion queues an optimized version of the given file using the given preset.
+// If one that is not failed or cancelled already exists, it is returned instead.
+func QueueOptimizedVersion(file MediaFile, preset string) (*OptimizedVersion, error) {
+	ownerID, ownerType, err := optimizedVersionOwner(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing OptimizedVersion
+	err = db.Where("owner_id = ? AND owner_type = ? AND preset = ?", ownerID, ownerType, preset).
+		Take(&existing).Error
+	if err == nil {
+		if existing.State != JobStateFailed && existing.State != JobStateCancelled {
+			return &existing, nil
+		}
+		DeleteOptimizedVersion(&existing)
+	}
+
+	v := OptimizedVersion{
+		OwnerID:   ownerID,
+		OwnerType: ownerType,
+		Preset:    preset,
+		State:     JobStateQueued,
+	}
+	if err := db.Create(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// FindOptimizedVersionByUUID finds the OptimizedVersion with the given UUID.
+func FindOptimizedVersionByUUID(uuid string) (*OptimizedVersion, error) {
+	var v OptimizedVersion
+	if err := db.Take(&v, "uuid = ?", uuid).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// FindAllOptimizedVersions returns all optimized versions, oldest first.
+func FindAllOptimizedVersions() (versions []OptimizedVersion) {
+	db.Order("created_at ASC").Find(&versions)
+	return versions
+}
+
+// FindOptimizedVersionsInState returns all optimized versions in the given state, oldest first.
+func FindOptimizedVersionsInState(state string) (versions []OptimizedVersion) {
+	db.Where("state = ?", state).Order("created_at ASC").Find(&versions)
+	return versions
+}
+
+// FindOptimizedVersionsForFile returns all optimized versions of the given file.
+func FindOptimizedVersionsForFile(file MediaFile) (versions []OptimizedVersion) {
+	ownerID, ownerType, err := optimizedVersionOwner(file)
+	if err != nil {
+		return versions
+	}
+	db.Where("owner_id = ? AND owner_type = ?", ownerID, ownerType).Find(&versions)
+	return versions
+}
+
+// FindDoneOptimizedVersionsForFilePath returns the finished optimized versions of the MovieFile
+// or EpisodeFile with the given file locator.
+func FindDoneOptimizedVersionsForFilePath(filePath string) (versions []OptimizedVersion) {
+	db.Select("optimized_versions.*").
+		Joins("LEFT JOIN movie_files ON optimized_versions.owner_type = 'movie_files' AND movie_files.id = optimized_versions.owner_id").
+		Joins("LEFT JOIN episode_files ON optimized_versions.owner_type = 'episode_files' AND episode_files.id = optimized_versions.owner_id").
+		Where("movie_files.file_path = ? OR episode_files.file_path = ?", filePath, filePath).
+		Where("optimized_versions.state = ?", JobStateDone).
+		Find(&versions)
+	return versions
+}
+
+// UpdateOptimizedVersionProgress only updates the progress of the given OptimizedVersion so that
+// a concurrent change of its state, e.g. a cancellation, is not overwritten.
+func UpdateOptimizedVersionProgress(v *OptimizedVersion, progress float64) error {
+	return db.Model(v).UpdateColumn("progress", progress).Error
+}
+
+// StartOptimizedVersion marks the given OptimizedVersion as being transcoded if it is still
+// queued. It returns false if it isn't, e.g. because it was cancelled or deleted in the meantime.
+func StartOptimizedVersion(v *OptimizedVersion) (bool, error) {
+	res := db.Model(&OptimizedVersion{}).
+		Where("uuid = ? AND state = ?", v.UUID, JobStateQueued).
+		UpdateColumn("state", JobStateTranscoding)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	v.State = JobStateTranscoding
+	return true, nil
+}
+
+// SaveOptimizedVersion saves an OptimizedVersion.
+func SaveOptimizedVersion(v *OptimizedVersion) error {
+	return db.Save(v).Error
+}
+
+// DeleteOptimizedVersion removes the optimized version and its file.
+func DeleteOptimizedVersion(v *OptimizedVersion) {
+	if v.FilePath != "" {
+		fileLocator, err := filesystem.ParseFileLocator(v.FilePath)
+		if err == nil && fileLocator.Backend == filesystem.BackendLocal {
+			err = os.Remove(fileLocator.Path)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "path": v.FilePath}).
+				Warnln("Failed to remove optimized version")
+		}
+	}
+	db.Unscoped().Delete(v)
+}
+
+func deleteOptimizedVersionsForFile(file MediaFile) {
+	for _, v := range FindOptimizedVersionsForFile(file) {
+		DeleteOptimizedVersion(&v)
+	}
+}
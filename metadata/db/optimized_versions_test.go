@@ -0,0 +1,79 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+)
+
+func TestQueueOptimizedVersion(t *testing.T) {
+	defer setupTest(t)()
+
+	createMovieData()
+	mf := movie.MovieFiles[0]
+
+	v, err := db.QueueOptimizedVersion(mf, "720-5000k-video")
+	assert.NoError(t, err)
+	assert.Equal(t, db.JobStateQueued, v.State)
+
+	// Queueing the same preset again returns the existing version
+	again, err := db.QueueOptimizedVersion(&mf, "720-5000k-video")
+	assert.NoError(t, err)
+	assert.Equal(t, v.UUID, again.UUID)
+
+	// Failed versions are replaced
+	v.State = db.JobStateFailed
+	db.SaveOptimizedVersion(v)
+	retried, err := db.QueueOptimizedVersion(mf, "720-5000k-video")
+	assert.NoError(t, err)
+	assert.NotEqual(t, v.UUID, retried.UUID)
+	assert.Len(t, db.FindOptimizedVersionsForFile(mf), 1)
+}
+
+func TestFindDoneOptimizedVersionsForFilePath(t *testing.T) {
+	defer setupTest(t)()
+
+	createMovieData()
+	mf := movie.MovieFiles[0]
+
+	done, _ := db.QueueOptimizedVersion(mf, "720-5000k-video")
+	db.QueueOptimizedVersion(mf, "480-1000k-video")
+	assert.Empty(t, db.FindDoneOptimizedVersionsForFilePath(mf.FilePath))
+
+	done.State = db.JobStateDone
+	db.SaveOptimizedVersion(done)
+
+	versions := db.FindDoneOptimizedVersionsForFilePath(mf.FilePath)
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, "720-5000k-video", versions[0].Preset)
+	}
+	assert.Empty(t, db.FindDoneOptimizedVersionsForFilePath("/tmp/other.mkv"))
+
+	mf.DeleteSelfAndMD()
+	assert.Empty(t, db.FindAllOptimizedVersions())
+}
+
+func TestStartOptimizedVersion(t *testing.T) {
+	defer setupTest(t)()
+
+	createMovieData()
+	mf := movie.MovieFiles[0]
+
+	v, err := db.QueueOptimizedVersion(mf, "720-5000k-video")
+	assert.NoError(t, err)
+	started, err := db.StartOptimizedVersion(v)
+	assert.NoError(t, err)
+	assert.True(t, started)
+
+	// A version that was cancelled before the worker got to it isn't started
+	cancelled, err := db.QueueOptimizedVersion(mf, "1080-10000k-video")
+	assert.NoError(t, err)
+	stale := *cancelled
+	cancelled.State = db.JobStateCancelled
+	db.SaveOptimizedVersion(cancelled)
+	started, err = db.StartOptimizedVersion(&stale)
+	assert.NoError(t, err)
+	assert.False(t, started)
+	current, _ := db.FindOptimizedVersionByUUID(stale.UUID)
+	assert.Equal(t, db.JobStateCancelled, current.State)
+}
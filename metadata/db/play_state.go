@@ -1,7 +1,9 @@
 package db
 
 import (
+	"fmt"
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
 // PlayState holds status information about media files, it keeps track of progress and whether or not the content has been viewed
@@ -13,8 +15,16 @@ type PlayState struct {
 	Finished  bool
 	Playtime  float64
 	MediaUUID string `gorm:"unique_index:idx_unique_play_state_per_media"`
+	// Source is PlayStateSourceClient if the state was reported by a client and
+	// PlayStateSourceServer if it was inferred from the segments streamed to the user.
+	Source string
 }
 
+const (
+	PlayStateSourceClient = "client"
+	PlayStateSourceServer = "server"
+)
+
 // latestEpResult holds information about the episode that is up next for the given user.
 type latestEpResult struct {
 	EpisodeID  int
@@ -58,31 +68,8 @@ func UpNextEpisodes(userID uint) []*Episode {
 			ep := Episode{}
 			db.Where("ID = ?", r.EpisodeID).First(&ep)
 			eps = append(eps, &ep)
-		} else {
-			result := latestEpResult{}
-			db.Raw("SELECT episodes.id AS episode_id, series.id AS series_id"+
-				" FROM episodes"+
-				" JOIN series ON series.id = seasons.series_id"+
-				" JOIN seasons ON seasons.id = episodes.season_id"+
-				" WHERE season_num = ? AND episode_num > ? AND series_id = ?"+
-				" ORDER BY season_num ASC, episode_num ASC LIMIT 1", r.SeasonNum, r.EpisodeNum, r.SeriesID).Scan(&result)
-			ep := Episode{}
-			if result.EpisodeID != 0 {
-				db.Where("ID = ?", result.EpisodeID).First(&ep)
-				eps = append(eps, &ep)
-			} else {
-				// It appears there a no more episode left in this season, let's try the next.
-				db.Raw("SELECT episodes.id AS episode_id, series.id AS series_id"+
-					" FROM episodes"+
-					" JOIN series ON series.id = seasons.series_id"+
-					" JOIN seasons ON seasons.id = episodes.season_id"+
-					" WHERE season_num > ? AND episode_num > 0 AND series_id = ?"+
-					" ORDER BY season_num ASC, episode_num ASC LIMIT 1", r.SeasonNum, r.EpisodeNum, r.SeriesID).Scan(&result)
-				if result.EpisodeID != 0 {
-					db.Where("ID = ?", result.EpisodeID).First(&ep)
-					eps = append(eps, &ep)
-				}
-			}
+		} else if ep := findEpisodeAfter(r.SeasonNum, r.EpisodeNum, r.SeriesID); ep != nil {
+			eps = append(eps, ep)
 		}
 	}
 	for i := range eps {
@@ -91,6 +78,48 @@ func UpNextEpisodes(userID uint) []*Episode {
 	return eps
 }
 
+// NextEpisode returns the episode following the given one in the same series, with its files.
+func NextEpisode(episode *Episode) (*Episode, error) {
+	var season Season
+	if err := db.First(&season, episode.SeasonID).Error; err != nil {
+		return nil, err
+	}
+
+	ep := findEpisodeAfter(episode.SeasonNum, episode.EpisodeNum, int(season.SeriesID))
+	if ep == nil {
+		return nil, fmt.Errorf("no episode after S%02dE%02d", episode.SeasonNum, episode.EpisodeNum)
+	}
+	db.Model(ep).Preload("Streams").Association("EpisodeFiles").Find(&ep.EpisodeFiles)
+	return ep, nil
+}
+
+// findEpisodeAfter finds the episode following the given episode number in the given series.
+func findEpisodeAfter(seasonNum int, episodeNum int, seriesID int) *Episode {
+	result := latestEpResult{}
+	db.Raw("SELECT episodes.id AS episode_id, series.id AS series_id"+
+		" FROM episodes"+
+		" JOIN seasons ON seasons.id = episodes.season_id"+
+		" JOIN series ON series.id = seasons.series_id"+
+		" WHERE episodes.season_num = ? AND episodes.episode_num > ? AND series.id = ?"+
+		" ORDER BY episodes.season_num ASC, episodes.episode_num ASC LIMIT 1", seasonNum, episodeNum, seriesID).Scan(&result)
+	if result.EpisodeID == 0 {
+		// It appears there a no more episode left in this season, let's try the next.
+		db.Raw("SELECT episodes.id AS episode_id, series.id AS series_id"+
+			" FROM episodes"+
+			" JOIN seasons ON seasons.id = episodes.season_id"+
+			" JOIN series ON series.id = seasons.series_id"+
+			" WHERE episodes.season_num > ? AND episodes.episode_num > 0 AND series.id = ?"+
+			" ORDER BY episodes.season_num ASC, episodes.episode_num ASC LIMIT 1", seasonNum, seriesID).Scan(&result)
+	}
+	if result.EpisodeID == 0 {
+		return nil
+	}
+
+	ep := Episode{}
+	db.Where("ID = ?", result.EpisodeID).First(&ep)
+	return &ep
+}
+
 // LatestPlayStates returns playstates for content recently played for the given user.
 func LatestPlayStates(limit uint, userID uint) []PlayState {
 	var pss []PlayState
@@ -109,6 +138,43 @@ func SavePlayState(playState *PlayState) error {
 		Error
 }
 
+// SaveInferredPlayState saves a PlayState inferred by the server unless a client reported the
+// state of the same media within the last clientPrecedence. Returns whether it was saved.
+func SaveInferredPlayState(playState *PlayState, clientPrecedence time.Duration) (bool, error) {
+	var existing PlayState
+	err := db.Where(&PlayState{MediaUUID: playState.MediaUUID, UserID: playState.UserID}).
+		Take(&existing).Error
+	if err == nil && existing.Source != PlayStateSourceServer &&
+		time.Since(existing.UpdatedAt) < clientPrecedence {
+		return false, nil
+	}
+
+	playState.Source = PlayStateSourceServer
+	return true, SavePlayState(playState)
+}
+
+// FindMediaUUIDForFilePath returns the UUID of the movie or episode the file at the given path
+// belongs to.
+func FindMediaUUIDForFilePath(filePath string) (string, error) {
+	if movieFile, err := FindMovieFileByPath(filePath); err == nil {
+		movie, err := FindMovieForMovieFile(movieFile)
+		if err != nil {
+			return "", err
+		}
+		return movie.UUID, nil
+	}
+
+	episodeFile, err := FindEpisodeFileByPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	episode, err := FindEpisodeByID(episodeFile.EpisodeID)
+	if err != nil {
+		return "", err
+	}
+	return episode.UUID, nil
+}
+
 func DeletePlayState(mediaUUID string, userID uint) error {
 	return db.Unscoped().Delete(PlayState{}, "media_uuid = ? AND user_id = ?", mediaUUID, userID).Error
 }
@@ -3,6 +3,7 @@ package db_test
 import (
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"testing"
+	"time"
 )
 
 func createData() {
@@ -127,3 +128,59 @@ func TestContinuePlayResume(t *testing.T) {
 	}
 
 }
+
+func TestNextEpisode(t *testing.T) {
+	defer setupTest(t)()
+
+	series := db.Series{Name: "Next Episode"}
+	episode := &db.Episode{SeasonNum: 1, EpisodeNum: 1, Name: "NE - Episode 1"}
+	episode2 := &db.Episode{SeasonNum: 2, EpisodeNum: 2, Name: "NE - Episode S02E02"}
+	episode3 := &db.Episode{SeasonNum: 2, EpisodeNum: 1, Name: "NE - Episode S02E01"}
+	season := db.Season{Name: "Season 1", Episodes: []*db.Episode{episode}}
+	season2 := db.Season{Name: "Season 2", Episodes: []*db.Episode{episode2, episode3}}
+	series.Seasons = []*db.Season{&season, &season2}
+	db.CreateSeries(&series)
+
+	next, err := db.NextEpisode(episode)
+	if err != nil {
+		t.Fatal("Expected a next episode, got error", err)
+	}
+	if next.Name != episode3.Name {
+		t.Errorf("Expected the next episode to be %s got %s instead", episode3.Name, next.Name)
+	}
+
+	if _, err := db.NextEpisode(episode2); err == nil {
+		t.Error("Expected no episode after the last one")
+	}
+}
+
+func TestSaveInferredPlayState(t *testing.T) {
+	defer setupTest(t)()
+
+	db.SavePlayState(&db.PlayState{
+		MediaUUID: "client-uuid",
+		UserID:    1,
+		Playtime:  100,
+		Source:    db.PlayStateSourceClient,
+	})
+
+	saved, _ := db.SaveInferredPlayState(
+		&db.PlayState{MediaUUID: "client-uuid", UserID: 1, Playtime: 50}, time.Minute)
+	if saved {
+		t.Error("Expected a recent client-reported PlayState to take precedence")
+	}
+
+	saved, _ = db.SaveInferredPlayState(
+		&db.PlayState{MediaUUID: "client-uuid", UserID: 1, Playtime: 50}, 0)
+	if !saved {
+		t.Error("Expected an old client-reported PlayState to be overwritten")
+	}
+
+	db.SaveInferredPlayState(&db.PlayState{MediaUUID: "server-uuid", UserID: 1, Playtime: 20}, time.Minute)
+	saved, _ = db.SaveInferredPlayState(
+		&db.PlayState{MediaUUID: "server-uuid", UserID: 1, Playtime: 40, Finished: true}, time.Minute)
+	ps, err := db.FindPlayState("server-uuid", 1)
+	if !saved || err != nil || ps.Playtime != 40 || !ps.Finished {
+		t.Errorf("Expected inferred PlayState to be updated, got %+v", ps)
+	}
+}
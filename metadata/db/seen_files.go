@@ -0,0 +1,79 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// Outcomes of probing a file, stored in SeenFile.
+const (
+	// ProbeResultAdded means the file was added to the library as a movie or episode file.
+	ProbeResultAdded = "added"
+	// ProbeResultNoVideo means the file could be read but has no video streams.
+	ProbeResultNoVideo = "no_video"
+	// ProbeResultFailed means ffprobe failed to read the file.
+	ProbeResultFailed = "failed"
+)
+
+// SeenFile remembers a file in a library that was probed, so that rescans can skip it as long as
+// it doesn't change, including files that didn't make it into the library.
+type SeenFile struct {
+	gorm.Model
+	LibraryID uint   `gorm:"unique_index:idx_seen_file_path"`
+	FilePath  string `gorm:"unique_index:idx_seen_file_path"`
+	Size      int64
+	ModTime   time.Time
+	// ProbeResult is one of the ProbeResult constants.
+	ProbeResult string
+}
+
+// Unchanged returns whether the file still has the size and modification time it had when it was
+// probed.
+func (f *SeenFile) Unchanged(size int64, modTime time.Time) bool {
+	return f.Size == size && f.ModTime.Equal(modTime)
+}
+
+// FindSeenFile returns what we know about the file with the given path in the library. It fails
+// if the file wasn't probed yet.
+func FindSeenFile(libraryID uint, filePath string) (*SeenFile, error) {
+	var seenFile SeenFile
+	if err := db.Take(&seenFile, "library_id = ? AND file_path = ?", libraryID, filePath).Error; err != nil {
+		return nil, err
+	}
+	return &seenFile, nil
+}
+
+// FindSeenFilesInPath returns the seen files of the given library that are stored at the given
+// file locator or below it if it is a directory.
+func FindSeenFilesInPath(libraryID uint, filePath string) (seenFiles []SeenFile) {
+	db.Scopes(inPath(filePath)).Where("library_id = ?", libraryID).Find(&seenFiles)
+	return seenFiles
+}
+
+// FindSeenFilesInLibrary returns all seen files of the given library.
+func FindSeenFilesInLibrary(libraryID uint) (seenFiles []SeenFile) {
+	db.Where("library_id = ?", libraryID).Find(&seenFiles)
+	return seenFiles
+}
+
+// SaveSeenFile records the outcome of probing a file, replacing what we knew about it before.
+func SaveSeenFile(libraryID uint, filePath string, size int64, modTime time.Time, probeResult string) error {
+	seenFile := SeenFile{}
+	db.Where("library_id = ? AND file_path = ?", libraryID, filePath).First(&seenFile)
+	seenFile.LibraryID = libraryID
+	seenFile.FilePath = filePath
+	seenFile.Size = size
+	seenFile.ModTime = modTime
+	seenFile.ProbeResult = probeResult
+	return db.Save(&seenFile).Error
+}
+
+// DeleteSeenFile forgets about a file, e.g. because it no longer exists.
+func DeleteSeenFile(seenFile *SeenFile) error {
+	return db.Unscoped().Delete(seenFile).Error
+}
+
+// DeleteSeenFilesFromLibrary forgets about all files of the given library.
+func DeleteSeenFilesFromLibrary(libraryID uint) error {
+	return db.Unscoped().Delete(SeenFile{}, "library_id = ?", libraryID).Error
+}
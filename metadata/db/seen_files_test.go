@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestSeenFiles(t *testing.T) {
+	defer setupTest(t)()
+
+	modTime := time.Date(2019, 7, 1, 12, 30, 0, 500, time.UTC)
+	_, err := db.FindSeenFile(1, "rclone#/drive/Movies/Heat.mkv")
+	assert.Error(t, err)
+
+	assert.NoError(t, db.SaveSeenFile(1, "rclone#/drive/Movies/Heat.mkv", 100, modTime, db.ProbeResultFailed))
+	assert.NoError(t, db.SaveSeenFile(1, "rclone#/drive/Movies/Heat.mkv", 200, modTime, db.ProbeResultNoVideo))
+	assert.NoError(t, db.SaveSeenFile(1, "rclone#/drive/Movies/Ronin/Ronin.mkv", 300, modTime, db.ProbeResultAdded))
+	assert.NoError(t, db.SaveSeenFile(2, "rclone#/drive/Movies/Heat.mkv", 100, modTime, db.ProbeResultAdded))
+
+	seenFile, err := db.FindSeenFile(1, "rclone#/drive/Movies/Heat.mkv")
+	if assert.NoError(t, err) {
+		assert.Equal(t, db.ProbeResultNoVideo, seenFile.ProbeResult)
+		assert.True(t, seenFile.Unchanged(200, modTime))
+		assert.False(t, seenFile.Unchanged(100, modTime))
+		assert.False(t, seenFile.Unchanged(200, modTime.Add(time.Second)))
+	}
+
+	assert.Len(t, db.FindSeenFilesInLibrary(1), 2)
+	assert.Len(t, db.FindSeenFilesInPath(1, "rclone#/drive/Movies/Ronin"), 1)
+	assert.Len(t, db.FindSeenFilesInPath(1, "rclone#/drive/Movies/R_nin"), 0)
+
+	assert.NoError(t, db.DeleteSeenFile(seenFile))
+	assert.Len(t, db.FindSeenFilesInLibrary(1), 1)
+
+	assert.NoError(t, db.DeleteSeenFilesFromLibrary(1))
+	assert.Len(t, db.FindSeenFilesInLibrary(1), 0)
+	assert.Len(t, db.FindSeenFilesInLibrary(2), 1)
+}
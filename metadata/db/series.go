@@ -4,6 +4,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 // BaseItem holds information that is shared between various mediatypes.
@@ -103,7 +104,8 @@ func (file EpisodeFile) GetStreams() []Stream {
 // IsSingleFile returns true if this is the only file for the given episode.
 func (file *EpisodeFile) IsSingleFile() bool {
 	count := 0
-	db.Model(&EpisodeFile{}).Where("episode_id = ?", file.EpisodeID).Count(&count)
+	// Files that are missing still count, the episode is kept for them until they are purged.
+	db.Unscoped().Model(&EpisodeFile{}).Where("episode_id = ?", file.EpisodeID).Count(&count)
 	if count <= 1 {
 		return true
 	}
@@ -120,6 +122,16 @@ func (file EpisodeFile) GetFilePath() string {
 	return file.FilePath
 }
 
+// GetFingerprint is a wrapper for the MediaFile interface
+func (file EpisodeFile) GetFingerprint() string {
+	return file.Fingerprint
+}
+
+// GetMissingSince is a wrapper for the MediaFile interface
+func (file EpisodeFile) GetMissingSince() *time.Time {
+	return file.DeletedAt
+}
+
 // GetLibrary is a wrapper for the MediaFile interface
 func (file EpisodeFile) GetLibrary() *Library {
 	var library Library
@@ -137,7 +149,7 @@ func (file EpisodeFile) DeleteSelfAndMD() {
 	db.Unscoped().Delete(Stream{}, "owner_id = ? AND owner_type = 'episode_files'", &file.ID)
 
 	var episode Episode
-	db.First(&episode, file.EpisodeID)
+	db.Unscoped().First(&episode, file.EpisodeID)
 
 	if file.IsSingleFile() {
 		// Delete all PlayState information
@@ -148,9 +160,9 @@ func (file EpisodeFile) DeleteSelfAndMD() {
 
 		count := 0
 		var season Season
-		db.First(&season, episode.SeasonID)
+		db.Unscoped().First(&season, episode.SeasonID)
 
-		db.Model(Episode{}).Where("season_id = ?", season.ID).Count(&count)
+		db.Unscoped().Model(Episode{}).Where("season_id = ?", season.ID).Count(&count)
 
 		// If there are no more episodes to this season, delete the season.
 		if count == 0 {
@@ -159,12 +171,15 @@ func (file EpisodeFile) DeleteSelfAndMD() {
 
 		// If there are no more seasons to this series, delete it.
 		count = 0
-		db.Model(Season{}).Where("series_id = ?", season.SeriesID).Count(&count)
+		db.Unscoped().Model(Season{}).Where("series_id = ?", season.SeriesID).Count(&count)
 		if count == 0 {
 			db.Unscoped().Delete(Series{}, "id = ?", season.SeriesID)
 		}
 	}
 
+	file.removeFrameGrab()
+	deleteOptimizedVersionsForFile(file)
+
 	// Delete all file information
 	db.Unscoped().Delete(&file)
 
@@ -177,14 +192,14 @@ type countResult struct {
 // UnwatchedEpisodesInSeriesCount retrieves the amount of unwatched episodes in a given series.
 func UnwatchedEpisodesInSeriesCount(seriesID uint, userID uint) uint {
 	var res countResult
-	db.Raw("SELECT COUNT(*) as count FROM episodes WHERE season_id IN(SELECT id FROM seasons WHERE series_id = ?) AND uuid NOT IN(SELECT media_uuid FROM play_states WHERE finished = 1 AND user_id = ? AND media_uuid IN(SELECT uuid FROM episodes WHERE season_id IN(SELECT id FROM seasons WHERE series_id = ?)))", seriesID, userID, seriesID).Scan(&res)
+	db.Raw("SELECT COUNT(*) as count FROM episodes WHERE deleted_at IS NULL AND season_id IN(SELECT id FROM seasons WHERE series_id = ?) AND uuid NOT IN(SELECT media_uuid FROM play_states WHERE finished = 1 AND user_id = ? AND media_uuid IN(SELECT uuid FROM episodes WHERE season_id IN(SELECT id FROM seasons WHERE series_id = ?)))", seriesID, userID, seriesID).Scan(&res)
 	return res.Count
 }
 
 // UnwatchedEpisodesInSeasonCount retrieves the amount of unwatched episodes in a given season.
 func UnwatchedEpisodesInSeasonCount(seasonID uint, userID uint) uint {
 	var res countResult
-	db.Raw("select count(*) as count from episodes where season_id = ? "+
+	db.Raw("select count(*) as count from episodes where season_id = ? AND deleted_at IS NULL "+
 		"AND uuid NOT IN (SELECT media_uuid FROM play_states WHERE finished = 1 "+
 		"AND user_id = ? AND media_uuid IN( SELECT uuid FROM episodes WHERE season_id = ?))", seasonID, userID,
 		seasonID).Scan(&res)
@@ -270,6 +285,18 @@ func FindSeriesInLibrary(libraryID uint) (series []Series) {
 	return series
 }
 
+// FindLibraryIDForSeries returns the ID of the library the episode files of the given series are
+// in, 0 if it has none.
+func FindLibraryIDForSeries(seriesID uint) uint {
+	var file EpisodeFile
+	db.Select("episode_files.library_id").
+		Joins("JOIN episodes ON episodes.id = episode_files.episode_id").
+		Joins("JOIN seasons ON seasons.id = episodes.season_id").
+		Where("seasons.series_id = ?", seriesID).
+		Take(&file)
+	return file.LibraryID
+}
+
 // FindEpisodeFilesInLibrary returns all episodes in the given library.
 func FindEpisodeFilesInLibrary(libraryID uint) (episodes []EpisodeFile) {
 	db.Where("library_id = ?", libraryID).Find(&episodes)
@@ -277,6 +304,30 @@ func FindEpisodeFilesInLibrary(libraryID uint) (episodes []EpisodeFile) {
 	return episodes
 }
 
+// FindEpisodeFilesWithoutArtwork finds all EpisodeFiles in the given library that have no frame
+// grab yet and that are either unidentified or belong to an Episode without a still.
+func FindEpisodeFilesWithoutArtwork(libraryID uint) (files []EpisodeFile) {
+	db.Select("episode_files.*").
+		Preload("Streams").
+		Joins("LEFT JOIN episodes ON episodes.id = episode_files.episode_id").
+		Where("episode_files.library_id = ?", libraryID).
+		Where("COALESCE(episode_files.frame_grab_path, '') = ''").
+		Where("COALESCE(episode_files.frame_grab_failed, 0) = 0").
+		Where("episodes.id IS NULL OR episodes.still_path = ''").
+		Find(&files)
+	return files
+}
+
+// UpdateEpisodeFileFrameGrabPath stores the path of the frame grabbed from the given EpisodeFile.
+func UpdateEpisodeFileFrameGrabPath(file *EpisodeFile, frameGrabPath string) error {
+	return db.Model(file).UpdateColumn("frame_grab_path", frameGrabPath).Error
+}
+
+// MarkEpisodeFileFrameGrabFailed records that no frame could be grabbed from the given EpisodeFile.
+func MarkEpisodeFileFrameGrabFailed(file *EpisodeFile) error {
+	return db.Model(file).UpdateColumn("frame_grab_failed", true).Error
+}
+
 // FindEpisodesInLibrary returns all episodes in the given library.
 func FindEpisodesInLibrary(libraryID uint) (episodes []Episode) {
 	var files []EpisodeFile
@@ -293,6 +344,11 @@ func FindEpisodeFileByUUID(uuid string) (*EpisodeFile, error) {
 	return findEpisodeFile("uuid = ?", uuid)
 }
 
+// FindEpisodeFileByPath finds an EpisodeFile by its file locator.
+func FindEpisodeFileByPath(filePath string) (*EpisodeFile, error) {
+	return findEpisodeFile("file_path = ?", filePath)
+}
+
 func findEpisodeFile(where ...interface{}) (*EpisodeFile, error) {
 	var episodeFile EpisodeFile
 	if err := db.
@@ -384,7 +440,7 @@ func FindAllEpisodeFiles() (files []EpisodeFile) {
 // DeleteEpisodesFromLibrary deletes all episodes from the given library.
 func DeleteEpisodesFromLibrary(libraryID uint) {
 	files := []EpisodeFile{}
-	db.Where("library_id = ?", libraryID).Find(&files)
+	db.Unscoped().Where("library_id = ?", libraryID).Find(&files)
 	for _, file := range files {
 		file.DeleteSelfAndMD()
 	}
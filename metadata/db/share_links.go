@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// ShareLink grants people without an account access to stream a single movie or episode through
+// a random token.
+type ShareLink struct {
+	gorm.Model
+	Token     string `gorm:"unique_index"`
+	MediaUUID string
+	// UserID of the user that created the link. Streams through the link are attributed to them.
+	UserID    uint
+	ExpiresAt time.Time
+	// MaxPlays is how often the link can be opened, 0 means no limit.
+	MaxPlays int
+	Plays    int
+	Revoked  bool
+}
+
+// Usable returns an error if streaming through the link is no longer allowed.
+func (l *ShareLink) Usable() error {
+	if l.Revoked {
+		return fmt.Errorf("share link was revoked")
+	}
+	if time.Now().After(l.ExpiresAt) {
+		return fmt.Errorf("share link expired")
+	}
+	return nil
+}
+
+// CreateShareLink stores a new ShareLink.
+func CreateShareLink(link *ShareLink) error {
+	return db.Create(link).Error
+}
+
+// FindShareLinkByToken finds the ShareLink with the given token.
+func FindShareLinkByToken(token string) (*ShareLink, error) {
+	var link ShareLink
+	if err := db.Take(&link, "token = ?", token).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindShareLinks returns the share links created by the given user, or by anyone if userID is 0,
+// newest first.
+func FindShareLinks(userID uint) (links []ShareLink) {
+	q := db.Order("created_at DESC")
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	q.Find(&links)
+	return links
+}
+
+// RevokeShareLink revokes the share link with the given token.
+func RevokeShareLink(token string) error {
+	return db.Model(&ShareLink{}).Where("token = ?", token).UpdateColumn("revoked", true).Error
+}
+
+// UseShareLink counts a play of the share link with the given token if it is still usable and
+// has plays left.
+func UseShareLink(token string) (*ShareLink, error) {
+	link, err := FindShareLinkByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := link.Usable(); err != nil {
+		return nil, err
+	}
+
+	// Check the limit in the UPDATE itself so that concurrent plays can't exceed it.
+	res := db.Model(&ShareLink{}).
+		Where("token = ? AND (max_plays = 0 OR plays < max_plays)", token).
+		UpdateColumn("plays", gorm.Expr("plays + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("share link has no plays left")
+	}
+	link.Plays++
+	return link, nil
+}
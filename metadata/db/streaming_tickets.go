@@ -0,0 +1,49 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// StreamingTicket registers a streaming JWT handed out to a user so that it can be revoked.
+type StreamingTicket struct {
+	gorm.Model
+	// JTI is the unique ID of the JWT.
+	JTI       string `gorm:"unique_index"`
+	UserID    uint
+	FilePath  string
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+// CreateStreamingTicket registers a new StreamingTicket and forgets about expired ones.
+func CreateStreamingTicket(ticket *StreamingTicket) error {
+	db.Unscoped().Where("expires_at < ?", time.Now()).Delete(StreamingTicket{})
+	return db.Create(ticket).Error
+}
+
+// FindStreamingTicketByJTI finds the StreamingTicket with the given JTI.
+func FindStreamingTicketByJTI(jti string) (*StreamingTicket, error) {
+	var ticket StreamingTicket
+	if err := db.Take(&ticket, "jti = ?", jti).Error; err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// FindStreamingTicketsForUser returns the unexpired tickets of the given user, newest first.
+func FindStreamingTicketsForUser(userID uint) (tickets []StreamingTicket) {
+	db.Where("user_id = ? AND expires_at >= ?", userID, time.Now()).
+		Order("created_at DESC").Find(&tickets)
+	return tickets
+}
+
+// RevokeStreamingTicket revokes the ticket with the given JTI.
+func RevokeStreamingTicket(jti string) error {
+	return db.Model(&StreamingTicket{}).Where("jti = ?", jti).UpdateColumn("revoked", true).Error
+}
+
+// RevokeStreamingTicketsForUser revokes all tickets of the given user.
+func RevokeStreamingTicketsForUser(userID uint) error {
+	return db.Model(&StreamingTicket{}).Where("user_id = ?", userID).UpdateColumn("revoked", true).Error
+}
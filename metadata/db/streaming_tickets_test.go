@@ -0,0 +1,29 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestRevokeStreamingTicketsForUser(t *testing.T) {
+	defer setupTest(t)()
+
+	user, _ := db.CreateUser("ticketuser", "password", false)
+	for _, jti := range []string{"a", "b"} {
+		err := db.CreateStreamingTicket(&db.StreamingTicket{
+			JTI: jti, UserID: user.ID, FilePath: "/movie.mkv", ExpiresAt: time.Now().Add(time.Hour)})
+		assert.Nil(t, err)
+	}
+	assert.Len(t, db.FindStreamingTicketsForUser(user.ID), 2)
+
+	assert.Nil(t, db.RevokeStreamingTicketsForUser(user.ID))
+	ticket, err := db.FindStreamingTicketByJTI("a")
+	assert.Nil(t, err)
+	assert.True(t, ticket.Revoked)
+
+	db.DeleteUser(user.ID)
+	_, err = db.FindStreamingTicketByJTI("b")
+	assert.NotNil(t, err, "tickets must be deleted with their user")
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Files that went missing, e.g. because a drive isn't mounted, are not deleted right away but
+// soft-deleted, which hides them and their metadata from all regular queries. They keep their
+// metadata and play states and can be restored until they are purged for good.
+
+// TrashMediaFile marks a movie or episode file as missing. If it was the last available file of
+// its movie or episode, that is hidden as well, as are seasons and series that are left without
+// available episodes.
+func TrashMediaFile(file MediaFile) error {
+	log.WithFields(log.Fields{"path": file.GetFilePath()}).Println("Marking missing file as unavailable")
+
+	switch f := file.(type) {
+	case MovieFile:
+		if err := db.Delete(&MovieFile{}, "id = ?", f.ID).Error; err != nil {
+			return err
+		}
+		if f.MovieID == 0 {
+			return nil
+		}
+		count := 0
+		db.Model(&MovieFile{}).Where("movie_id = ?", f.MovieID).Count(&count)
+		if count == 0 {
+			return db.Delete(&Movie{}, "id = ?", f.MovieID).Error
+		}
+
+	case EpisodeFile:
+		if err := db.Delete(&EpisodeFile{}, "id = ?", f.ID).Error; err != nil {
+			return err
+		}
+		if f.EpisodeID == 0 {
+			return nil
+		}
+		count := 0
+		db.Model(&EpisodeFile{}).Where("episode_id = ?", f.EpisodeID).Count(&count)
+		if count > 0 {
+			return nil
+		}
+
+		var episode Episode
+		if err := db.Unscoped().First(&episode, f.EpisodeID).Error; err != nil {
+			return err
+		}
+		if err := db.Delete(&Episode{}, "id = ?", episode.ID).Error; err != nil {
+			return err
+		}
+
+		db.Model(&Episode{}).Where("season_id = ?", episode.SeasonID).Count(&count)
+		if count > 0 {
+			return nil
+		}
+		var season Season
+		if err := db.Unscoped().First(&season, episode.SeasonID).Error; err != nil {
+			return err
+		}
+		if err := db.Delete(&Season{}, "id = ?", season.ID).Error; err != nil {
+			return err
+		}
+
+		db.Model(&Season{}).Where("series_id = ?", season.SeriesID).Count(&count)
+		if count == 0 {
+			return db.Delete(&Series{}, "id = ?", season.SeriesID).Error
+		}
+
+	default:
+		return fmt.Errorf("unsupported media file %T", file)
+	}
+
+	return nil
+}
+
+// RestoreMediaFile makes a missing movie or episode file available again, together with the
+// metadata that was hidden because of it.
+func RestoreMediaFile(file MediaFile) error {
+	log.WithFields(log.Fields{"path": file.GetFilePath()}).Println("Restoring missing file")
+
+	switch f := file.(type) {
+	case MovieFile:
+		if err := restore(&MovieFile{}, f.ID); err != nil {
+			return err
+		}
+		if f.MovieID != 0 {
+			return restore(&Movie{}, f.MovieID)
+		}
+
+	case EpisodeFile:
+		if err := restore(&EpisodeFile{}, f.ID); err != nil {
+			return err
+		}
+		if f.EpisodeID == 0 {
+			return nil
+		}
+
+		var episode Episode
+		if err := db.Unscoped().First(&episode, f.EpisodeID).Error; err != nil {
+			return err
+		}
+		var season Season
+		if err := db.Unscoped().First(&season, episode.SeasonID).Error; err != nil {
+			return err
+		}
+		if err := restore(&Episode{}, episode.ID); err != nil {
+			return err
+		}
+		if err := restore(&Season{}, season.ID); err != nil {
+			return err
+		}
+		return restore(&Series{}, season.SeriesID)
+
+	default:
+		return fmt.Errorf("unsupported media file %T", file)
+	}
+
+	return nil
+}
+
+// restore clears the soft-deletion of the row with the given ID.
+func restore(model interface{}, id uint) error {
+	return db.Unscoped().Model(model).Where("id = ?", id).UpdateColumn("deleted_at", nil).Error
+}
+
+// findMissingMediaFiles returns the missing movie and episode files matching the given conditions.
+func findMissingMediaFiles(query string, args ...interface{}) (files []MediaFile) {
+	query = "deleted_at IS NOT NULL AND " + query
+
+	var movieFiles []MovieFile
+	db.Unscoped().Where(query, args...).Preload("Library").Order("deleted_at").Find(&movieFiles)
+	for _, f := range movieFiles {
+		files = append(files, f)
+	}
+
+	var episodeFiles []EpisodeFile
+	db.Unscoped().Where(query, args...).Preload("Library").Order("deleted_at").Find(&episodeFiles)
+	for _, f := range episodeFiles {
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// FindMissingMediaFiles returns all missing movie and episode files.
+func FindMissingMediaFiles() []MediaFile {
+	return findMissingMediaFiles("1 = 1")
+}
+
+// FindMissingMediaFileByUUID returns the missing movie or episode file with the given UUID.
+func FindMissingMediaFileByUUID(uuid string) (MediaFile, error) {
+	files := findMissingMediaFiles("uuid = ?", uuid)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no missing file found for UUID %s", uuid)
+	}
+	return files[0], nil
+}
+
+// FindMissingMediaFilesByFingerprint returns the missing movie and episode files of the given
+// library with the given fingerprint.
+func FindMissingMediaFilesByFingerprint(libraryID uint, fingerprint string) []MediaFile {
+	return findMissingMediaFiles("library_id = ? AND fingerprint = ?", libraryID, fingerprint)
+}
+
+// FindMediaFilesMissingBefore returns the movie and episode files that went missing before the
+// given time.
+func FindMediaFilesMissingBefore(t time.Time) []MediaFile {
+	return findMissingMediaFiles("deleted_at < ?", t)
+}
@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+	"time"
+)
+
+func TestTrashMovieFile(t *testing.T) {
+	defer setupTest(t)()
+	createMovieData()
+
+	file := db.FindMediaFilesInPath(0, "/tmp/test.mkv")[0]
+	assert.NoError(t, db.TrashMediaFile(file))
+
+	assert.Len(t, db.FindAllMovies(nil), 0)
+	assert.Nil(t, db.FindContentByUUID(movie.MovieFiles[0].UUID))
+	missing := db.FindMissingMediaFiles()
+	if assert.Len(t, missing, 1) {
+		assert.NotNil(t, missing[0].GetMissingSince())
+	}
+	assert.Len(t, db.FindMediaFilesMissingBefore(time.Now().Add(-time.Hour)), 0)
+	assert.Len(t, db.FindMediaFilesMissingBefore(time.Now().Add(time.Hour)), 1)
+
+	file, err := db.FindMissingMediaFileByUUID(movie.MovieFiles[0].UUID)
+	if assert.NoError(t, err) {
+		assert.NoError(t, db.RestoreMediaFile(file))
+	}
+	assert.Len(t, db.FindAllMovies(nil), 1)
+	assert.Len(t, db.FindMissingMediaFiles(), 0)
+	_, err = db.FindPlayState(movie.UUID, 1)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.TrashMediaFile(file))
+	db.FindMissingMediaFiles()[0].DeleteSelfAndMD()
+	assert.Len(t, db.FindMissingMediaFiles(), 0)
+	_, err = db.FindMovieByUUID(movie.UUID)
+	assert.Error(t, err)
+}
+
+func TestTrashEpisodeFile(t *testing.T) {
+	defer setupTest(t)()
+
+	episode := &db.Episode{SeasonNum: 1, EpisodeNum: 1, Name: "Pilot", EpisodeFiles: []db.EpisodeFile{
+		{MediaItem: db.MediaItem{FilePath: "/tmp/pilot.mkv"}},
+	}}
+	episode2 := &db.Episode{SeasonNum: 2, EpisodeNum: 1, Name: "Return", EpisodeFiles: []db.EpisodeFile{
+		{MediaItem: db.MediaItem{FilePath: "/tmp/return.mkv"}},
+	}}
+	series := db.Series{Name: "Trashed", Seasons: []*db.Season{
+		{Name: "Season 1", SeasonNumber: 1, Episodes: []*db.Episode{episode}},
+		{Name: "Season 2", SeasonNumber: 2, Episodes: []*db.Episode{episode2}},
+	}}
+	db.CreateSeries(&series)
+
+	assert.NoError(t, db.TrashMediaFile(db.FindMediaFilesInPath(0, "/tmp/pilot.mkv")[0]))
+	_, err := db.FindEpisodeByUUID(episode.UUID)
+	assert.Error(t, err)
+	_, err = db.FindSeasonByUUID(series.Seasons[0].UUID)
+	assert.Error(t, err)
+	_, err = db.FindSeriesByUUID(series.UUID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.TrashMediaFile(db.FindMediaFilesInPath(0, "/tmp/return.mkv")[0]))
+	_, err = db.FindSeriesByUUID(series.UUID)
+	assert.Error(t, err)
+
+	file, err := db.FindMissingMediaFileByUUID(episode.EpisodeFiles[0].UUID)
+	if assert.NoError(t, err) {
+		assert.NoError(t, db.RestoreMediaFile(file))
+	}
+	_, err = db.FindEpisodeByUUID(episode.UUID)
+	assert.NoError(t, err)
+	_, err = db.FindSeasonByUUID(series.Seasons[0].UUID)
+	assert.NoError(t, err)
+	_, err = db.FindSeriesByUUID(series.UUID)
+	assert.NoError(t, err)
+	_, err = db.FindEpisodeByUUID(episode2.UUID)
+	assert.Error(t, err)
+}
@@ -26,6 +26,14 @@ type User struct {
 	Admin        bool   `gorm:"not null" json:"admin"`
 	PasswordHash string `gorm:"not null" json:"-"`
 	Salt         string `gorm:"not null" json:"-"`
+
+	// Maximum streaming bitrates in bits per second for clients in the local network and remote
+	// clients. 0 means no limit other than the global one.
+	MaxLocalBitrate  int `json:"maxLocalBitrate"`
+	MaxRemoteBitrate int `json:"maxRemoteBitrate"`
+
+	// CanShare allows a user that is not an admin to create share links.
+	CanShare bool `json:"canShare"`
 }
 
 // Invite is a model used to invite users to your server.
@@ -148,6 +156,38 @@ func FindUserByUsername(username string) (*User, error) {
 	return &user, nil
 }
 
+// UpdateUserBitrateLimits sets the maximum streaming bitrates of the given user.
+func UpdateUserBitrateLimits(id uint, maxLocalBitrate int, maxRemoteBitrate int) (*User, error) {
+	user, err := FindUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if maxLocalBitrate < 0 || maxRemoteBitrate < 0 {
+		return nil, fmt.Errorf("bitrate limits must not be negative")
+	}
+
+	user.MaxLocalBitrate = maxLocalBitrate
+	user.MaxRemoteBitrate = maxRemoteBitrate
+	if err := db.Save(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUserCanShare sets whether the given user may create share links.
+func UpdateUserCanShare(id uint, canShare bool) (*User, error) {
+	user, err := FindUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.CanShare = canShare
+	if err := db.Save(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // UserCount counts the amount of users in the db.
 func UserCount() int {
 	count := 0
@@ -162,6 +202,12 @@ func DeleteUser(id uint) (User, error) {
 
 	if user.ID != 0 {
 		db.Unscoped().Where("user_id = ?", user.ID).Delete(Invite{})
+		// Invalidates all streaming tickets of the user
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(StreamingTicket{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(ShareLink{})
+		for _, c := range FindClipsForUser(user.ID) {
+			DeleteClip(&c)
+		}
 		obj := db.Unscoped().Delete(&user)
 		return user, obj.Error
 	}
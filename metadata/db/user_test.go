@@ -15,3 +15,24 @@ func TestSetPassword(t *testing.T) {
 		t.Errorf("Password hash is not set on user")
 	}
 }
+
+func TestUpdateUserBitrateLimits(t *testing.T) {
+	defer setupTest(t)()
+
+	user, err := db.CreateUser("limited", "password123", false)
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	if _, err := db.UpdateUserBitrateLimits(user.ID, -1, 0); err == nil {
+		t.Error("Expected negative limits to be rejected")
+	}
+
+	if _, err := db.UpdateUserBitrateLimits(user.ID, 0, 2000000); err != nil {
+		t.Fatal("Failed to update limits:", err)
+	}
+	found, _ := db.FindUser(user.ID)
+	if found.MaxLocalBitrate != 0 || found.MaxRemoteBitrate != 2000000 {
+		t.Errorf("Expected limits 0/2000000 got %d/%d instead", found.MaxLocalBitrate, found.MaxRemoteBitrate)
+	}
+}
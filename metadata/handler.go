@@ -23,6 +23,9 @@ func RegisterRoutes(menv *app.MetadataContext, r *mux.Router) {
 	r.HandleFunc("/v1/user", auth.CreateUserHandler).Methods("POST")
 	r.HandleFunc("/v1/user/setup", auth.ReadyForSetup)
 
+	// Public, the share link's token is the authorisation.
+	r.HandleFunc("/v1/share/{token}", ShareLinkHandler).Methods("GET")
+
 	// TODO(Maran): This should be authenticated too.
 	r.HandleFunc("/images/{provider}/{size}/{id}", imageManager.HTTPHandler)
 }
@@ -13,6 +13,10 @@ import (
 	"path"
 )
 
+// LocalImageProvider is the provider name for images that were generated locally, e.g. frames
+// grabbed from media files. These images are not scaled, the size is ignored.
+const LocalImageProvider = "local"
+
 // ImageManager cache implementation for themoviedb.
 type ImageManager struct {
 	// Path where cached images will be stored.
@@ -21,16 +25,23 @@ type ImageManager struct {
 
 // NewImageManager creates a new instance of a image caching server for themoviedb.
 func NewImageManager() *ImageManager {
-	cachePath := path.Join(helpers.CacheDir(), "images")
+	cachePath := helpers.ImageCachePath()
 	helpers.EnsurePath(cachePath)
 	return &ImageManager{cachePath: cachePath}
 }
 
 // HTTPHandler responsible for proxying calls to themoviedb. If an image is already present locally it will be served from filesystem. If it's not present it will attempt to download a file into the filesystem cache from tmdb.
+// Images from the "local" provider are generated by olaris itself and are never downloaded.
 func (man *ImageManager) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 	provider := mux.Vars(r)["provider"]
 	size := mux.Vars(r)["size"]
 	id := mux.Vars(r)["id"]
+
+	if provider == LocalImageProvider {
+		man.serveLocalImage(w, r, id)
+		return
+	}
+
 	folderPath := path.Join(man.cachePath, provider, size)
 	filePath := path.Join(folderPath, id)
 	if helpers.FileExists(filePath) {
@@ -76,3 +87,12 @@ func (man *ImageManager) HTTPHandler(w http.ResponseWriter, r *http.Request) {
 		w.Write(imageB)
 	}
 }
+
+func (man *ImageManager) serveLocalImage(w http.ResponseWriter, r *http.Request, id string) {
+	filePath := path.Join(helpers.LocalImagePath(), path.Base(id))
+	if !helpers.FileExists(filePath) {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filePath)
+}
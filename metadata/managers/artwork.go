@@ -0,0 +1,95 @@
+package managers
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"io/ioutil"
+	"path"
+)
+
+var generateFrameGrabsFlag = flag.Bool(
+	"generate_frame_grabs",
+	true,
+	"Whether to grab frames from media files without artwork to use as fallback artwork")
+
+// frameGrabHeight is the height that frames grabbed from media files are scaled to.
+const frameGrabHeight = 720
+
+// GenerateMissingArtwork grabs representative frames from files that have no artwork from the
+// metadata agent, e.g. unidentified files, home videos or episodes without a still.
+func (man *LibraryManager) GenerateMissingArtwork() {
+	if !*generateFrameGrabsFlag {
+		return
+	}
+	log.WithFields(man.Library.LogFields()).Debugln("Grabbing frames for files without artwork.")
+
+	for _, f := range db.FindMovieFilesWithoutArtwork(man.Library.ID) {
+		if man.isShutingDown {
+			return
+		}
+		frameGrabPath, err := grabFrame(f.UUID, f.FilePath, f.Streams)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "path": f.FilePath}).
+				Warnln("Failed to grab frame from MovieFile")
+			db.MarkMovieFileFrameGrabFailed(&f)
+			continue
+		}
+		db.UpdateMovieFileFrameGrabPath(&f, frameGrabPath)
+	}
+
+	for _, f := range db.FindEpisodeFilesWithoutArtwork(man.Library.ID) {
+		if man.isShutingDown {
+			return
+		}
+		frameGrabPath, err := grabFrame(f.UUID, f.FilePath, f.Streams)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "path": f.FilePath}).
+				Warnln("Failed to grab frame from EpisodeFile")
+			db.MarkEpisodeFileFrameGrabFailed(&f)
+			continue
+		}
+		db.UpdateEpisodeFileFrameGrabPath(&f, frameGrabPath)
+	}
+}
+
+// grabFrame extracts a representative frame from the given file and stores it in the local image
+// cache. It returns the image ID to be used with the "local" image provider.
+func grabFrame(uuid string, filePath string, streams []db.Stream) (string, error) {
+	var videoStream *db.Stream
+	for i := range streams {
+		if streams[i].StreamType == "video" {
+			videoStream = &streams[i]
+			break
+		}
+	}
+	if videoStream == nil {
+		return "", fmt.Errorf("file has no video stream")
+	}
+
+	fileLocator, err := filesystem.ParseFileLocator(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	frame, err := ffmpeg.ExtractRepresentativeFrame(
+		fileLocator, videoStream.TotalDuration, frameGrabHeight)
+	if err != nil {
+		return "", err
+	}
+
+	imageDir := helpers.LocalImagePath()
+	if err := helpers.EnsurePath(imageDir); err != nil {
+		return "", err
+	}
+	imageName := uuid + ".jpg"
+	if err := ioutil.WriteFile(path.Join(imageDir, imageName), frame, 0644); err != nil {
+		return "", err
+	}
+
+	return "/" + imageName, nil
+}
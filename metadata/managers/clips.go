@@ -0,0 +1,275 @@
+package managers
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+var clipExpiryFlag = flag.Duration(
+	"clip_expiry",
+	7*24*time.Hour,
+	"How long finished clips can be shared before they are removed")
+var maxClipLengthFlag = flag.Duration(
+	"max_clip_length",
+	3*time.Minute,
+	"Maximum length of a clip")
+var maxClipsPerUserFlag = flag.Int(
+	"max_clips_per_user",
+	10,
+	"Maximum number of unexpired clips a user can have, 0 means no limit")
+
+// clipsDir returns the directory that clips are transcoded to.
+func clipsDir() string {
+	return path.Join(helpers.CacheDir(), "clips")
+}
+
+// ClipManager cuts queued clips in the background, one at a time, and removes clips once they
+// expire.
+type ClipManager struct {
+	// wakeChan is signalled when new clips are queued.
+	wakeChan chan bool
+	exitChan chan bool
+	// expiryExitChan stops the periodic removal of expired clips.
+	expiryExitChan chan bool
+
+	// Guards the fields below
+	mutex        sync.Mutex
+	currentJob   *ffmpeg.FileTranscodingJob
+	currentID    string
+	shuttingDown bool
+}
+
+// NewClipManager creates a new ClipManager and starts working on any clips that are still queued
+// from a previous run.
+func NewClipManager() *ClipManager {
+	// Clips that were being transcoded when we were shut down have to start over.
+	for _, c := range db.FindClipsInState(db.JobStateTranscoding) {
+		c.State = db.JobStateQueued
+		c.Progress = 0
+		db.SaveClip(&c)
+	}
+
+	m := &ClipManager{
+		wakeChan:       make(chan bool, 1),
+		exitChan:       make(chan bool),
+		expiryExitChan: make(chan bool),
+	}
+	go m.work()
+	go m.removeExpiredPeriodically()
+
+	return m
+}
+
+// Queue queues a clip of the given file for the given user.
+func (m *ClipManager) Queue(
+	userID uint,
+	file db.MediaFile,
+	fileUUID string,
+	options ffmpeg.ClipOptions) (*db.Clip, error) {
+
+	if options.Start < 0 || options.End <= options.Start {
+		return nil, fmt.Errorf("the clip has to end after it starts")
+	}
+	if options.End-options.Start > *maxClipLengthFlag {
+		return nil, fmt.Errorf("clips can be at most %s long", *maxClipLengthFlag)
+	}
+	if *maxClipsPerUserFlag > 0 && db.CountActiveClipsForUser(userID) >= *maxClipsPerUserFlag {
+		return nil, fmt.Errorf("you can't have more than %d clips, delete some first", *maxClipsPerUserFlag)
+	}
+
+	c := &db.Clip{
+		UserID:           userID,
+		MediaFileUUID:    fileUUID,
+		FileName:         file.GetFileName(),
+		Start:            options.Start.Seconds(),
+		End:              options.End.Seconds(),
+		SubtitleStreamID: options.SubtitleStreamID,
+		State:            db.JobStateQueued,
+	}
+	if err := db.CreateClip(c); err != nil {
+		return nil, err
+	}
+
+	select {
+	case m.wakeChan <- true:
+	default:
+	}
+	return c, nil
+}
+
+// Delete cancels the given clip if it is still being transcoded and removes it.
+func (m *ClipManager) Delete(c *db.Clip) {
+	db.DeleteClip(c)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.currentID == c.UUID && m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+}
+
+// RemoveExpired removes all clips that have expired.
+func (m *ClipManager) RemoveExpired() {
+	for _, c := range db.FindExpiredClips(time.Now()) {
+		log.WithFields(log.Fields{"uuid": c.UUID, "user": c.UserID}).Debugln("Removing expired clip")
+		db.DeleteClip(&c)
+	}
+}
+
+// Shutdown stops the ClipManager, cancelling the running job.
+func (m *ClipManager) Shutdown() {
+	m.mutex.Lock()
+	m.shuttingDown = true
+	if m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+	m.mutex.Unlock()
+
+	m.expiryExitChan <- true
+	m.exitChan <- true
+}
+
+func (m *ClipManager) removeExpiredPeriodically() {
+	expiryTicker := time.NewTicker(time.Hour)
+	defer expiryTicker.Stop()
+	for {
+		select {
+		case <-expiryTicker.C:
+			m.RemoveExpired()
+		case <-m.expiryExitChan:
+			return
+		}
+	}
+}
+
+func (m *ClipManager) work() {
+	for {
+		queued := db.FindClipsInState(db.JobStateQueued)
+		if len(queued) == 0 {
+			select {
+			case <-m.wakeChan:
+				continue
+			case <-m.exitChan:
+				return
+			}
+		}
+
+		select {
+		case <-m.exitChan:
+			return
+		default:
+		}
+
+		m.transcode(&queued[0])
+	}
+}
+
+func (m *ClipManager) transcode(c *db.Clip) {
+	logFields := log.Fields{"uuid": c.UUID, "user": c.UserID, "start": c.Start, "end": c.End}
+
+	fail := func(err error) {
+		log.WithFields(logFields).WithField("error", err).Warnln("Failed to create clip")
+		// Jobs interrupted by a shutdown are started over on the next start
+		m.mutex.Lock()
+		shuttingDown := m.shuttingDown
+		m.mutex.Unlock()
+		if shuttingDown {
+			return
+		}
+		// Don't resurrect a clip that was deleted in the meantime
+		if current, _ := db.FindClipByUUID(c.UUID); current == nil {
+			return
+		}
+		c.State = db.JobStateFailed
+		c.Error = err.Error()
+		db.SaveClip(c)
+	}
+
+	file := db.FindContentByUUID(c.MediaFileUUID)
+	if file == nil {
+		fail(fmt.Errorf("no file found for UUID %s", c.MediaFileUUID))
+		return
+	}
+	fileLocator, err := filesystem.ParseFileLocator(file.GetFilePath())
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	dir := clipsDir()
+	if err := helpers.EnsurePath(dir); err != nil {
+		fail(err)
+		return
+	}
+	outputPath := path.Join(dir, c.UUID+".mp4")
+
+	job, err := ffmpeg.NewClipTranscodingJob(
+		fileLocator,
+		ffmpeg.ClipOptions{
+			Start:            time.Duration(c.Start * float64(time.Second)),
+			End:              time.Duration(c.End * float64(time.Second)),
+			SubtitleStreamID: c.SubtitleStreamID,
+		},
+		outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	m.mutex.Lock()
+	// The clip may have been deleted while we were getting ready. From here on, Delete will find
+	// the job and stop it.
+	if current, _ := db.FindClipByUUID(c.UUID); current == nil || m.shuttingDown {
+		m.mutex.Unlock()
+		return
+	}
+	m.currentJob = job
+	m.currentID = c.UUID
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		m.currentJob = nil
+		m.currentID = ""
+		m.mutex.Unlock()
+	}()
+
+	c.State = db.JobStateTranscoding
+	db.SaveClip(c)
+	log.WithFields(logFields).WithField("path", fileLocator).Infoln("Creating clip")
+
+	err = job.Run(func(progress float64) {
+		db.UpdateClipProgress(c, progress)
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if current, _ := db.FindClipByUUID(c.UUID); current == nil {
+		os.Remove(outputPath)
+		return
+	}
+
+	c.State = db.JobStateDone
+	c.Progress = 100
+	c.Size = stat.Size()
+	c.FilePath = outputPath
+	c.ExpiresAt = time.Now().Add(*clipExpiryFlag)
+	db.SaveClip(c)
+	log.WithFields(logFields).Infoln("Finished creating clip")
+}
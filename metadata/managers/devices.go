@@ -0,0 +1,270 @@
+package managers
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Offline devices are forgotten after this long.
+const deviceForgetTimeout = 24 * time.Hour
+
+// Number of commands or state updates buffered per subscriber before they are dropped.
+const deviceSubscriberBuffer = 16
+
+// Device commands
+const (
+	// DeviceCommandPlay starts playing a media item at a position.
+	DeviceCommandPlay   = "play"
+	DeviceCommandPause  = "pause"
+	DeviceCommandResume = "resume"
+	DeviceCommandSeek   = "seek"
+	DeviceCommandStop   = "stop"
+	// DeviceCommandSetAudioStream switches to the audio stream with the given StreamID.
+	DeviceCommandSetAudioStream = "setAudioStream"
+	// DeviceCommandSetSubtitleStream switches to the subtitle stream with the given StreamID, or
+	// disables subtitles if it is -1.
+	DeviceCommandSetSubtitleStream = "setSubtitleStream"
+)
+
+// DeviceCommand tells a device what to do.
+type DeviceCommand struct {
+	Action    string
+	MediaUUID string
+	// Position in seconds
+	Position float64
+	StreamID int
+	// The device that sent the command, if any.
+	FromDeviceID string
+}
+
+// DeviceState is what a device reports about its playback.
+type DeviceState struct {
+	MediaUUID string
+	// Position in seconds
+	Position         float64
+	Playing          bool
+	AudioStreamID    int
+	SubtitleStreamID int
+	UpdatedAt        time.Time
+}
+
+// Device is a snapshot of a client that can be remote controlled.
+type Device struct {
+	ID     string
+	UserID uint
+	Name   string
+	// Type is a free-form hint for the UI, e.g. "tv", "phone" or "web".
+	Type     string
+	Online   bool
+	LastSeen time.Time
+	State    DeviceState
+}
+
+type deviceKey struct {
+	userID   uint
+	deviceID string
+}
+
+type device struct {
+	Device
+	// The subscription through which the device receives commands, nil if the device is offline.
+	commands chan DeviceCommand
+}
+
+type deviceStateSubscriber struct {
+	userID uint
+	// Only updates of this device are sent, all of the user's devices if empty.
+	deviceID string
+	updates  chan Device
+}
+
+// DeviceManager keeps track of the clients of each user so that they can be remote controlled
+// from other clients of the same user.
+type DeviceManager struct {
+	// Guards the fields below
+	mutex            sync.Mutex
+	devices          map[deviceKey]*device
+	stateSubscribers map[*deviceStateSubscriber]bool
+}
+
+// NewDeviceManager creates a new DeviceManager.
+func NewDeviceManager() *DeviceManager {
+	return &DeviceManager{
+		devices:          map[deviceKey]*device{},
+		stateSubscribers: map[*deviceStateSubscriber]bool{},
+	}
+}
+
+// publishState sends the device to all state subscribers interested in it. Must be called with
+// the mutex held.
+func (m *DeviceManager) publishState(d *device) {
+	for s := range m.stateSubscribers {
+		if s.userID != d.UserID || (s.deviceID != "" && s.deviceID != d.ID) {
+			continue
+		}
+		select {
+		case s.updates <- d.Device:
+		default:
+			log.WithFields(log.Fields{"user": d.UserID, "device": d.ID}).
+				Warnln("Device state subscriber is not keeping up, dropping update")
+		}
+	}
+}
+
+// forgetOfflineDevices removes devices that have been offline for a long time. Must be called
+// with the mutex held.
+func (m *DeviceManager) forgetOfflineDevices() {
+	for k, d := range m.devices {
+		if !d.Online && time.Since(d.LastSeen) > deviceForgetTimeout {
+			delete(m.devices, k)
+		}
+	}
+}
+
+// Announce registers a device of the given user and returns a channel on which commands for it
+// are sent. The device is online until stop is closed. Announcing a device that is already online
+// takes over its commands, e.g. after a client reconnected.
+func (m *DeviceManager) Announce(
+	userID uint,
+	deviceID string,
+	name string,
+	deviceType string,
+	stop <-chan struct{}) (<-chan DeviceCommand, error) {
+
+	if deviceID == "" {
+		return nil, fmt.Errorf("device ID is required")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.forgetOfflineDevices()
+
+	k := deviceKey{userID, deviceID}
+	d, ok := m.devices[k]
+	if !ok {
+		d = &device{Device: Device{ID: deviceID, UserID: userID}}
+		m.devices[k] = d
+	}
+	if d.commands != nil {
+		close(d.commands)
+	}
+	commands := make(chan DeviceCommand, deviceSubscriberBuffer)
+	d.commands = commands
+	d.Name = name
+	d.Type = deviceType
+	d.Online = true
+	d.LastSeen = time.Now()
+	m.publishState(d)
+
+	log.WithFields(log.Fields{"user": userID, "device": deviceID, "name": name}).Infoln("Device online")
+
+	go func() {
+		<-stop
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		// The device may have reconnected through another subscription in the meantime.
+		if d.commands != commands {
+			return
+		}
+		close(d.commands)
+		d.commands = nil
+		d.Online = false
+		d.LastSeen = time.Now()
+		m.publishState(d)
+
+		log.WithFields(log.Fields{"user": userID, "device": deviceID}).Infoln("Device offline")
+	}()
+	return commands, nil
+}
+
+// Devices returns all known devices of the given user.
+func (m *DeviceManager) Devices(userID uint) []Device {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	devices := []Device{}
+	for _, d := range m.devices {
+		if d.UserID == userID {
+			devices = append(devices, d.Device)
+		}
+	}
+	return devices
+}
+
+// SendCommand sends a command to one of the user's devices.
+func (m *DeviceManager) SendCommand(userID uint, deviceID string, command DeviceCommand) error {
+	switch command.Action {
+	case DeviceCommandPlay:
+		if command.MediaUUID == "" {
+			return fmt.Errorf("mediaUUID is required to play")
+		}
+	case DeviceCommandPause, DeviceCommandResume, DeviceCommandSeek, DeviceCommandStop,
+		DeviceCommandSetAudioStream, DeviceCommandSetSubtitleStream:
+	default:
+		return fmt.Errorf("unknown device command %s", command.Action)
+	}
+	if command.Position < 0 {
+		return fmt.Errorf("invalid position %f", command.Position)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d, ok := m.devices[deviceKey{userID, deviceID}]
+	if !ok || d.commands == nil {
+		return fmt.Errorf("device %s is not online", deviceID)
+	}
+
+	select {
+	case d.commands <- command:
+		return nil
+	default:
+		return fmt.Errorf("device %s is not responding", deviceID)
+	}
+}
+
+// ReportState updates the playback state of one of the user's devices and tells the state
+// subscribers about it.
+func (m *DeviceManager) ReportState(userID uint, deviceID string, state DeviceState) (Device, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d, ok := m.devices[deviceKey{userID, deviceID}]
+	if !ok {
+		return Device{}, fmt.Errorf("unknown device %s, announce it first", deviceID)
+	}
+
+	state.UpdatedAt = time.Now()
+	d.State = state
+	d.LastSeen = state.UpdatedAt
+	m.publishState(d)
+	return d.Device, nil
+}
+
+// SubscribeState returns a channel on which state updates of the given device of the user, or of
+// all of their devices if deviceID is empty, are sent until stop is closed.
+func (m *DeviceManager) SubscribeState(userID uint, deviceID string, stop <-chan struct{}) <-chan Device {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := &deviceStateSubscriber{
+		userID:   userID,
+		deviceID: deviceID,
+		updates:  make(chan Device, deviceSubscriberBuffer),
+	}
+	m.stateSubscribers[s] = true
+
+	go func() {
+		<-stop
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		delete(m.stateSubscribers, s)
+		close(s.updates)
+	}()
+	return s.updates
+}
@@ -0,0 +1,310 @@
+package managers
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+var downloadExpiryFlag = flag.Duration(
+	"download_expiry",
+	7*24*time.Hour,
+	"How long finished offline downloads are kept before they are removed")
+var maxPendingDownloadsPerUserFlag = flag.Int(
+	"max_pending_downloads_per_user",
+	10,
+	"Maximum number of downloads a user can have waiting to be transcoded, 0 means no limit")
+
+// downloadsDir returns the directory that downloads are transcoded to.
+func downloadsDir() string {
+	return path.Join(helpers.CacheDir(), "downloads")
+}
+
+// DownloadManager transcodes queued downloads in the background, one at a time, and removes
+// downloads once they expire.
+type DownloadManager struct {
+	// wakeChan is signalled when new downloads are queued.
+	wakeChan chan bool
+	exitChan chan bool
+	// expiryExitChan stops the periodic removal of expired downloads.
+	expiryExitChan chan bool
+
+	// Guards the fields below
+	mutex        sync.Mutex
+	currentJob   *ffmpeg.FileTranscodingJob
+	currentID    string
+	shuttingDown bool
+}
+
+// NewDownloadManager creates a new DownloadManager and starts working on any downloads that are
+// still queued from a previous run.
+func NewDownloadManager() *DownloadManager {
+	// Downloads that were being transcoded when we were shut down have to start over.
+	for _, d := range db.FindDownloadsInState(db.JobStateTranscoding) {
+		d.State = db.JobStateQueued
+		d.Progress = 0
+		db.SaveDownload(&d)
+	}
+
+	m := &DownloadManager{
+		wakeChan:       make(chan bool, 1),
+		exitChan:       make(chan bool),
+		expiryExitChan: make(chan bool),
+	}
+	go m.work()
+	go m.removeExpiredPeriodically()
+
+	return m
+}
+
+// Queue queues a download of the given file for the given user.
+func (m *DownloadManager) Queue(
+	userID uint,
+	file db.MediaFile,
+	fileUUID string,
+	options ffmpeg.TranscodeToFileOptions) (*db.Download, error) {
+
+	if !ffmpeg.IsVideoEncoderPreset(options.Preset) {
+		return nil, fmt.Errorf("no preset \"%s\"", options.Preset)
+	}
+	if err := checkDownloadStreams(file, options); err != nil {
+		return nil, err
+	}
+	if *maxPendingDownloadsPerUserFlag > 0 &&
+		db.CountPendingDownloadsForUser(userID) >= *maxPendingDownloadsPerUserFlag {
+		return nil, fmt.Errorf(
+			"you can't have more than %d downloads waiting, wait for them to finish first",
+			*maxPendingDownloadsPerUserFlag)
+	}
+
+	d := &db.Download{
+		UserID:            userID,
+		MediaFileUUID:     fileUUID,
+		FileName:          file.GetFileName(),
+		Preset:            options.Preset,
+		AudioStreamIDs:    db.StreamIDsToString(options.AudioStreamIds),
+		SubtitleStreamIDs: db.StreamIDsToString(options.SubtitleStreamIds),
+		State:             db.JobStateQueued,
+	}
+	if err := db.CreateDownload(d); err != nil {
+		return nil, err
+	}
+
+	select {
+	case m.wakeChan <- true:
+	default:
+	}
+	return d, nil
+}
+
+// checkDownloadStreams returns an error if the audio and subtitle streams of the given options
+// can't be put into a download of the file. Only the file's own audio streams and text subtitle
+// streams can, external and image-based subtitles would make ffmpeg fail.
+func checkDownloadStreams(file db.MediaFile, options ffmpeg.TranscodeToFileOptions) error {
+	// External subtitles are the only streams that aren't in the same file as the video.
+	var videoLocator filesystem.FileLocator
+	for _, s := range file.GetStreams() {
+		if s.StreamType == "video" {
+			videoLocator = s.FileLocator
+			break
+		}
+	}
+	embedded := map[int64]db.Stream{}
+	for _, s := range file.GetStreams() {
+		if s.FileLocator == videoLocator {
+			embedded[s.StreamId] = s
+		}
+	}
+
+	for _, id := range options.AudioStreamIds {
+		if s, ok := embedded[id]; !ok || s.StreamType != "audio" {
+			return fmt.Errorf("file has no audio stream %d", id)
+		}
+	}
+	for _, id := range options.SubtitleStreamIds {
+		s, ok := embedded[id]
+		if !ok || s.StreamType != "subtitle" {
+			return fmt.Errorf("file has no embedded subtitle stream %d", id)
+		}
+		if ffmpeg.IsImageSubtitleCodec(s.CodecName) {
+			return fmt.Errorf("subtitle stream %d is image-based, only text subtitles can be downloaded", id)
+		}
+	}
+	return nil
+}
+
+// Delete cancels the given download if it is still being transcoded and removes it.
+func (m *DownloadManager) Delete(d *db.Download) {
+	db.DeleteDownload(d)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.currentID == d.UUID && m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+}
+
+// RemoveExpired removes all downloads that have expired.
+func (m *DownloadManager) RemoveExpired() {
+	for _, d := range db.FindExpiredDownloads(time.Now()) {
+		log.WithFields(log.Fields{"uuid": d.UUID, "user": d.UserID}).Debugln("Removing expired download")
+		db.DeleteDownload(&d)
+	}
+}
+
+// Shutdown stops the DownloadManager, cancelling the running job.
+func (m *DownloadManager) Shutdown() {
+	m.mutex.Lock()
+	m.shuttingDown = true
+	if m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+	m.mutex.Unlock()
+
+	m.expiryExitChan <- true
+	m.exitChan <- true
+}
+
+func (m *DownloadManager) removeExpiredPeriodically() {
+	expiryTicker := time.NewTicker(time.Hour)
+	defer expiryTicker.Stop()
+	for {
+		select {
+		case <-expiryTicker.C:
+			m.RemoveExpired()
+		case <-m.expiryExitChan:
+			return
+		}
+	}
+}
+
+func (m *DownloadManager) work() {
+	for {
+		queued := db.FindDownloadsInState(db.JobStateQueued)
+		if len(queued) == 0 {
+			select {
+			case <-m.wakeChan:
+				continue
+			case <-m.exitChan:
+				return
+			}
+		}
+
+		select {
+		case <-m.exitChan:
+			return
+		default:
+		}
+
+		m.transcode(&queued[0])
+	}
+}
+
+func (m *DownloadManager) transcode(d *db.Download) {
+	logFields := log.Fields{"uuid": d.UUID, "user": d.UserID, "preset": d.Preset}
+
+	fail := func(err error) {
+		log.WithFields(logFields).WithField("error", err).Warnln("Failed to create download")
+		// Jobs interrupted by a shutdown are started over on the next start
+		m.mutex.Lock()
+		shuttingDown := m.shuttingDown
+		m.mutex.Unlock()
+		if shuttingDown {
+			return
+		}
+		// Don't resurrect a download that was deleted in the meantime
+		if current, _ := db.FindDownloadByUUID(d.UUID); current == nil {
+			return
+		}
+		d.State = db.JobStateFailed
+		d.Error = err.Error()
+		db.SaveDownload(d)
+	}
+
+	file := db.FindContentByUUID(d.MediaFileUUID)
+	if file == nil {
+		fail(fmt.Errorf("no file found for UUID %s", d.MediaFileUUID))
+		return
+	}
+	fileLocator, err := filesystem.ParseFileLocator(file.GetFilePath())
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	dir := downloadsDir()
+	if err := helpers.EnsurePath(dir); err != nil {
+		fail(err)
+		return
+	}
+	outputPath := path.Join(dir, d.UUID+".mp4")
+
+	job, err := ffmpeg.NewFileTranscodingJob(
+		fileLocator,
+		ffmpeg.TranscodeToFileOptions{
+			Preset:            d.Preset,
+			AudioStreamIds:    db.StreamIDsFromString(d.AudioStreamIDs),
+			SubtitleStreamIds: db.StreamIDsFromString(d.SubtitleStreamIDs),
+		},
+		outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	m.mutex.Lock()
+	// The download may have been deleted while we were getting ready. From here on, Delete will
+	// find the job and stop it.
+	if current, _ := db.FindDownloadByUUID(d.UUID); current == nil || m.shuttingDown {
+		m.mutex.Unlock()
+		return
+	}
+	m.currentJob = job
+	m.currentID = d.UUID
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		m.currentJob = nil
+		m.currentID = ""
+		m.mutex.Unlock()
+	}()
+
+	d.State = db.JobStateTranscoding
+	db.SaveDownload(d)
+	log.WithFields(logFields).WithField("path", fileLocator).Infoln("Creating download")
+
+	err = job.Run(func(progress float64) {
+		db.UpdateDownloadProgress(d, progress)
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if current, _ := db.FindDownloadByUUID(d.UUID); current == nil {
+		os.Remove(outputPath)
+		return
+	}
+
+	d.State = db.JobStateDone
+	d.Progress = 100
+	d.Size = stat.Size()
+	d.FilePath = outputPath
+	d.ExpiresAt = time.Now().Add(*downloadExpiryFlag)
+	db.SaveDownload(d)
+	log.WithFields(logFields).Infoln("Finished creating download")
+}
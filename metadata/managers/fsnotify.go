@@ -1,57 +1,128 @@
 package managers
 
 import (
-	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/filesystem"
+	"strings"
 	"time"
 )
 
+// Bursts of events, e.g. while a directory is copied into the library, are collected for this long
+// before they are handled.
+const fsnotifyDebounce = time.Second
+
+// New files are only probed once their size and modification time haven't changed for this long,
+// so that we don't look at files that are still being written.
+const fileSettleTime = 5 * time.Second
+
+// settlingFile is a new file that we are waiting for to be completely written.
+type settlingFile struct {
+	size    int64
+	modTime time.Time
+	// When the size or modification time last changed
+	since time.Time
+}
+
 func (man *LibraryManager) startWatcher(exitChan chan bool) {
+	if man.Watcher == nil {
+		<-exitChan
+		return
+	}
+
 	log.WithFields(log.Fields{"libraryID": man.Library.ID}).Println("Starting FSNotify watcher")
-loop:
+
+	// Paths with events since the last time we handled them
+	pending := map[string]bool{}
+	settling := map[string]*settlingFile{}
+
+	debounce := time.NewTimer(fsnotifyDebounce)
+	debounce.Stop()
+	settleTicker := time.NewTicker(time.Second)
+	defer settleTicker.Stop()
+
 	for {
 		select {
 		case <-exitChan:
 			log.WithFields(log.Fields{"libraryID": man.Library.ID}).Println("Stopping FSNotify watchers.")
 			man.Watcher.Close()
-			break loop
+			return
 		case event := <-man.Watcher.Events:
 			log.WithFields(log.Fields{"filename": event.Name, "event": event.Op}).Debugln("Got filesystem notification event.")
-
-			if event.Op&fsnotify.Remove == fsnotify.Remove {
-				man.Watcher.Remove(event.Name)
-				man.CheckRemovedFiles() // Make this faster by only scanning the changed file
-				return
+			pending[event.Name] = true
+			debounce.Reset(fsnotifyDebounce)
+		case <-debounce.C:
+			for p := range pending {
+				man.handleChangedPath(p, settling)
 			}
+			pending = map[string]bool{}
+		case <-settleTicker.C:
+			man.probeSettledFiles(settling)
+		case err := <-man.Watcher.Errors:
+			log.Warnln("fsnotify watcher error:", err)
+		}
+	}
+}
 
-			n, err := filesystem.LocalNodeFromPath(event.Name)
-			if err != nil {
-				log.WithFields(log.Fields{"error": err}).Warnln("Error getting node from filesystem.")
-				return
-			}
+// handleChangedPath looks at a path that had filesystem events. If it is gone, e.g. because it was
+// deleted or moved away, the files at or below it are removed from the library. If it is a
+// directory, it and its subdirectories are watched and its files are probed once they settled.
+func (man *LibraryManager) handleChangedPath(p string, settling map[string]*settlingFile) {
+	if man.rootOf(filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: p}) == nil {
+		// Left over from a root that was removed from the library
+		man.Watcher.Remove(p)
+		return
+	}
 
-			if n.IsDir() {
-				man.AddWatcher(event.Name)
-				man.RescanFilesystem()
-			} else if ValidFile(n) {
-				// We are sleeping 2 seconds here in case it's a creation event and the file is 0kb but growing.
-				time.Sleep(2 * time.Second)
+	n, err := filesystem.LocalNodeFromPath(p)
+	if err != nil {
+		log.WithFields(log.Fields{"path": p}).Debugln("Path is gone, removing its files from the library.")
+		man.Watcher.Remove(p)
+		for s := range settling {
+			if s == p || strings.HasPrefix(s, p+"/") {
+				delete(settling, s)
+			}
+		}
+		man.removeMissingFilesInPath(filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: p})
+		return
+	}
 
-				if event.Op&fsnotify.Rename == fsnotify.Rename {
-					log.Debugln("File is renamed, forcing removed files scan.")
-					man.CheckRemovedFiles() // Make this faster by only scanning the changed file
-				}
+	_ = n.Walk(func(walkPath string, n filesystem.Node, err error) error {
+		if err != nil {
+			log.WithFields(log.Fields{"error": err}).
+				Warnf("Received an error while walking %s", walkPath)
+			return nil
+		}
+		if n.IsDir() {
+			man.AddWatcher(walkPath)
+		} else if _, ok := settling[walkPath]; !ok {
+			settling[walkPath] = &settlingFile{size: n.Size(), modTime: n.ModTime(), since: time.Now()}
+		}
+		return nil
+	}, true)
+}
 
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					log.Debugln("File added was added adding watcher and requesting library rescan.")
-					man.AddWatcher(event.Name)
-					man.ProbeFile(n)
-				}
+// probeSettledFiles probes the files that haven't changed for fileSettleTime.
+func (man *LibraryManager) probeSettledFiles(settling map[string]*settlingFile) {
+	for p, s := range settling {
+		n, err := filesystem.LocalNodeFromPath(p)
+		if err != nil {
+			delete(settling, p)
+			continue
+		}
+		if n.Size() != s.size || !n.ModTime().Equal(s.modTime) {
+			s.size = n.Size()
+			s.modTime = n.ModTime()
+			s.since = time.Now()
+			continue
+		}
+		if time.Since(s.since) < fileSettleTime {
+			continue
+		}
 
-			}
-		case err := <-man.Watcher.Errors:
-			log.Warnln("fsnotify watcher error:", err)
+		delete(settling, p)
+		if man.ValidFile(n) {
+			log.WithFields(log.Fields{"path": p}).Debugln("File settled, adding it to the library.")
+			man.checkAndAddProbeJob(n)
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package managers
 
 import (
+	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/ncw/rclone/vfs"
 	log "github.com/sirupsen/logrus"
@@ -8,8 +9,11 @@ import (
 	"gitlab.com/olaris/olaris-server/filesystem"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"gitlab.com/olaris/olaris-server/metadata/managers/metadata"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,6 +30,8 @@ var SupportedExtensions = map[string]bool{
 	".wmv":  true,
 	".mpg":  true,
 	".mpeg": true,
+	// Contain the URL of a video on an HTTP(S) server, see filesystem.ReadStrmFile.
+	filesystem.StrmExtension: true,
 }
 
 type probeJob struct {
@@ -40,40 +46,151 @@ type LibraryManager struct {
 	Pool            *WorkerPool
 	Library         *db.Library
 	exitChan        chan bool
+	rescanStop      chan struct{}
 	isShutingDown   bool
+
+	settingsMutex sync.Mutex
+	settings      *db.LibrarySettings
+
+	// Guards the fields below
+	rootsMutex sync.Mutex
+	roots      []db.LibraryRoot
+	// Closing the channel of an rclone root stops watching it for changes.
+	rootWatchers map[uint]chan struct{}
+
+	// Guards the fields below
+	excludeMutex sync.Mutex
+	// excludeRules are parsed from excludePatterns, which are the library's ExcludePatterns they
+	// were parsed from. nil if they have to be parsed again because the roots changed.
+	excludeRules    *filesystem.IgnoreRules
+	excludePatterns string
 }
 
 // NewLibraryManager creates a new LibraryManager
 func NewLibraryManager(lib *db.Library, metadataManager *metadata.MetadataManager) *LibraryManager {
 	var err error
+	settings := db.FindLibrarySettings(lib.ID)
 	manager := LibraryManager{
 		Library:         lib,
 		metadataManager: metadataManager,
-		Pool:            NewDefaultWorkerPool(),
+		Pool:            NewWorkerPool(settings.ProbeConcurrency),
 		exitChan:        make(chan bool),
+		rescanStop:      make(chan struct{}),
+		settings:        settings,
+		rootWatchers:    map[uint]chan struct{}{},
 	}
 
+	// Local roots can be added later on, so we always need the watcher.
 	manager.Watcher, err = fsnotify.NewWatcher()
 	if err != nil {
 		log.Errorln("Could not start fsnotify")
-	} else {
 	}
 	go manager.startWatcher(manager.exitChan)
+
+	for _, root := range db.FindLibraryRoots(lib) {
+		manager.startRoot(root)
+	}
+	go manager.startRescanScheduler(manager.rescanStop)
 	log.WithFields(log.Fields{"libraryID": lib.ID}).Println("Created new LibraryManager")
 
 	return &manager
 }
 
-// Shutdown shuts down the LibraryManager, right now it's just about cleaning up the fsnotify watcher.
+// Shutdown shuts down the LibraryManager, right now it's just about stopping the change detection.
 func (man *LibraryManager) Shutdown() {
 	log.WithFields(log.Fields{"libraryID": man.Library.ID}).Debugln("Closing down LibraryManager")
 	man.isShutingDown = true
 	man.exitChan <- true
+	close(man.rescanStop)
+
+	man.rootsMutex.Lock()
+	for id, stop := range man.rootWatchers {
+		close(stop)
+		delete(man.rootWatchers, id)
+	}
+	for _, root := range man.roots {
+		if root.IsRclone() {
+			filesystem.ReleaseRcloneVFSOptions(root.RcloneName, man.Library.ID)
+		}
+	}
+	man.rootsMutex.Unlock()
+
 	man.Pool.Shutdown()
 }
 
+// Settings returns the settings of the library.
+func (man *LibraryManager) Settings() *db.LibrarySettings {
+	man.settingsMutex.Lock()
+	defer man.settingsMutex.Unlock()
+	return man.settings
+}
+
+// UpdateSettings saves the given settings of the library and applies them. The VFS options of
+// rclone libraries only take effect once the remote's VFS is created again and have to match those
+// of other libraries on the same remote, see filesystem.SetRcloneVFSOptions.
+func (man *LibraryManager) UpdateSettings(settings *db.LibrarySettings) error {
+	settings.LibraryID = man.Library.ID
+	for _, root := range man.Roots() {
+		if root.IsRclone() {
+			err := filesystem.CheckRcloneVFSOptions(root.RcloneName, man.Library.ID, settings.VFSOptions())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.SaveLibrarySettings(settings); err != nil {
+		return err
+	}
+
+	man.settingsMutex.Lock()
+	man.settings = settings
+	man.settingsMutex.Unlock()
+
+	man.Pool.SetProbeConcurrency(settings.ProbeConcurrency)
+	for _, root := range man.Roots() {
+		if root.IsRclone() {
+			if err := filesystem.SetRcloneVFSOptions(root.RcloneName, man.Library.ID, settings.VFSOptions()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startRescanScheduler rescans the library whenever its rescan interval has passed since the last
+// scan started, until stop is closed.
+func (man *LibraryManager) startRescanScheduler(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if man.rescanDue(now) {
+				log.WithFields(man.Library.LogFields()).Println("Starting scheduled library rescan.")
+				man.RefreshAll()
+			}
+		}
+	}
+}
+
+// rescanDue returns whether the library should be rescanned automatically at the given time.
+func (man *LibraryManager) rescanDue(now time.Time) bool {
+	interval := man.Settings().RescanInterval
+	if interval == 0 || man.isShutingDown {
+		return false
+	}
+	return now.Sub(man.Library.RefreshStartedAt) >= interval
+}
+
 // IdentifyUnidentifiedFiles looks for missing metadata information and attempts to retrieve it.
 func (man *LibraryManager) IdentifyUnidentifiedFiles() {
+	if man.Settings().Agent == db.AgentNone {
+		return
+	}
+
 	log.WithFields(man.Library.LogFields()).
 		Debugln("Trying to identify unidentified files in library.")
 	var err error
@@ -130,22 +247,38 @@ func (man *LibraryManager) IdentifyUnidentifiedMovieFiles() error {
 	return nil
 }
 
+// checkAndAddProbeJob queues the given file for probing unless it was already probed and didn't
+// change since, whether it made it into the library or not.
 func (man *LibraryManager) checkAndAddProbeJob(node filesystem.Node) {
 	library := man.Library
-	if (library.Kind == db.MediaTypeSeries && !db.EpisodeFileExists(node.FileLocator().String())) ||
-		(library.Kind == db.MediaTypeMovie && !db.MovieFileExists(node.FileLocator().String())) {
-
-		// This is really annoying however when a tunny job is added to a closed pool it will throw a panic
-		// Right now a job can still be running when we delete a library this recover catches the fact that the pool is closed but we are still queuing up
-		// TODO: Somebody smarter than me figure out a better way of doing this
-		go func(p *probeJob) {
-			defer checkPanic()
-			man.Pool.probePool.Process(p)
-		}(&probeJob{man: man, node: node})
-	} else {
-		log.WithFields(log.Fields{"path": node.Path()}).
-			Debugln("File already exists in library, not adding again.")
+	filePath := node.FileLocator().String()
+	inLibrary := (library.Kind == db.MediaTypeSeries && db.EpisodeFileExists(filePath)) ||
+		(library.Kind == db.MediaTypeMovie && db.MovieFileExists(filePath))
+
+	seenFile, err := db.FindSeenFile(library.ID, filePath)
+	if err != nil && inLibrary {
+		// The file was added before we kept track of probed files, assume it didn't change since.
+		db.SaveSeenFile(library.ID, filePath, node.Size(), node.ModTime(), db.ProbeResultAdded)
+		seenFile = &db.SeenFile{Size: node.Size(), ModTime: node.ModTime(), ProbeResult: db.ProbeResultAdded}
 	}
+
+	if seenFile != nil && seenFile.Unchanged(node.Size(), node.ModTime()) &&
+		(inLibrary || seenFile.ProbeResult != db.ProbeResultAdded) {
+		log.WithFields(log.Fields{"path": node.Path(), "probeResult": seenFile.ProbeResult}).
+			Debugln("File didn't change since it was last probed, not probing again.")
+		if inLibrary {
+			man.backfillFingerprint(node)
+		}
+		return
+	}
+
+	// This is really annoying however when a tunny job is added to a closed pool it will throw a panic
+	// Right now a job can still be running when we delete a library this recover catches the fact that the pool is closed but we are still queuing up
+	// TODO: Somebody smarter than me figure out a better way of doing this
+	go func(p *probeJob) {
+		defer checkPanic()
+		man.Pool.probePool.Process(p)
+	}(&probeJob{man: man, node: node})
 }
 
 // RescanFilesystem goes over the filesystem and parses filenames in the given library.
@@ -158,40 +291,40 @@ func (man *LibraryManager) RescanFilesystem() {
 	man.Library.RefreshCompletedAt = time.Time{}
 	db.SaveLibrary(man.Library)
 
-	var rootNode filesystem.Node
-	var err error
-
-	// TODO: Should this be in it's own healthCheck method on the library or something?
-	switch man.Library.Backend {
-	case db.BackendLocal:
-		rootNode, err = filesystem.LocalNodeFromPath(man.Library.FilePath)
-	case db.BackendRclone:
-		rootNode, err = filesystem.RcloneNodeFromPath(
-			path.Join(man.Library.RcloneName, man.Library.FilePath))
+	healthy := true
+	for _, root := range man.Roots() {
+		if !man.scanRoot(&root) {
+			healthy = false
+		}
 	}
 
+	dur := time.Since(stime)
+	log.Printf("Probing library '%s' took %f seconds", man.Library.Name, dur.Seconds())
+	man.Library.Healthy = healthy
+	man.Library.RefreshCompletedAt = time.Now()
+	db.SaveLibrary(man.Library)
+}
+
+// scanRoot goes over the files in one of the library's roots and probes the new ones. Returns
+// false if the root can't be accessed.
+func (man *LibraryManager) scanRoot(root *db.LibraryRoot) bool {
+	rootNode, err := libraryRootNode(root)
+	root.Healthy = err == nil
+	if saveErr := db.SaveLibraryRoot(root); saveErr != nil {
+		log.WithFields(root.LogFields()).WithField("error", saveErr).Warnln("Failed to save library root")
+	}
 	if err != nil {
-		log.
-			WithFields(log.Fields{
-				"backend":    man.Library.Backend,
-				"rcloneName": man.Library.RcloneName,
-				"path":       man.Library.FilePath,
-				"error":      err.Error()}).
+		log.WithFields(root.LogFields()).WithField("error", err.Error()).
 			Errorln("Failed to access library filesystem root node")
-		man.Library.Healthy = false
-		db.SaveLibrary(man.Library)
-		return
+		return false
 	}
 
-	man.Library.Healthy = true
-	db.SaveLibrary(man.Library)
-
 	// We don't need to handle the error here because we already handle it in walkFn
 	_ = rootNode.Walk(func(walkPath string, n filesystem.Node, err error) error {
 		if err != nil {
 			log.WithFields(log.Fields{"error": err}).
 				Warnf("Received an error while walking %s", walkPath)
-		} else if ValidFile(n) {
+		} else if man.ValidFile(n) {
 			man.checkAndAddProbeJob(n)
 		}
 		// Watchers are only supported for the local backend
@@ -202,15 +335,44 @@ func (man *LibraryManager) RescanFilesystem() {
 		return nil
 	}, true)
 
-	dur := time.Since(stime)
-	log.Printf("Probing library '%s' took %f seconds", man.Library.FilePath, dur.Seconds())
-	man.Library.RefreshCompletedAt = time.Now()
-	db.SaveLibrary(man.Library)
+	return true
+}
+
+// libraryRootNode returns the node of the given root directory.
+func libraryRootNode(root *db.LibraryRoot) (filesystem.Node, error) {
+	switch root.Backend {
+	case db.BackendLocal:
+		return filesystem.LocalNodeFromPath(root.FilePath)
+	case db.BackendRclone:
+		return filesystem.RcloneNodeFromPath(path.Join(root.RcloneName, root.FilePath))
+	}
+	return nil, fmt.Errorf("unknown backend %d", root.Backend)
+}
+
+// rootAvailable returns whether the given root directory can be accessed. If it can't, e.g.
+// because a drive isn't mounted or the remote is down, files that seem to be missing are most
+// likely still there. An empty local root is treated as unavailable too since that is what an
+// unmounted mount point looks like.
+func rootAvailable(root *db.LibraryRoot) bool {
+	rootNode, err := libraryRootNode(root)
+	if err == nil && rootNode.BackendType() == filesystem.BackendLocal {
+		var names []string
+		var f *os.File
+		if f, err = os.Open(rootNode.Path()); err == nil {
+			names, err = f.Readdirnames(1)
+			f.Close()
+			if err == nil && len(names) == 0 {
+				err = fmt.Errorf("library root is empty")
+			}
+		}
+	}
 
 	if err != nil {
-		log.WithFields(log.Fields{"error": err}).Warnln("Error while probing some files.")
-		return
+		log.WithFields(root.LogFields()).WithField("error", err).
+			Warnln("Library root is unavailable, not checking for missing files")
+		return false
 	}
+	return true
 }
 
 // AddWatcher adds a fsnotify watcher to the given path.
@@ -228,40 +390,93 @@ func (man *LibraryManager) AddWatcher(filePath string) {
 
 // ProbeFile goes over the given file,
 // creates a new entry in the database if required and tries to associate the file with a
-// with metadata based on the filename.
+// with metadata based on the filename. If the file is already in the database, e.g. because it
+// was replaced, its stream information is updated instead. The outcome is recorded so that the
+// file isn't probed again as long as it doesn't change.
 func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 	library := man.Library
 	log.WithFields(log.Fields{"filepath": n.Path()}).Println("Parsing filepath.")
 
 	basename := n.Name()
+	filePath := n.FileLocator().String()
+	// Taken before probing, so that changes while we probe are noticed by the next scan.
+	size, modTime := n.Size(), n.ModTime()
+	recordProbeResult := func(probeResult string) {
+		if err := db.SaveSeenFile(library.ID, filePath, size, modTime, probeResult); err != nil {
+			log.WithFields(log.Fields{"filePath": filePath, "error": err}).
+				Warnln("Failed to record probe result")
+		}
+	}
 
-	log.WithFields(log.Fields{"filePath": n.FileLocator().String()}).
+	log.WithFields(log.Fields{"filePath": filePath}).
 		Debugln("Reading stream information from file")
 
-	streams, err := ffmpeg.GetStreams(n.FileLocator())
+	// A .strm file stands in for the video at the URL in it, which is what gets probed. The file
+	// itself is still what is recorded as seen, so that editing it triggers a new probe.
+	media := n
+	if filesystem.IsStrmFile(n) {
+		target, err := filesystem.GetNodeFromFileLocator(n.FileLocator())
+		if err != nil {
+			log.WithFields(log.Fields{"filePath": filePath, "error": err}).
+				Warnln("Failed to open the URL in .strm file")
+			recordProbeResult(db.ProbeResultFailed)
+			return nil
+		}
+		media = target
+	}
+	mediaSize := media.Size()
+
+	streams, err := ffmpeg.GetStreams(media.FileLocator())
 	if err != nil {
 		log.WithFields(log.Fields{"error": err}).
 			Debugln("Received error while opening file for stream inspection")
+		recordProbeResult(db.ProbeResultFailed)
+		return nil
 	}
 
-	// TODO(Leon Handreke): Ideally, to not have to scan the file at every startup,
-	//  we would somehow create a database entry to remember that we already saw this file.
-	// Ideally, this should happen in ValidFile,
-	// but since we have to open and ffprobe the file, we do it in this async job instead.
 	if len(streams.VideoStreams) == 0 {
-		log.WithFields(log.Fields{"filePath": n.FileLocator().String()}).
+		log.WithFields(log.Fields{"filePath": filePath}).
 			Infoln("File doesn't have any video streams, not adding to library.")
+		recordProbeResult(db.ProbeResultNoVideo)
+		return nil
+	}
+
+	fingerprint, err := filesystem.Fingerprint(media)
+	if err != nil {
+		log.WithFields(log.Fields{"filePath": filePath, "error": err}).
+			Warnln("Failed to compute fingerprint of file")
+	}
+
+	if m := db.FindMediaFileByPath(library.ID, filePath); m != nil {
+		if err := db.UpdateMediaFileContent(m, mediaSize, fingerprint, collectStreams(streams)); err != nil {
+			return err
+		}
+		recordProbeResult(db.ProbeResultAdded)
 		return nil
 	}
 
+	if fingerprint != "" {
+		if m := man.findMovedFile(fingerprint); m != nil {
+			err := db.RelocateMediaFile(m, filePath, basename, collectStreams(streams))
+			if err == nil {
+				recordProbeResult(db.ProbeResultAdded)
+				return nil
+			}
+			log.WithFields(log.Fields{"filePath": filePath, "error": err}).
+				Warnln("Failed to relocate moved file, adding it as a new file")
+			m.DeleteSelfAndMD()
+		}
+	}
+
 	switch kind := library.Kind; kind {
 	case db.MediaTypeSeries:
 		episodeFile := db.EpisodeFile{
 			MediaItem: db.MediaItem{
-				FileName:  basename,
-				FilePath:  n.FileLocator().String(),
-				Size:      n.Size(),
-				LibraryID: library.ID,
+				FileName:    basename,
+				FilePath:    filePath,
+				Size:        mediaSize,
+				LibraryID:   library.ID,
+				Fingerprint: fingerprint,
 			},
 			Streams: collectStreams(streams),
 		}
@@ -269,7 +484,7 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 		db.SaveEpisodeFile(&episodeFile)
 
 		_, err := man.metadataManager.GetOrCreateEpisodeForEpisodeFile(&episodeFile)
-		if err != nil {
+		if err != nil && err != metadata.ErrAgentDisabled {
 			log.
 				WithField("error", err.Error()).
 				WithField("episodeFile", episodeFile).
@@ -279,17 +494,18 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 	case db.MediaTypeMovie:
 		movieFile := db.MovieFile{
 			MediaItem: db.MediaItem{
-				FileName:  basename,
-				FilePath:  n.FileLocator().String(),
-				Size:      n.Size(),
-				LibraryID: library.ID,
+				FileName:    basename,
+				FilePath:    filePath,
+				Size:        mediaSize,
+				LibraryID:   library.ID,
+				Fingerprint: fingerprint,
 			},
 			Streams: collectStreams(streams),
 		}
 		db.CreateMovieFile(&movieFile)
 
 		_, err := man.metadataManager.GetOrCreateMovieForMovieFile(&movieFile)
-		if err != nil {
+		if err != nil && err != metadata.ErrAgentDisabled {
 			log.
 				WithField("error", err.Error()).
 				WithField("movieFile", movieFile).
@@ -297,73 +513,163 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 		}
 
 	}
+	recordProbeResult(db.ProbeResultAdded)
 	return nil
 }
 
-// ValidFile checks whether the supplied filepath is a file that can be indexed by the metadata server.
-func ValidFile(node filesystem.Node) bool {
+// rootPath returns the path of the given root in the form node paths of its backend have.
+func rootPath(root *db.LibraryRoot) string {
+	if root.IsRclone() {
+		return strings.Trim(root.FilePath, "/")
+	}
+	return filepath.Clean(root.FilePath)
+}
+
+// excluded returns whether the given node matches one of the library's exclude patterns. The
+// patterns are relative to each of the library's roots.
+func (man *LibraryManager) excluded(node filesystem.Node) bool {
+	man.excludeMutex.Lock()
+	if man.excludeRules == nil || man.excludePatterns != man.Library.ExcludePatterns {
+		man.excludePatterns = man.Library.ExcludePatterns
+		rules := filesystem.IgnoreRules{}
+		for _, root := range man.Roots() {
+			rules = rules.With(rootPath(&root), man.excludePatterns)
+		}
+		man.excludeRules = &rules
+	}
+	rules := man.excludeRules
+	man.excludeMutex.Unlock()
+
+	return rules.IgnoredPath(node.Path(), node.IsDir())
+}
+
+// supportedExtensions returns the extensions of the files that are scanned in this library.
+func (man *LibraryManager) supportedExtensions() map[string]bool {
+	if man.Library.Extensions == "" {
+		return SupportedExtensions
+	}
+	extensions := map[string]bool{}
+	for _, ext := range strings.Split(man.Library.Extensions, ",") {
+		extensions[strings.TrimSpace(ext)] = true
+	}
+	return extensions
+}
+
+// ValidFile checks whether the supplied node is a file that can be indexed in this library.
+func (man *LibraryManager) ValidFile(node filesystem.Node) bool {
 	filePath := node.Name()
 	if node.IsDir() {
 		log.WithFields(log.Fields{"filepath": filePath}).Debugln("File is a directory, not scanning as file.")
 		return false
 	}
 
-	if !SupportedExtensions[filepath.Ext(filePath)] {
+	if man.rootOf(node.FileLocator()) == nil {
+		log.WithFields(log.Fields{"filepath": node.Path()}).Debugln("File is not in one of the library's roots, file won't be indexed.")
+		return false
+	}
+
+	if !man.supportedExtensions()[filepath.Ext(filePath)] {
 		log.WithFields(log.Fields{"extension": filepath.Ext(filePath), "filepath": filePath}).Debugln("File is not a valid media file, file won't be indexed.")
 		return false
 	}
 
 	// Ignore really small files
-	if node.Size() < MinFileSize {
+	minFileSize := man.Library.MinFileSize
+	if minFileSize == 0 {
+		minFileSize = MinFileSize
+	}
+	if node.Size() < minFileSize && !filesystem.IsStrmFile(node) {
 		log.WithFields(log.Fields{"size": node.Size(), "filepath": filePath}).
 			Debugln("File is too small, file won't be indexed.")
 		return false
 	}
 
+	if man.excluded(node) {
+		log.WithFields(log.Fields{"filepath": node.Path()}).
+			Debugln("File matches an exclude pattern of the library, file won't be indexed.")
+		return false
+	}
+
 	return true
 }
 
-// CheckFileAndDeleteIfMissing checks the given media file and if it's no longer present removes it from the database
+// CheckFileAndDeleteIfMissing checks the given media file and if it's no longer present marks it as missing, which hides
+// it until it is either found again or purged.
 func CheckFileAndDeleteIfMissing(m db.MediaFile) {
 	log.WithFields(log.Fields{
 		"path":    m.GetFilePath(),
 		"library": m.GetLibrary().Name,
 	}).Debugln("Checking to see if file still exists.")
 
-	switch m.GetLibrary().Backend {
-	case db.BackendLocal:
-		p, err := filesystem.ParseFileLocator(m.GetFilePath())
+	p, err := filesystem.ParseFileLocator(m.GetFilePath())
+	if err != nil {
+		log.WithFields(log.Fields{"error": err}).Warnln("Received invalid file locator")
+		return
+	}
+
+	// The roots of a library can be on different backends, so go by the file's own.
+	switch p.Backend {
+	case filesystem.BackendLocal:
 		//		log.WithFields(log.Fields{"path": p.Path}).Debugln("Checking on local")
 		_, err = filesystem.LocalNodeFromPath(p.Path)
 		// TODO(Leon Handreke): Check if the error is actually not found
 		if err != nil {
 			log.WithFields(log.Fields{"error": err}).Warnln("Received error while statting file")
-			m.DeleteSelfAndMD()
+			removeMissingFile(m)
 		}
-	case db.BackendRclone:
-		p, err := filesystem.ParseFileLocator(m.GetFilePath())
+	case filesystem.BackendRclone:
 		//		log.WithFields(log.Fields{"path": p.Path}).Debugln("Checking on Rclone")
 		_, err = filesystem.RcloneNodeFromPath(p.Path)
 		if err != nil {
 			log.WithFields(log.Fields{"error": err}).Warnln("Received error while statting file")
 			// We only delete on the file does not exist error. Any other errors are not enough reason to wipe the content.
 			if err == vfs.ENOENT {
-				m.DeleteSelfAndMD()
+				removeMissingFile(m)
 			}
 		}
 	}
 }
 
+// removeMissingFilesInPath removes the library's files at or below the given path that no longer
+// exist, e.g. after a file or a whole directory was deleted or moved away.
+func (man *LibraryManager) removeMissingFilesInPath(fileLocator filesystem.FileLocator) {
+	root := man.rootOf(fileLocator)
+	if root == nil || !rootAvailable(root) {
+		return
+	}
+	for _, file := range db.FindMediaFilesInPath(man.Library.ID, fileLocator.String()) {
+		CheckFileAndDeleteIfMissing(file)
+	}
+	forgetMissingSeenFiles(db.FindSeenFilesInPath(man.Library.ID, fileLocator.String()))
+}
+
+// forgetMissingSeenFiles removes the record of having probed a file for files that no longer exist.
+func forgetMissingSeenFiles(seenFiles []db.SeenFile) {
+	for _, seenFile := range seenFiles {
+		fileLocator, err := filesystem.ParseFileLocator(seenFile.FilePath)
+		if err == nil {
+			_, err = filesystem.GetNodeFromFileLocator(fileLocator)
+		}
+		if err != nil {
+			db.DeleteSeenFile(&seenFile)
+		}
+	}
+}
+
 // CheckRemovedFiles checks all files in the database to ensure they still exist, if not it attempts to remove the MD information from the db.
 func (man *LibraryManager) CheckRemovedFiles() {
 	log.WithFields(log.Fields{"libraryID": man.Library.ID}).Infoln("Checking for removed files.")
 
-	for _, movieFile := range db.FindMovieFilesInLibrary(man.Library.ID) {
-		CheckFileAndDeleteIfMissing(movieFile)
-	}
-
-	for _, file := range db.FindEpisodeFilesInLibrary(man.Library.ID) {
-		CheckFileAndDeleteIfMissing(file)
+	// Only the files of roots that are available, the others are most likely still there.
+	for _, root := range man.Roots() {
+		if !rootAvailable(&root) {
+			continue
+		}
+		rootLocator := root.FileLocator().String()
+		for _, file := range db.FindMediaFilesInPath(man.Library.ID, rootLocator) {
+			CheckFileAndDeleteIfMissing(file)
+		}
+		forgetMissingSeenFiles(db.FindSeenFilesInPath(man.Library.ID, rootLocator))
 	}
 }
 
@@ -371,12 +677,15 @@ func (man *LibraryManager) CheckRemovedFiles() {
 func (man *LibraryManager) RefreshAll() {
 	man.CheckRemovedFiles()
 
-	if man.Library.IsLocal() {
-		man.AddWatcher(man.Library.FilePath)
+	for _, root := range man.Roots() {
+		if root.IsLocal() {
+			man.AddWatcher(root.FilePath)
+		}
 	}
 
 	man.RescanFilesystem()
 	man.IdentifyUnidentifiedFiles()
+	man.GenerateMissingArtwork()
 }
 
 func checkPanic() {
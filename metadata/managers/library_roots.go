@@ -0,0 +1,126 @@
+package managers
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// Roots returns the root directories of the library.
+func (man *LibraryManager) Roots() []db.LibraryRoot {
+	man.rootsMutex.Lock()
+	defer man.rootsMutex.Unlock()
+	return append([]db.LibraryRoot{}, man.roots...)
+}
+
+// rootOf returns the root of the library that contains the given file locator, or nil if it isn't
+// in the library.
+func (man *LibraryManager) rootOf(fileLocator filesystem.FileLocator) *db.LibraryRoot {
+	for _, root := range man.Roots() {
+		if root.Contains(fileLocator) {
+			return &root
+		}
+	}
+	return nil
+}
+
+// startRoot adds the given root to the ones the manager works with and starts watching it for
+// changes. Local roots are watched through fsnotify once they are scanned.
+func (man *LibraryManager) startRoot(root db.LibraryRoot) {
+	man.rootsMutex.Lock()
+	man.roots = append(man.roots, root)
+	if root.IsRclone() {
+		// New roots were checked before they were added, but existing ones may still disagree.
+		err := filesystem.SetRcloneVFSOptions(root.RcloneName, man.Library.ID, man.Settings().VFSOptions())
+		if err != nil {
+			log.WithFields(root.LogFields()).WithField("error", err).
+				Warnln("Ignoring the library's VFS options for this root")
+		}
+		stop := make(chan struct{})
+		man.rootWatchers[root.ID] = stop
+		go man.startRcloneWatcher(root, stop)
+	}
+	man.rootsMutex.Unlock()
+
+	man.forgetExcludeRules()
+}
+
+// forgetExcludeRules makes the exclude rules be parsed again, relative to the current roots.
+func (man *LibraryManager) forgetExcludeRules() {
+	man.excludeMutex.Lock()
+	man.excludeRules = nil
+	man.excludeMutex.Unlock()
+}
+
+// AddRoot adds another root directory to the library and scans it.
+func (man *LibraryManager) AddRoot(root *db.LibraryRoot) error {
+	root.LibraryID = man.Library.ID
+	if root.IsRclone() {
+		err := filesystem.CheckRcloneVFSOptions(root.RcloneName, man.Library.ID, man.Settings().VFSOptions())
+		if err != nil {
+			return err
+		}
+	}
+	if err := db.AddLibraryRoot(root); err != nil {
+		return err
+	}
+
+	man.startRoot(*root)
+	go man.RefreshAll()
+	return nil
+}
+
+// RemoveRoot removes a root directory from the library together with the files in it.
+func (man *LibraryManager) RemoveRoot(rootID uint) error {
+	var root *db.LibraryRoot
+	for _, r := range man.Roots() {
+		if r.ID == rootID {
+			root = &r
+			break
+		}
+	}
+	if root == nil {
+		return fmt.Errorf("library root not found")
+	}
+
+	if err := db.DeleteLibraryRoot(root); err != nil {
+		return err
+	}
+	log.WithFields(root.LogFields()).Infoln("Removed library root")
+
+	man.rootsMutex.Lock()
+	for i, r := range man.roots {
+		if r.ID == rootID {
+			man.roots = append(man.roots[:i], man.roots[i+1:]...)
+			break
+		}
+	}
+	if stop, ok := man.rootWatchers[rootID]; ok {
+		close(stop)
+		delete(man.rootWatchers, rootID)
+	}
+	remoteInUse := false
+	for _, r := range man.roots {
+		if r.IsRclone() && r.RcloneName == root.RcloneName {
+			remoteInUse = true
+		}
+	}
+	man.rootsMutex.Unlock()
+
+	if root.IsRclone() && !remoteInUse {
+		filesystem.ReleaseRcloneVFSOptions(root.RcloneName, man.Library.ID)
+	}
+
+	if root.IsLocal() && man.Watcher != nil {
+		man.Watcher.Remove(root.FilePath)
+	}
+	man.forgetExcludeRules()
+
+	// Removing the root the library was created with moves its own path to another root.
+	lib := db.FindLibrary(int(man.Library.ID))
+	man.Library.Backend = lib.Backend
+	man.Library.RcloneName = lib.RcloneName
+	man.Library.FilePath = lib.FilePath
+	return nil
+}
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"errors"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/metadata/agents"
 	"gitlab.com/olaris/olaris-server/metadata/db"
@@ -32,6 +33,32 @@ func NewMetadataManager(agent agents.MetadataRetrievalAgent) *MetadataManager {
 	}
 }
 
+// ErrAgentDisabled is returned when a file can't be identified automatically because its library
+// doesn't use a metadata agent.
+var ErrAgentDisabled = errors.New("library doesn't use a metadata agent, files have to be identified by hand")
+
+// agentForLibrary returns the agent that retrieves the metadata of the given library, localized
+// to the library's metadata language and region if the agent supports that.
+func (m *MetadataManager) agentForLibrary(libraryID uint) agents.MetadataRetrievalAgent {
+	if libraryID == 0 {
+		return m.agent
+	}
+	settings := db.FindLibrarySettings(libraryID)
+	if settings.MetadataLanguage == "" && settings.MetadataRegion == "" {
+		return m.agent
+	}
+	if a, ok := m.agent.(agents.LocalizedAgent); ok {
+		return a.Localized(settings.MetadataLanguage, settings.MetadataRegion)
+	}
+	return m.agent
+}
+
+// identifiesAutomatically returns whether new files of the given library are looked up with the
+// metadata agent.
+func identifiesAutomatically(libraryID uint) bool {
+	return db.FindLibrarySettings(libraryID).Agent != db.AgentNone
+}
+
 // RefreshAgentMetadataWithMissingArt loops over all series/episodes/seasons and movies with missing art (posters/backdrop) and tries to retrieve them.
 func (m *MetadataManager) RefreshAgentMetadataWithMissingArt() {
 	log.Debugln("Checking and updating media items for missing art.")
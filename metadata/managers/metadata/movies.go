@@ -5,6 +5,7 @@ import (
 	"github.com/ryanbradynd05/go-tmdb"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/helpers/levenshtein"
+	"gitlab.com/olaris/olaris-server/metadata/agents"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"gitlab.com/olaris/olaris-server/metadata/parsers"
 	"math"
@@ -22,10 +23,14 @@ func (m *MetadataManager) ForceMovieMetadataUpdate() {
 
 // UpdateMovieMD updates the database record with the latest data from the agent
 func (m *MetadataManager) UpdateMovieMD(movie *db.Movie) error {
+	return m.updateMovieMD(m.agentForLibrary(db.FindLibraryIDForMovie(movie.ID)), movie)
+}
+
+func (m *MetadataManager) updateMovieMD(agent agents.MetadataRetrievalAgent, movie *db.Movie) error {
 	log.WithFields(log.Fields{"title": movie.Title}).
 		Println("Refreshing metadata for movie.")
 
-	if err := m.agent.UpdateMovieMD(movie, movie.TmdbID); err != nil {
+	if err := agent.UpdateMovieMD(movie, movie.TmdbID); err != nil {
 		return err
 	}
 	// TODO(Leon Handreke): return an error here.
@@ -44,6 +49,11 @@ func (m *MetadataManager) GetOrCreateMovieForMovieFile(
 		return db.FindMovieByID(movieFile.MovieID)
 	}
 
+	if !identifiesAutomatically(movieFile.LibraryID) {
+		return nil, ErrAgentDisabled
+	}
+	agent := m.agentForLibrary(movieFile.LibraryID)
+
 	name := strings.TrimSuffix(movieFile.FileName, filepath.Ext(movieFile.FileName))
 	parsedInfo := parsers.ParseMovieName(name)
 
@@ -51,7 +61,7 @@ func (m *MetadataManager) GetOrCreateMovieForMovieFile(
 	if parsedInfo.Year > 0 {
 		options["year"] = strconv.FormatUint(parsedInfo.Year, 10)
 	}
-	searchRes, err := m.agent.TmdbSearchMovie(parsedInfo.Title, options)
+	searchRes, err := agent.TmdbSearchMovie(parsedInfo.Title, options)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +87,7 @@ func (m *MetadataManager) GetOrCreateMovieForMovieFile(
 		}
 	}
 
-	movie, err := m.GetOrCreateMovieByTmdbID(bestResult.ID)
+	movie, err := m.getOrCreateMovieByTmdbID(agent, bestResult.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +102,11 @@ func (m *MetadataManager) GetOrCreateMovieForMovieFile(
 // GetOrCreateMovieByTmdbID gets or creates a Movie object in the database,
 // populating it with the details of the movie indicated by the TMDB ID.
 func (m *MetadataManager) GetOrCreateMovieByTmdbID(tmdbID int) (*db.Movie, error) {
+	return m.getOrCreateMovieByTmdbID(m.agent, tmdbID)
+}
+
+func (m *MetadataManager) getOrCreateMovieByTmdbID(
+	agent agents.MetadataRetrievalAgent, tmdbID int) (*db.Movie, error) {
 
 	// Lock so that we don't create the same movie twice
 	m.moviesCreationMutex.Lock()
@@ -103,7 +118,7 @@ func (m *MetadataManager) GetOrCreateMovieByTmdbID(tmdbID int) (*db.Movie, error
 	}
 
 	movie = &db.Movie{BaseItem: db.BaseItem{TmdbID: tmdbID}}
-	if err := m.UpdateMovieMD(movie); err != nil {
+	if err := m.updateMovieMD(agent, movie); err != nil {
 		return nil, err
 	}
 
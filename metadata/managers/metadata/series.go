@@ -6,6 +6,7 @@ import (
 	errors2 "github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/helpers/levenshtein"
+	"gitlab.com/olaris/olaris-server/metadata/agents"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"gitlab.com/olaris/olaris-server/metadata/parsers"
 	"math"
@@ -53,16 +54,24 @@ func (m *MetadataManager) ForceSeriesMetadataUpdate() {
 
 // UpdateSeriesMD loops over all series with no tmdb information yet and attempts to retrieve the metadata.
 func (m *MetadataManager) UpdateSeriesMD(series *db.Series) error {
+	return m.updateSeriesMD(m.agentForLibrary(db.FindLibraryIDForSeries(series.ID)), series)
+}
+
+func (m *MetadataManager) updateSeriesMD(agent agents.MetadataRetrievalAgent, series *db.Series) error {
 	log.WithFields(log.Fields{"name": series.Name}).
 		Println("Refreshing metadata for series.")
-	m.agent.UpdateSeriesMD(series, series.TmdbID)
+	agent.UpdateSeriesMD(series, series.TmdbID)
 	db.SaveSeries(series)
 	return nil
 }
 
 // UpdateEpisodeMD updates the database record with the latest data from the agent
 func (m *MetadataManager) UpdateEpisodeMD(ep *db.Episode) error {
-	if err := m.agent.UpdateEpisodeMD(ep,
+	return m.updateEpisodeMD(m.agentForLibrary(db.FindLibraryIDForSeries(ep.GetSeries().ID)), ep)
+}
+
+func (m *MetadataManager) updateEpisodeMD(agent agents.MetadataRetrievalAgent, ep *db.Episode) error {
+	if err := agent.UpdateEpisodeMD(ep,
 		ep.GetSeries().TmdbID, ep.GetSeason().SeasonNumber, ep.EpisodeNum); err != nil {
 		return err
 	}
@@ -75,7 +84,11 @@ func (m *MetadataManager) UpdateEpisodeMD(ep *db.Episode) error {
 
 // UpdateSeasonMD updates the database record with the latest data from the agent
 func (m *MetadataManager) UpdateSeasonMD(season *db.Season) error {
-	if err := m.agent.UpdateSeasonMD(
+	return m.updateSeasonMD(m.agentForLibrary(db.FindLibraryIDForSeries(season.GetSeries().ID)), season)
+}
+
+func (m *MetadataManager) updateSeasonMD(agent agents.MetadataRetrievalAgent, season *db.Season) error {
+	if err := agent.UpdateSeasonMD(
 		season, season.GetSeries().TmdbID, season.SeasonNumber); err != nil {
 		return err
 	}
@@ -95,6 +108,11 @@ func (m *MetadataManager) GetOrCreateEpisodeForEpisodeFile(
 		return db.FindEpisodeByID(episodeFile.EpisodeID)
 	}
 
+	if !identifiesAutomatically(episodeFile.LibraryID) {
+		return nil, ErrAgentDisabled
+	}
+	agent := m.agentForLibrary(episodeFile.LibraryID)
+
 	name := strings.TrimSuffix(episodeFile.FileName, filepath.Ext(episodeFile.FileName))
 	parsedInfo := parsers.ParseSerieName(name)
 
@@ -108,7 +126,7 @@ func (m *MetadataManager) GetOrCreateEpisodeForEpisodeFile(
 	if parsedInfo.Year != 0 {
 		options["first_air_date_year"] = strconv.FormatUint(parsedInfo.Year, 10)
 	}
-	searchRes, err := m.agent.TmdbSearchTv(parsedInfo.Title, options)
+	searchRes, err := agent.TmdbSearchTv(parsedInfo.Title, options)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +151,7 @@ func (m *MetadataManager) GetOrCreateEpisodeForEpisodeFile(
 	}
 	seriesInfo := searchRes.Results[bestResultIdx]
 
-	episode, err := m.GetOrCreateEpisodeByTmdbID(
+	episode, err := m.getOrCreateEpisodeByTmdbID(agent,
 		seriesInfo.ID, parsedInfo.SeasonNum, parsedInfo.EpisodeNum)
 	if err != nil {
 		return nil, err
@@ -152,8 +170,13 @@ func (m *MetadataManager) GetOrCreateEpisodeForEpisodeFile(
 // populating it with the details of the episode indicated by the TMDB ID.
 func (m *MetadataManager) GetOrCreateEpisodeByTmdbID(
 	seriesTmdbID int, seasonNum int, episodeNum int) (*db.Episode, error) {
+	return m.getOrCreateEpisodeByTmdbID(m.agent, seriesTmdbID, seasonNum, episodeNum)
+}
+
+func (m *MetadataManager) getOrCreateEpisodeByTmdbID(agent agents.MetadataRetrievalAgent,
+	seriesTmdbID int, seasonNum int, episodeNum int) (*db.Episode, error) {
 
-	season, err := m.getOrCreateSeasonByTmdbID(seriesTmdbID, seasonNum)
+	season, err := m.getOrCreateSeasonByTmdbID(agent, seriesTmdbID, seasonNum)
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +192,7 @@ func (m *MetadataManager) GetOrCreateEpisodeByTmdbID(
 	}
 
 	episode = &db.Episode{Season: season, SeasonID: season.ID, EpisodeNum: episodeNum}
-	if err := m.UpdateEpisodeMD(episode); err != nil {
+	if err := m.updateEpisodeMD(agent, episode); err != nil {
 		return nil, err
 	}
 
@@ -180,7 +203,7 @@ func (m *MetadataManager) GetOrCreateEpisodeByTmdbID(
 	return episode, nil
 }
 
-func (m *MetadataManager) getOrCreateSeriesByTmdbID(
+func (m *MetadataManager) getOrCreateSeriesByTmdbID(agent agents.MetadataRetrievalAgent,
 	seriesTmdbID int) (*db.Series, error) {
 
 	// Lock so that we don't create the same series twice
@@ -193,7 +216,7 @@ func (m *MetadataManager) getOrCreateSeriesByTmdbID(
 	}
 
 	series = &db.Series{BaseItem: db.BaseItem{TmdbID: seriesTmdbID}}
-	if err := m.UpdateSeriesMD(series); err != nil {
+	if err := m.updateSeriesMD(agent, series); err != nil {
 		return nil, err
 	}
 
@@ -204,10 +227,10 @@ func (m *MetadataManager) getOrCreateSeriesByTmdbID(
 	return series, nil
 }
 
-func (m *MetadataManager) getOrCreateSeasonByTmdbID(
+func (m *MetadataManager) getOrCreateSeasonByTmdbID(agent agents.MetadataRetrievalAgent,
 	seriesTmdbID int, seasonNum int) (*db.Season, error) {
 
-	series, err := m.getOrCreateSeriesByTmdbID(seriesTmdbID)
+	series, err := m.getOrCreateSeriesByTmdbID(agent, seriesTmdbID)
 	if err != nil {
 		return nil, err
 	}
@@ -222,7 +245,7 @@ func (m *MetadataManager) getOrCreateSeasonByTmdbID(
 	}
 
 	season = &db.Season{Series: series, SeriesID: series.ID, SeasonNumber: seasonNum}
-	if err := m.UpdateSeasonMD(season); err != nil {
+	if err := m.updateSeasonMD(agent, season); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,60 @@
+package managers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// removeMissingFile takes care of a file that is no longer present. It is only marked as missing
+// so that its record can be reused if it was just moved and is found again under its new path, or
+// if it comes back, e.g. when a drive is mounted again. The TrashManager purges it eventually.
+func removeMissingFile(m db.MediaFile) {
+	if err := db.TrashMediaFile(m); err != nil {
+		log.WithFields(log.Fields{"path": m.GetFilePath(), "error": err}).
+			Warnln("Failed to mark missing file as unavailable")
+	}
+}
+
+// findMovedFile returns the file in the library that the file with the given fingerprint was
+// before it was moved or renamed, or nil if it is a genuinely new file. Besides the files we know
+// are missing, this also considers files whose disappearance we haven't noticed yet, since the new
+// path is sometimes reported before the old one.
+func (man *LibraryManager) findMovedFile(fingerprint string) db.MediaFile {
+	if files := db.FindMissingMediaFilesByFingerprint(man.Library.ID, fingerprint); len(files) > 0 {
+		return files[0]
+	}
+
+	for _, m := range db.FindMediaFilesByFingerprint(man.Library.ID, fingerprint) {
+		fileLocator, err := filesystem.ParseFileLocator(m.GetFilePath())
+		if err != nil {
+			continue
+		}
+		if _, err := filesystem.GetNodeFromFileLocator(fileLocator); err == nil {
+			// Still there, so the new file is a copy.
+			continue
+		}
+		return m
+	}
+	return nil
+}
+
+// backfillFingerprint computes the fingerprint of a file that is already in the library but was
+// added before we stored fingerprints, so that it can be recognized if it is moved later on.
+func (man *LibraryManager) backfillFingerprint(n filesystem.Node) {
+	filePath := n.FileLocator().String()
+	if !db.MediaFileNeedsFingerprint(man.Library.ID, filePath) {
+		return
+	}
+
+	fingerprint, err := filesystem.Fingerprint(n)
+	if err != nil {
+		log.WithFields(log.Fields{"path": filePath, "error": err}).
+			Debugln("Failed to compute fingerprint of file")
+		return
+	}
+	if err := db.SetMediaFileFingerprint(man.Library.ID, filePath, fingerprint); err != nil {
+		log.WithFields(log.Fields{"path": filePath, "error": err}).
+			Warnln("Failed to store fingerprint of file")
+	}
+}
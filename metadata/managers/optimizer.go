@@ -0,0 +1,239 @@
+package managers
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"os"
+	"path"
+	"sync"
+)
+
+var optimizedVersionsDirFlag = flag.String(
+	"optimized_versions_dir",
+	"",
+	"Directory in which pre-transcoded optimized versions of media files are stored. Defaults to a folder in the config directory.")
+
+// optimizedVersionsDir returns the managed directory that optimized versions are written to.
+func optimizedVersionsDir() string {
+	if *optimizedVersionsDirFlag != "" {
+		return *optimizedVersionsDirFlag
+	}
+	return path.Join(helpers.BaseConfigPath(), "optimized")
+}
+
+// OptimizationManager transcodes queued optimized versions in the background. Only one job runs
+// at a time since each of them will happily use all available cores.
+type OptimizationManager struct {
+	// wakeChan is signalled when new versions are queued.
+	wakeChan chan bool
+	exitChan chan bool
+
+	// Guards the fields below
+	mutex        sync.Mutex
+	currentJob   *ffmpeg.FileTranscodingJob
+	currentID    string
+	shuttingDown bool
+}
+
+// NewOptimizationManager creates a new OptimizationManager and starts working on any versions
+// that are still queued from a previous run.
+func NewOptimizationManager() *OptimizationManager {
+	// Versions that were being transcoded when we were shut down have to start over.
+	for _, v := range db.FindOptimizedVersionsInState(db.JobStateTranscoding) {
+		v.State = db.JobStateQueued
+		v.Progress = 0
+		db.SaveOptimizedVersion(&v)
+	}
+
+	m := &OptimizationManager{
+		wakeChan: make(chan bool, 1),
+		exitChan: make(chan bool),
+	}
+	go m.work()
+
+	return m
+}
+
+// Queue queues an optimized version of the given file with the given video encoder preset.
+func (m *OptimizationManager) Queue(file db.MediaFile, preset string) (*db.OptimizedVersion, error) {
+	if !ffmpeg.IsVideoEncoderPreset(preset) {
+		return nil, fmt.Errorf("no preset \"%s\"", preset)
+	}
+
+	v, err := db.QueueOptimizedVersion(file, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case m.wakeChan <- true:
+	default:
+	}
+	return v, nil
+}
+
+// Cancel cancels the given optimized version if it is queued or currently being transcoded.
+func (m *OptimizationManager) Cancel(v *db.OptimizedVersion) error {
+	if v.State != db.JobStateQueued && v.State != db.JobStateTranscoding {
+		return fmt.Errorf("optimized version is %s, cannot cancel", v.State)
+	}
+
+	v.State = db.JobStateCancelled
+	if err := db.SaveOptimizedVersion(v); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.currentID == v.UUID && m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+	return nil
+}
+
+// Delete cancels the given optimized version if required and removes it.
+func (m *OptimizationManager) Delete(v *db.OptimizedVersion) {
+	m.Cancel(v)
+	db.DeleteOptimizedVersion(v)
+}
+
+// Shutdown stops the OptimizationManager, cancelling the running job.
+func (m *OptimizationManager) Shutdown() {
+	m.mutex.Lock()
+	m.shuttingDown = true
+	if m.currentJob != nil {
+		m.currentJob.Cancel()
+	}
+	m.mutex.Unlock()
+
+	m.exitChan <- true
+}
+
+func (m *OptimizationManager) work() {
+	for {
+		queued := db.FindOptimizedVersionsInState(db.JobStateQueued)
+		if len(queued) == 0 {
+			select {
+			case <-m.wakeChan:
+				continue
+			case <-m.exitChan:
+				return
+			}
+		}
+
+		select {
+		case <-m.exitChan:
+			return
+		default:
+		}
+
+		m.transcode(&queued[0])
+	}
+}
+
+func (m *OptimizationManager) transcode(v *db.OptimizedVersion) {
+	logFields := log.Fields{"uuid": v.UUID, "preset": v.Preset}
+
+	fail := func(err error) {
+		log.WithFields(logFields).WithField("error", err).Warnln("Failed to create optimized version")
+		// Jobs interrupted by a shutdown are started over on the next start
+		m.mutex.Lock()
+		shuttingDown := m.shuttingDown
+		m.mutex.Unlock()
+		if shuttingDown {
+			return
+		}
+		// Don't overwrite a cancellation or deletion that happened in the meantime
+		if current, _ := db.FindOptimizedVersionByUUID(v.UUID); current == nil ||
+			current.State == db.JobStateCancelled {
+			return
+		}
+		v.State = db.JobStateFailed
+		v.Error = err.Error()
+		db.SaveOptimizedVersion(v)
+	}
+
+	file, err := v.GetSourceFile()
+	if err != nil {
+		fail(err)
+		return
+	}
+	fileLocator, err := filesystem.ParseFileLocator(file.GetFilePath())
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	dir := optimizedVersionsDir()
+	if err := helpers.EnsurePath(dir); err != nil {
+		fail(err)
+		return
+	}
+	outputPath := path.Join(dir, v.UUID+".mp4")
+
+	job, err := ffmpeg.NewFileTranscodingJob(
+		fileLocator, ffmpeg.TranscodeToFileOptions{Preset: v.Preset}, outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	m.mutex.Lock()
+	if m.shuttingDown {
+		m.mutex.Unlock()
+		return
+	}
+	// The version may have been cancelled while we were getting ready, in which case it is no
+	// longer queued. From here on, Cancel will find the job and stop it.
+	started, err := db.StartOptimizedVersion(v)
+	if !started {
+		m.mutex.Unlock()
+		if err != nil {
+			fail(err)
+		}
+		return
+	}
+	m.currentJob = job
+	m.currentID = v.UUID
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		m.currentJob = nil
+		m.currentID = ""
+		m.mutex.Unlock()
+	}()
+
+	log.WithFields(logFields).WithField("path", fileLocator).Infoln("Creating optimized version")
+
+	err = job.Run(func(progress float64) {
+		db.UpdateOptimizedVersionProgress(v, progress)
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if current, _ := db.FindOptimizedVersionByUUID(v.UUID); current == nil ||
+		current.State == db.JobStateCancelled {
+		os.Remove(outputPath)
+		return
+	}
+
+	v.State = db.JobStateDone
+	v.Progress = 100
+	v.Size = stat.Size()
+	v.FilePath = filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: outputPath}.String()
+	db.SaveOptimizedVersion(v)
+	log.WithFields(logFields).Infoln("Finished creating optimized version")
+}
@@ -0,0 +1,385 @@
+package managers
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"sync"
+	"time"
+)
+
+// Parties without any activity for this long are closed.
+const partyIdleTimeout = 6 * time.Hour
+
+// If a participant's reported position is further than this from the party's position, they are
+// told to seek.
+const partyMaxDrift = 2 * time.Second
+
+// Number of events buffered per subscriber before events are dropped.
+const partySubscriberBuffer = 16
+
+// Party event types
+const (
+	PartyEventPlay   = "play"
+	PartyEventPause  = "pause"
+	PartyEventSeek   = "seek"
+	PartyEventJoin   = "join"
+	PartyEventLeave  = "leave"
+	PartyEventInvite = "invite"
+	PartyEventClosed = "closed"
+	// PartyEventSync is only sent to a single participant whose playback drifted.
+	PartyEventSync = "sync"
+)
+
+// Party is a snapshot of a watch-together room in which all participants play the same media item
+// in sync.
+type Party struct {
+	ID        string
+	HostID    uint
+	MediaUUID string
+	// AllowMemberControl allows participants other than the host to play, pause and seek.
+	AllowMemberControl bool
+	// Users that may join, including the host.
+	Members []uint
+	// Users that are currently in the party.
+	Participants []uint
+	Playing      bool
+	// Position in seconds at the time of the last playback change.
+	Position  float64
+	UpdatedAt time.Time
+}
+
+// CurrentPosition returns where playback should be right now in seconds.
+func (p *Party) CurrentPosition() float64 {
+	if !p.Playing {
+		return p.Position
+	}
+	return p.Position + time.Since(p.UpdatedAt).Seconds()
+}
+
+// PartyEvent is sent to the subscribers of a party.
+type PartyEvent struct {
+	Type   string
+	Party  Party
+	UserID uint
+}
+
+type partySubscriber struct {
+	userID uint
+	events chan PartyEvent
+}
+
+type party struct {
+	Party
+	members      map[uint]bool
+	participants map[uint]bool
+	subscribers  map[*partySubscriber]bool
+}
+
+// PartyManager keeps track of watch-together parties and broadcasts playback changes to their
+// participants.
+type PartyManager struct {
+	// Guards parties
+	mutex   sync.Mutex
+	parties map[string]*party
+}
+
+// NewPartyManager creates a new PartyManager.
+func NewPartyManager() *PartyManager {
+	return &PartyManager{parties: map[string]*party{}}
+}
+
+func (p *party) snapshot() Party {
+	s := p.Party
+	s.Members = []uint{}
+	for userID := range p.members {
+		s.Members = append(s.Members, userID)
+	}
+	s.Participants = []uint{}
+	for userID := range p.participants {
+		s.Participants = append(s.Participants, userID)
+	}
+	return s
+}
+
+// broadcast sends the event to all subscribers. Must be called with the mutex held.
+func (p *party) broadcast(eventType string, userID uint) {
+	e := PartyEvent{Type: eventType, Party: p.snapshot(), UserID: userID}
+	for s := range p.subscribers {
+		s.send(e)
+	}
+}
+
+func (s *partySubscriber) send(e PartyEvent) {
+	select {
+	case s.events <- e:
+	default:
+		log.WithFields(log.Fields{"user": s.userID, "event": e.Type, "party": e.Party.ID}).
+			Warnln("Party subscriber is not keeping up, dropping event")
+	}
+}
+
+// getParty returns the party with the given ID. Must be called with the mutex held.
+func (m *PartyManager) getParty(partyID string) (*party, error) {
+	p, ok := m.parties[partyID]
+	if !ok {
+		return nil, fmt.Errorf("no party with ID %s", partyID)
+	}
+	return p, nil
+}
+
+// closeIdleParties closes parties that haven't seen any activity for a long time. Must be called
+// with the mutex held.
+func (m *PartyManager) closeIdleParties() {
+	for id, p := range m.parties {
+		if len(p.subscribers) == 0 && time.Since(p.UpdatedAt) > partyIdleTimeout {
+			delete(m.parties, id)
+		}
+	}
+}
+
+// CreateParty creates a new party for the given media item hosted by the given user.
+func (m *PartyManager) CreateParty(hostID uint, mediaUUID string, allowMemberControl bool) Party {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closeIdleParties()
+
+	p := &party{
+		Party: Party{
+			ID:                 uuid.New().String(),
+			HostID:             hostID,
+			MediaUUID:          mediaUUID,
+			AllowMemberControl: allowMemberControl,
+			UpdatedAt:          time.Now(),
+		},
+		members:      map[uint]bool{hostID: true},
+		participants: map[uint]bool{hostID: true},
+		subscribers:  map[*partySubscriber]bool{},
+	}
+	m.parties[p.ID] = p
+
+	log.WithFields(log.Fields{"party": p.ID, "host": hostID, "media": mediaUUID}).Infoln("Party created")
+	return p.snapshot()
+}
+
+// FindParty returns the party with the given ID if the given user is a member.
+func (m *PartyManager) FindParty(partyID string, userID uint) (Party, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if !p.members[userID] {
+		return Party{}, fmt.Errorf("you are not a member of this party")
+	}
+	return p.snapshot(), nil
+}
+
+// PartiesForUser returns all parties the given user is a member of.
+func (m *PartyManager) PartiesForUser(userID uint) []Party {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	parties := []Party{}
+	for _, p := range m.parties {
+		if p.members[userID] {
+			parties = append(parties, p.snapshot())
+		}
+	}
+	return parties
+}
+
+// Invite allows the given user to join the party. Only the host can invite.
+func (m *PartyManager) Invite(partyID string, hostID uint, userID uint) (Party, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if p.HostID != hostID {
+		return Party{}, fmt.Errorf("only the host can invite to a party")
+	}
+
+	p.members[userID] = true
+	p.broadcast(PartyEventInvite, userID)
+	return p.snapshot(), nil
+}
+
+// Join adds the given user to the party's participants if they were invited.
+func (m *PartyManager) Join(partyID string, userID uint) (Party, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if !p.members[userID] {
+		return Party{}, fmt.Errorf("you were not invited to this party")
+	}
+
+	p.participants[userID] = true
+	p.broadcast(PartyEventJoin, userID)
+	return p.snapshot(), nil
+}
+
+// Leave removes the given user from the party's participants. The party is closed if the host
+// leaves.
+func (m *PartyManager) Leave(partyID string, userID uint) (Party, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if p.HostID == userID {
+		m.closeLocked(p)
+		return p.snapshot(), nil
+	}
+
+	delete(p.participants, userID)
+	p.broadcast(PartyEventLeave, userID)
+	return p.snapshot(), nil
+}
+
+// Close closes the party. Only the host or an admin can close a party.
+func (m *PartyManager) Close(partyID string, userID uint, admin bool) (Party, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if p.HostID != userID && !admin {
+		return Party{}, fmt.Errorf("only the host can close a party")
+	}
+
+	m.closeLocked(p)
+	return p.snapshot(), nil
+}
+
+// closeLocked notifies all subscribers that the party is closed and forgets about it. Must be
+// called with the mutex held.
+func (m *PartyManager) closeLocked(p *party) {
+	p.participants = map[uint]bool{}
+	p.Playing = false
+	p.broadcast(PartyEventClosed, p.HostID)
+	for s := range p.subscribers {
+		close(s.events)
+	}
+	p.subscribers = map[*partySubscriber]bool{}
+	delete(m.parties, p.ID)
+
+	log.WithFields(log.Fields{"party": p.ID}).Infoln("Party closed")
+}
+
+// UpdatePlayback plays, pauses or seeks the party's playback to the given position in seconds and
+// tells all participants about it.
+func (m *PartyManager) UpdatePlayback(
+	partyID string,
+	userID uint,
+	eventType string,
+	position float64) (Party, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return Party{}, err
+	}
+	if !p.participants[userID] {
+		return Party{}, fmt.Errorf("you have to join the party first")
+	}
+	if p.HostID != userID && !p.AllowMemberControl {
+		return Party{}, fmt.Errorf("only the host controls playback in this party")
+	}
+	if position < 0 {
+		return Party{}, fmt.Errorf("invalid position %f", position)
+	}
+
+	switch eventType {
+	case PartyEventPlay:
+		p.Playing = true
+	case PartyEventPause:
+		p.Playing = false
+	case PartyEventSeek:
+	default:
+		return Party{}, fmt.Errorf("unknown playback action %s", eventType)
+	}
+	p.Position = position
+	p.UpdatedAt = time.Now()
+
+	p.broadcast(eventType, userID)
+	return p.snapshot(), nil
+}
+
+// ReportPosition compares the position in seconds a participant is at with where the party is.
+// If they drifted too far, a PartyEventSync is sent to them so that they can seek.
+// Returns whether the participant is out of sync.
+func (m *PartyManager) ReportPosition(partyID string, userID uint, position float64) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return false, err
+	}
+	if !p.participants[userID] {
+		return false, fmt.Errorf("you have to join the party first")
+	}
+
+	drift := math.Abs(p.CurrentPosition() - position)
+	if drift <= partyMaxDrift.Seconds() {
+		return false, nil
+	}
+
+	log.WithFields(log.Fields{"party": p.ID, "user": userID, "drift": drift}).
+		Debugln("Party participant drifted, syncing")
+	e := PartyEvent{Type: PartyEventSync, Party: p.snapshot(), UserID: userID}
+	for s := range p.subscribers {
+		if s.userID == userID {
+			s.send(e)
+		}
+	}
+	return true, nil
+}
+
+// Subscribe returns a channel on which all events of the party are sent until stop is closed or
+// the party is closed.
+func (m *PartyManager) Subscribe(partyID string, userID uint, stop <-chan struct{}) (<-chan PartyEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.getParty(partyID)
+	if err != nil {
+		return nil, err
+	}
+	if !p.members[userID] {
+		return nil, fmt.Errorf("you are not a member of this party")
+	}
+
+	s := &partySubscriber{userID: userID, events: make(chan PartyEvent, partySubscriberBuffer)}
+	p.subscribers[s] = true
+
+	go func() {
+		<-stop
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		// The party may have been closed in the meantime, which already closed the channel.
+		if p.subscribers[s] {
+			delete(p.subscribers, s)
+			close(s.events)
+		}
+	}()
+	return s.events, nil
+}
@@ -0,0 +1,186 @@
+package managers
+
+import (
+	"flag"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"path"
+	"time"
+)
+
+var rclonePollIntervalFlag = flag.Duration(
+	"rclone_poll_interval",
+	time.Minute,
+	"How often rclone remotes that support change notifications are asked for changes")
+var rcloneScanIntervalFlag = flag.Duration(
+	"rclone_scan_interval",
+	15*time.Minute,
+	"How often rclone libraries on remotes without change notifications are scanned for changes")
+
+// Number of change notifications buffered before the remote has to wait for us.
+const rcloneChangeBuffer = 256
+
+// rcloneFileState is what we remember about a file between two polling scans.
+type rcloneFileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// rcloneChange is a path on a remote that was reported as changed.
+type rcloneChange struct {
+	path  string
+	isDir bool
+}
+
+// rcloneRootPath returns the path of an rclone root in the form RcloneNodeFromPath takes.
+func rcloneRootPath(root *db.LibraryRoot) string {
+	return path.Join(root.RcloneName, root.FilePath)
+}
+
+// startRcloneWatcher picks how changes to an rclone root of the library are noticed: through
+// rclone's ChangeNotify if the remote supports it, otherwise by periodically scanning the root and
+// comparing the listings. Either way only added and removed files are processed, not the whole
+// root. Watching stops when exit is closed.
+func (man *LibraryManager) startRcloneWatcher(root db.LibraryRoot, exit <-chan struct{}) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	changes := make(chan rcloneChange, rcloneChangeBuffer)
+	supported, err := filesystem.RcloneChangeNotify(
+		root.RcloneName,
+		*rclonePollIntervalFlag,
+		func(pathStr string, isDir bool) {
+			select {
+			case changes <- rcloneChange{pathStr, isDir}:
+			case <-stop:
+			}
+		},
+		stop)
+	if err != nil {
+		log.WithFields(root.LogFields()).WithField("error", err).
+			Warnln("Failed to set up change notifications, falling back to scanning")
+	}
+
+	if supported {
+		log.WithFields(root.LogFields()).Println("Watching rclone library through change notifications")
+		for {
+			select {
+			case <-exit:
+				log.WithFields(root.LogFields()).Println("Stopping rclone change notifications.")
+				return
+			case c := <-changes:
+				man.processRcloneChange(&root, c)
+			}
+		}
+	}
+
+	log.WithFields(root.LogFields()).
+		WithField("interval", *rcloneScanIntervalFlag).
+		Println("Watching rclone library by scanning for changes")
+	ticker := time.NewTicker(*rcloneScanIntervalFlag)
+	defer ticker.Stop()
+
+	// The initial scan in RefreshAll takes care of everything that is already there.
+	previous := scanRcloneRoot(&root)
+	for {
+		select {
+		case <-exit:
+			log.WithFields(root.LogFields()).Println("Stopping rclone change scans.")
+			return
+		case <-ticker.C:
+			current := scanRcloneRoot(&root)
+			if current == nil {
+				// Keep comparing against the last listing we got until the remote is back.
+				continue
+			}
+			if previous != nil {
+				man.processRcloneScan(previous, current)
+			}
+			previous = current
+		}
+	}
+}
+
+// processRcloneChange probes new files at or below the changed path and removes files that are
+// gone from the library.
+func (man *LibraryManager) processRcloneChange(root *db.LibraryRoot, c rcloneChange) {
+	if !root.Contains(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: c.path}) {
+		return
+	}
+	log.WithFields(log.Fields{"path": c.path, "isDir": c.isDir}).Debugln("Got rclone change notification.")
+
+	if n, err := filesystem.RcloneNodeFromPath(c.path); err == nil {
+		_ = n.Walk(func(walkPath string, n filesystem.Node, err error) error {
+			if err == nil && man.ValidFile(n) {
+				man.checkAndAddProbeJob(n)
+			}
+			return nil
+		}, true)
+	}
+
+	man.removeMissingFilesInPath(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: c.path})
+}
+
+// scanRcloneRoot lists all files of an rclone root, bypassing rclone's directory cache. Returns
+// nil if the root can't be listed.
+func scanRcloneRoot(root *db.LibraryRoot) map[string]rcloneFileState {
+	filesystem.FlushRcloneDirCache(root.RcloneName)
+
+	rootNode, err := filesystem.RcloneNodeFromPath(rcloneRootPath(root))
+	if err != nil {
+		log.WithFields(root.LogFields()).WithField("error", err).
+			Warnln("Failed to access rclone library for scanning")
+		return nil
+	}
+
+	files := map[string]rcloneFileState{}
+	failed := false
+	_ = rootNode.Walk(func(walkPath string, n filesystem.Node, err error) error {
+		if err != nil {
+			failed = true
+			log.WithFields(log.Fields{"error": err}).
+				Warnf("Received an error while scanning %s", walkPath)
+			return nil
+		}
+		if !n.IsDir() {
+			files[n.FileLocator().Path] = rcloneFileState{size: n.Size(), modTime: n.ModTime()}
+		}
+		return nil
+	}, true)
+
+	// An incomplete listing would make us think files were removed.
+	if failed {
+		return nil
+	}
+	return files
+}
+
+// processRcloneScan compares two listings of an rclone root. New files and files whose size or
+// modification time changed, e.g. because they were still being uploaded last time, are probed if
+// they aren't in the library yet. Files that disappeared are removed, or relocated if they were
+// moved.
+func (man *LibraryManager) processRcloneScan(previous, current map[string]rcloneFileState) {
+	// Removals go first so that moved files are recognized when their new path is probed.
+	for p := range previous {
+		if _, ok := current[p]; ok {
+			continue
+		}
+		log.WithFields(log.Fields{"path": p}).Debugln("File disappeared while scanning rclone library.")
+		man.removeMissingFilesInPath(filesystem.FileLocator{Backend: filesystem.BackendRclone, Path: p})
+	}
+
+	for p, state := range current {
+		if old, ok := previous[p]; ok && old.size == state.size && old.modTime.Equal(state.modTime) {
+			continue
+		}
+		n, err := filesystem.RcloneNodeFromPath(p)
+		if err != nil {
+			continue
+		}
+		if man.ValidFile(n) {
+			log.WithFields(log.Fields{"path": p}).Debugln("Found new file while scanning rclone library.")
+			man.checkAndAddProbeJob(n)
+		}
+	}
+}
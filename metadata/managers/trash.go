@@ -0,0 +1,66 @@
+package managers
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+var missingFileGracePeriodFlag = flag.Duration(
+	"missing_file_grace_period",
+	30*24*time.Hour,
+	"How long files that went missing are kept, together with their metadata and play states, before they are purged")
+
+// TrashManager purges files that have been missing for longer than the grace period and lets
+// admins restore or purge missing files by hand.
+type TrashManager struct{}
+
+// NewTrashManager creates a new TrashManager and starts purging expired files periodically.
+func NewTrashManager() *TrashManager {
+	m := &TrashManager{}
+
+	expiryTicker := time.NewTicker(time.Hour)
+	go func() {
+		m.RemoveExpired()
+		for range expiryTicker.C {
+			m.RemoveExpired()
+		}
+	}()
+
+	return m
+}
+
+// PurgeAt returns when the given missing file will be purged.
+func (m *TrashManager) PurgeAt(file db.MediaFile) time.Time {
+	return file.GetMissingSince().Add(*missingFileGracePeriodFlag)
+}
+
+// Restore makes a missing file available again. This only works if the file is back, otherwise
+// it would be marked as missing again by the next scan.
+func (m *TrashManager) Restore(file db.MediaFile) error {
+	fileLocator, err := filesystem.ParseFileLocator(file.GetFilePath())
+	if err != nil {
+		return err
+	}
+	if _, err := filesystem.GetNodeFromFileLocator(fileLocator); err != nil {
+		return fmt.Errorf("file %s is still missing", fileLocator.Path)
+	}
+	return db.RestoreMediaFile(file)
+}
+
+// Purge removes a missing file together with its metadata and play states for good.
+func (m *TrashManager) Purge(file db.MediaFile) {
+	file.DeleteSelfAndMD()
+}
+
+// RemoveExpired purges all files that have been missing for longer than the grace period.
+func (m *TrashManager) RemoveExpired() {
+	for _, file := range db.FindMediaFilesMissingBefore(time.Now().Add(-*missingFileGracePeriodFlag)) {
+		log.WithFields(log.Fields{"path": file.GetFilePath(), "missingSince": file.GetMissingSince()}).
+			Println("Purging file that has been missing for too long")
+		m.Purge(file)
+	}
+}
@@ -3,6 +3,7 @@ package managers
 import (
 	"github.com/Jeffail/tunny"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
 // WorkerPool is a container for the various workers that a library needs
@@ -17,11 +18,21 @@ func (p *WorkerPool) Shutdown() {
 	log.Debugln("Pool shut down")
 }
 
+// SetProbeConcurrency changes how many files are probed at the same time.
+func (p *WorkerPool) SetProbeConcurrency(n int) {
+	p.probePool.SetSize(n)
+}
+
 // NewDefaultWorkerPool needs a description
 func NewDefaultWorkerPool() *WorkerPool {
+	return NewWorkerPool(db.DefaultProbeConcurrency)
+}
+
+// NewWorkerPool creates a WorkerPool that probes the given number of files at the same time.
+func NewWorkerPool(probeConcurrency int) *WorkerPool {
 	p := &WorkerPool{}
 
-	p.probePool = tunny.NewFunc(4, func(payload interface{}) interface{} {
+	p.probePool = tunny.NewFunc(probeConcurrency, func(payload interface{}) interface{} {
 		log.Println("Current Probe queue length:", p.probePool.QueueLength())
 		if job, ok := payload.(*probeJob); ok {
 			job.man.ProbeFile(job.node)
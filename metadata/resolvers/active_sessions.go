@@ -0,0 +1,189 @@
+package resolvers
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/streaming"
+	"time"
+)
+
+// How often subscribers get an updated list of active sessions.
+const activeSessionsUpdateInterval = 5 * time.Second
+
+// ActiveSessionResolver resolves a playback session on the "now playing" dashboard.
+type ActiveSessionResolver struct {
+	r streaming.ActiveSession
+}
+
+// PlaybackSessionID returns the ID used to stop the session.
+func (r *ActiveSessionResolver) PlaybackSessionID() string {
+	return r.r.PlaybackSessionID
+}
+
+// SessionID returns the ID of the client session, shared between its streams.
+func (r *ActiveSessionResolver) SessionID() string {
+	return r.r.SessionID
+}
+
+// User returns the user who is watching.
+func (r *ActiveSessionResolver) User() *UserResolver {
+	user, err := db.FindUser(r.r.UserID)
+	if err != nil {
+		return nil
+	}
+	return &UserResolver{*user}
+}
+
+// MediaItem returns the movie or episode that is being played.
+func (r *ActiveSessionResolver) MediaItem() *MediaItemResolver {
+	if file, err := db.FindMovieFileByPath(r.r.FileLocator); err == nil {
+		if movie, err := db.FindMovieForMovieFile(file); err == nil {
+			return &MediaItemResolver{r: &MovieResolver{r: *movie}}
+		}
+	}
+	if file, err := db.FindEpisodeFileByPath(r.r.FileLocator); err == nil {
+		if episode, err := db.FindEpisodeByID(file.EpisodeID); err == nil {
+			return &MediaItemResolver{r: &EpisodeResolver{r: *episode}}
+		}
+	}
+	return nil
+}
+
+// StreamType returns "video", "audio" or "subtitle".
+func (r *ActiveSessionResolver) StreamType() string {
+	return r.r.StreamType
+}
+
+// Representation returns the ID of the representation that is played.
+func (r *ActiveSessionResolver) Representation() string {
+	return r.r.RepresentationID
+}
+
+// Transcoded returns true if the stream is transcoded and false if it is transmuxed.
+func (r *ActiveSessionResolver) Transcoded() bool {
+	return r.r.Transcoded
+}
+
+// Progress returns how far ffmpeg got through the stream in percent.
+func (r *ActiveSessionResolver) Progress() float64 {
+	return float64(r.r.ProgressPercent)
+}
+
+// Throttled returns whether ffmpeg is throttled because it is far enough ahead of the client.
+func (r *ActiveSessionResolver) Throttled() bool {
+	return r.r.Throttled
+}
+
+// ClientIP returns the IP address of the client.
+func (r *ActiveSessionResolver) ClientIP() string {
+	return r.r.ClientIP
+}
+
+// Bandwidth returns the average rate at which segments were served in bits per second.
+func (r *ActiveSessionResolver) Bandwidth() int32 {
+	return int32(r.r.Bandwidth)
+}
+
+// LastAccessed returns when the client last requested a segment, in RFC 3339 format.
+func (r *ActiveSessionResolver) LastAccessed() string {
+	return r.r.LastAccessed.Format(time.RFC3339)
+}
+
+func activeSessionResolvers() (sessions []*ActiveSessionResolver) {
+	for _, s := range streaming.ActiveSessions() {
+		sessions = append(sessions, &ActiveSessionResolver{r: s})
+	}
+	return sessions
+}
+
+// ActiveSessions returns all playback sessions.
+func (r *Resolver) ActiveSessions(ctx context.Context) (sessions []*ActiveSessionResolver) {
+	if err := ifAdmin(ctx); err != nil {
+		return sessions
+	}
+	return activeSessionResolvers()
+}
+
+// ActiveSessionsUpdatedEvent holds the current list of playback sessions.
+type ActiveSessionsUpdatedEvent struct {
+	sessions []*ActiveSessionResolver
+}
+
+// Sessions returns all playback sessions.
+func (e *ActiveSessionsUpdatedEvent) Sessions() []*ActiveSessionResolver {
+	return e.sessions
+}
+
+// ActiveSessionsUpdated creates a subscription that periodically sends all playback sessions.
+func (r *Resolver) ActiveSessionsUpdated(ctx context.Context) <-chan *ActiveSessionsUpdatedEvent {
+	c := make(chan *ActiveSessionsUpdatedEvent)
+	if err := ifAdmin(ctx); err != nil {
+		close(c)
+		return c
+	}
+
+	log.Debugln("Adding subscription to ActiveSessionsUpdatedEvent")
+	go func() {
+		ticker := time.NewTicker(activeSessionsUpdateInterval)
+		defer ticker.Stop()
+		defer close(c)
+
+		for {
+			select {
+			case c <- &ActiveSessionsUpdatedEvent{sessions: activeSessionResolvers()}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// StopSessionResponse is returned when stopping a playback session.
+type StopSessionResponse struct {
+	Error   *ErrorResolver
+	Success bool
+}
+
+// StopSessionResponseResolver resolves StopSessionResponse.
+type StopSessionResponseResolver struct {
+	r StopSessionResponse
+}
+
+// Error returns error.
+func (r *StopSessionResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Success returns whether the session was stopped.
+func (r *StopSessionResponseResolver) Success() bool {
+	return r.r.Success
+}
+
+// StopSession stops the client session of the given playback session. Its further segment requests
+// are rejected with the given reason.
+func (r *Resolver) StopSession(ctx context.Context, args struct {
+	PlaybackSessionID string
+	Reason            *string
+}) *StopSessionResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return &StopSessionResponseResolver{StopSessionResponse{Error: CreateErrResolver(err)}}
+	}
+
+	reason := "Stopped by an administrator"
+	if args.Reason != nil && *args.Reason != "" {
+		reason = *args.Reason
+	}
+
+	if err := streaming.StopSession(args.PlaybackSessionID, reason); err != nil {
+		return &StopSessionResponseResolver{StopSessionResponse{Error: CreateErrResolver(err)}}
+	}
+	return &StopSessionResponseResolver{StopSessionResponse{Success: true}}
+}
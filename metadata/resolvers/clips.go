@@ -0,0 +1,171 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"path"
+	"strings"
+	"time"
+)
+
+// ClipResolver resolves a Clip.
+type ClipResolver struct {
+	r db.Clip
+}
+
+// UUID returns the UUID of the clip.
+func (r *ClipResolver) UUID() string {
+	return r.r.UUID
+}
+
+// MediaFileUUID returns the UUID of the file the clip is cut from.
+func (r *ClipResolver) MediaFileUUID() string {
+	return r.r.MediaFileUUID
+}
+
+// FileName returns the name of the file the clip is cut from.
+func (r *ClipResolver) FileName() string {
+	return r.r.FileName
+}
+
+// Start returns where the clip starts in seconds.
+func (r *ClipResolver) Start() float64 {
+	return r.r.Start
+}
+
+// End returns where the clip ends in seconds.
+func (r *ClipResolver) End() float64 {
+	return r.r.End
+}
+
+// SubtitleStreamID returns the ID of the subtitle stream burned into the clip, -1 if none.
+func (r *ClipResolver) SubtitleStreamID() int32 {
+	return int32(r.r.SubtitleStreamID)
+}
+
+// State returns the state of the transcoding job.
+func (r *ClipResolver) State() string {
+	return r.r.State
+}
+
+// Progress returns the progress of the transcoding job in percent.
+func (r *ClipResolver) Progress() float64 {
+	return r.r.Progress
+}
+
+// Error returns why the transcoding job failed, if it did.
+func (r *ClipResolver) Error() string {
+	return r.r.Error
+}
+
+// FileSize returns the size of the clip in bytes.
+func (r *ClipResolver) FileSize() int32 {
+	return int32(r.r.Size)
+}
+
+// ExpiresAt returns when the clip will be removed, empty while it is not done yet.
+func (r *ClipResolver) ExpiresAt() string {
+	if r.r.ExpiresAt.IsZero() {
+		return ""
+	}
+	return r.r.ExpiresAt.Format(time.RFC3339)
+}
+
+// ClipPath returns a signed URI anyone can fetch the finished clip from.
+func (r *ClipResolver) ClipPath() (string, error) {
+	if r.r.State != db.JobStateDone {
+		return "", nil
+	}
+
+	token, err := auth.CreateClipJWT(r.r.UUID, r.r.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := strings.TrimSuffix(r.r.FileName, path.Ext(r.r.FileName)) + "-clip.mp4"
+	return helpers.StreamingPath("clips", token, fileName), nil
+}
+
+// ClipResponse holds a clip and an error if needed.
+type ClipResponse struct {
+	Error *ErrorResolver
+	Clip  *ClipResolver
+}
+
+// ClipResponseResolver resolves ClipResponse.
+type ClipResponseResolver struct {
+	r ClipResponse
+}
+
+// Error returns error.
+func (r *ClipResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Clip returns the clip.
+func (r *ClipResponseResolver) Clip() *ClipResolver {
+	return r.r.Clip
+}
+
+func clipErrResponse(err error) *ClipResponseResolver {
+	return &ClipResponseResolver{ClipResponse{Error: CreateErrResolver(err)}}
+}
+
+// Clips returns the clips of the current user.
+func (r *Resolver) Clips(ctx context.Context) (clips []*ClipResolver) {
+	userID, _ := auth.UserID(ctx)
+	for _, c := range db.FindClipsForUser(userID) {
+		clips = append(clips, &ClipResolver{r: c})
+	}
+	return clips
+}
+
+// CreateClip queues a clip of the given movie, episode or file between start and end in seconds
+// for the current user.
+func (r *Resolver) CreateClip(ctx context.Context, args struct {
+	UUID             string
+	Start            float64
+	End              float64
+	SubtitleStreamID *int32
+}) *ClipResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	file, fileUUID, err := findFileToDownload(args.UUID)
+	if err != nil {
+		return clipErrResponse(err)
+	}
+
+	subtitleStreamID := int64(-1)
+	if args.SubtitleStreamID != nil {
+		subtitleStreamID = int64(*args.SubtitleStreamID)
+	}
+
+	c, err := r.clips.Queue(userID, file, fileUUID, ffmpeg.ClipOptions{
+		Start:            time.Duration(args.Start * float64(time.Second)),
+		End:              time.Duration(args.End * float64(time.Second)),
+		SubtitleStreamID: subtitleStreamID,
+	})
+	if err != nil {
+		return clipErrResponse(err)
+	}
+
+	return &ClipResponseResolver{ClipResponse{Clip: &ClipResolver{r: *c}}}
+}
+
+// DeleteClip removes a clip of the current user, cancelling it if required. Admins can delete any
+// clip.
+func (r *Resolver) DeleteClip(ctx context.Context, args struct{ UUID string }) *ClipResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	c, err := db.FindClipByUUID(args.UUID)
+	if err != nil || (c.UserID != userID && ifAdmin(ctx) != nil) {
+		return clipErrResponse(fmt.Errorf("No clip found for UUID %s", args.UUID))
+	}
+	r.clips.Delete(c)
+
+	return &ClipResponseResolver{ClipResponse{Clip: &ClipResolver{r: *c}}}
+}
@@ -0,0 +1,277 @@
+package resolvers
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/managers"
+	"time"
+)
+
+// DeviceResolver resolves a client that can be remote controlled.
+type DeviceResolver struct {
+	r managers.Device
+}
+
+// ID returns the ID the client chose for itself.
+func (r *DeviceResolver) ID() string {
+	return r.r.ID
+}
+
+// Name returns the user-visible name of the device.
+func (r *DeviceResolver) Name() string {
+	return r.r.Name
+}
+
+// Type returns what kind of device this is, e.g. "tv" or "phone".
+func (r *DeviceResolver) Type() string {
+	return r.r.Type
+}
+
+// Online returns whether the device can receive commands.
+func (r *DeviceResolver) Online() bool {
+	return r.r.Online
+}
+
+// LastSeen returns when the device was last online or reported its state, in RFC 3339 format.
+func (r *DeviceResolver) LastSeen() string {
+	return r.r.LastSeen.Format(time.RFC3339)
+}
+
+// State returns what the device is playing.
+func (r *DeviceResolver) State() *DeviceStateResolver {
+	return &DeviceStateResolver{r.r.State}
+}
+
+// DeviceStateResolver resolves the playback state of a device.
+type DeviceStateResolver struct {
+	r managers.DeviceState
+}
+
+// MediaUUID returns the UUID of the movie or episode being played, empty if nothing is.
+func (r *DeviceStateResolver) MediaUUID() string {
+	return r.r.MediaUUID
+}
+
+// Position returns the playback position in seconds.
+func (r *DeviceStateResolver) Position() float64 {
+	return r.r.Position
+}
+
+// Playing returns whether the device is playing or paused.
+func (r *DeviceStateResolver) Playing() bool {
+	return r.r.Playing
+}
+
+// AudioStreamID returns the ID of the audio stream being played.
+func (r *DeviceStateResolver) AudioStreamID() int32 {
+	return int32(r.r.AudioStreamID)
+}
+
+// SubtitleStreamID returns the ID of the subtitle stream being shown, -1 if none.
+func (r *DeviceStateResolver) SubtitleStreamID() int32 {
+	return int32(r.r.SubtitleStreamID)
+}
+
+// UpdatedAt returns when the device last reported its state, in RFC 3339 format.
+func (r *DeviceStateResolver) UpdatedAt() string {
+	return r.r.UpdatedAt.Format(time.RFC3339)
+}
+
+// DeviceCommandResolver resolves a command sent to a device.
+type DeviceCommandResolver struct {
+	r managers.DeviceCommand
+}
+
+// Action returns what the device should do.
+func (r *DeviceCommandResolver) Action() string {
+	return r.r.Action
+}
+
+// MediaUUID returns the movie or episode to play.
+func (r *DeviceCommandResolver) MediaUUID() string {
+	return r.r.MediaUUID
+}
+
+// Position returns the position in seconds to play or seek to.
+func (r *DeviceCommandResolver) Position() float64 {
+	return r.r.Position
+}
+
+// StreamID returns the audio or subtitle stream to switch to.
+func (r *DeviceCommandResolver) StreamID() int32 {
+	return int32(r.r.StreamID)
+}
+
+// FromDeviceID returns the device the command was sent from.
+func (r *DeviceCommandResolver) FromDeviceID() string {
+	return r.r.FromDeviceID
+}
+
+// DeviceResponse is returned by device mutations.
+type DeviceResponse struct {
+	Error  *ErrorResolver
+	Device *DeviceResolver
+}
+
+// DeviceResponseResolver resolves DeviceResponse.
+type DeviceResponseResolver struct {
+	r DeviceResponse
+}
+
+// Error returns error.
+func (r *DeviceResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Device returns the device.
+func (r *DeviceResponseResolver) Device() *DeviceResolver {
+	return r.r.Device
+}
+
+// Devices returns all devices of the current user.
+func (r *Resolver) Devices(ctx context.Context) (devices []*DeviceResolver) {
+	userID, _ := auth.UserID(ctx)
+	for _, d := range r.devices.Devices(userID) {
+		devices = append(devices, &DeviceResolver{d})
+	}
+	return devices
+}
+
+type deviceCommandInput struct {
+	Action       string
+	MediaUUID    *string
+	Position     *float64
+	StreamID     *int32
+	FromDeviceID *string
+}
+
+// SendDeviceCommand sends a command to another device of the current user.
+func (r *Resolver) SendDeviceCommand(ctx context.Context, args struct {
+	DeviceID string
+	Command  deviceCommandInput
+}) *DeviceResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	command := managers.DeviceCommand{Action: args.Command.Action}
+	if args.Command.MediaUUID != nil {
+		command.MediaUUID = *args.Command.MediaUUID
+	}
+	if args.Command.Position != nil {
+		command.Position = *args.Command.Position
+	}
+	if args.Command.StreamID != nil {
+		command.StreamID = int(*args.Command.StreamID)
+	}
+	if args.Command.FromDeviceID != nil {
+		command.FromDeviceID = *args.Command.FromDeviceID
+	}
+
+	if err := r.devices.SendCommand(userID, args.DeviceID, command); err != nil {
+		return &DeviceResponseResolver{DeviceResponse{Error: CreateErrResolver(err)}}
+	}
+	for _, d := range r.devices.Devices(userID) {
+		if d.ID == args.DeviceID {
+			return &DeviceResponseResolver{DeviceResponse{Device: &DeviceResolver{d}}}
+		}
+	}
+	return &DeviceResponseResolver{DeviceResponse{}}
+}
+
+type deviceStateInput struct {
+	MediaUUID        *string
+	Position         float64
+	Playing          bool
+	AudioStreamID    *int32
+	SubtitleStreamID *int32
+}
+
+// ReportDeviceState updates the playback state of one of the current user's devices.
+func (r *Resolver) ReportDeviceState(ctx context.Context, args struct {
+	DeviceID string
+	State    deviceStateInput
+}) *DeviceResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	state := managers.DeviceState{
+		Position:         args.State.Position,
+		Playing:          args.State.Playing,
+		SubtitleStreamID: -1,
+	}
+	if args.State.MediaUUID != nil {
+		state.MediaUUID = *args.State.MediaUUID
+	}
+	if args.State.AudioStreamID != nil {
+		state.AudioStreamID = int(*args.State.AudioStreamID)
+	}
+	if args.State.SubtitleStreamID != nil {
+		state.SubtitleStreamID = int(*args.State.SubtitleStreamID)
+	}
+
+	d, err := r.devices.ReportState(userID, args.DeviceID, state)
+	if err != nil {
+		return &DeviceResponseResolver{DeviceResponse{Error: CreateErrResolver(err)}}
+	}
+	return &DeviceResponseResolver{DeviceResponse{Device: &DeviceResolver{d}}}
+}
+
+// DeviceCommands announces a device of the current user and creates a subscription for the
+// commands sent to it. The device is online for as long as the subscription lasts.
+func (r *Resolver) DeviceCommands(ctx context.Context, args struct {
+	DeviceID string
+	Name     string
+	Type     *string
+}) <-chan *DeviceCommandResolver {
+	c := make(chan *DeviceCommandResolver)
+	userID, _ := auth.UserID(ctx)
+
+	deviceType := ""
+	if args.Type != nil {
+		deviceType = *args.Type
+	}
+	commands, err := r.devices.Announce(userID, args.DeviceID, args.Name, deviceType, ctx.Done())
+	if err != nil {
+		log.WithFields(log.Fields{"device": args.DeviceID, "error": err}).Debugln("Not announcing device")
+		close(c)
+		return c
+	}
+
+	log.Debugln("Adding subscription to DeviceCommands")
+	go func() {
+		defer close(c)
+		for command := range commands {
+			select {
+			case c <- &DeviceCommandResolver{command}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// DeviceStateUpdated creates a subscription for state changes of the given device of the current
+// user, or all of their devices if none is given.
+func (r *Resolver) DeviceStateUpdated(ctx context.Context, args struct{ DeviceID *string }) <-chan *DeviceResolver {
+	c := make(chan *DeviceResolver)
+	userID, _ := auth.UserID(ctx)
+
+	deviceID := ""
+	if args.DeviceID != nil {
+		deviceID = *args.DeviceID
+	}
+	updates := r.devices.SubscribeState(userID, deviceID, ctx.Done())
+
+	log.Debugln("Adding subscription to DeviceStateUpdated")
+	go func() {
+		defer close(c)
+		for d := range updates {
+			select {
+			case c <- &DeviceResolver{d}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c
+}
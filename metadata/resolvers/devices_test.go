@@ -0,0 +1,69 @@
+package resolvers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"testing"
+)
+
+func TestDeviceRemoteControl(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+
+	tvCtx, disconnectTV := context.WithCancel(auth.ContextWithUserID(context.Background(), 1))
+	phoneCtx, cancelPhone := context.WithCancel(auth.ContextWithUserID(context.Background(), 1))
+	defer cancelPhone()
+	otherUserCtx := auth.ContextWithUserID(context.Background(), 2)
+
+	tvType := "tv"
+	commands := r.DeviceCommands(tvCtx, struct {
+		DeviceID string
+		Name     string
+		Type     *string
+	}{"tv", "Living room", &tvType})
+	states := r.DeviceStateUpdated(phoneCtx, struct{ DeviceID *string }{})
+
+	assert.Len(t, r.Devices(phoneCtx), 1)
+	assert.Empty(t, r.Devices(otherUserCtx), "devices must only be visible to their user")
+
+	mediaUUID := "movie-uuid"
+	position := 42.0
+	play := deviceCommandInput{Action: "play", MediaUUID: &mediaUUID, Position: &position}
+	res := r.SendDeviceCommand(otherUserCtx, struct {
+		DeviceID string
+		Command  deviceCommandInput
+	}{"tv", play})
+	assert.NotNil(t, res.Error())
+
+	res = r.SendDeviceCommand(phoneCtx, struct {
+		DeviceID string
+		Command  deviceCommandInput
+	}{"tv", play})
+	assert.Nil(t, res.Error())
+
+	command := <-commands
+	assert.Equal(t, "play", command.Action())
+	assert.Equal(t, "movie-uuid", command.MediaUUID())
+	assert.Equal(t, 42.0, command.Position())
+
+	r.ReportDeviceState(tvCtx, struct {
+		DeviceID string
+		State    deviceStateInput
+	}{"tv", deviceStateInput{MediaUUID: &mediaUUID, Position: 43, Playing: true}})
+	state := <-states
+	assert.True(t, state.State().Playing())
+	assert.Equal(t, int32(-1), state.State().SubtitleStreamID())
+
+	disconnectTV()
+	_, open := <-commands
+	assert.False(t, open)
+	state = <-states
+	assert.False(t, state.Online())
+
+	res = r.SendDeviceCommand(phoneCtx, struct {
+		DeviceID string
+		Command  deviceCommandInput
+	}{"tv", deviceCommandInput{Action: "pause"}})
+	assert.NotNil(t, res.Error(), "offline devices can't receive commands")
+}
@@ -0,0 +1,182 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"path"
+	"strings"
+	"time"
+)
+
+// DownloadResolver resolves a Download.
+type DownloadResolver struct {
+	r db.Download
+}
+
+// UUID returns the UUID of the download.
+func (r *DownloadResolver) UUID() string {
+	return r.r.UUID
+}
+
+// MediaFileUUID returns the UUID of the file that is downloaded.
+func (r *DownloadResolver) MediaFileUUID() string {
+	return r.r.MediaFileUUID
+}
+
+// FileName returns the name of the file that is downloaded.
+func (r *DownloadResolver) FileName() string {
+	return r.r.FileName
+}
+
+// Preset returns the name of the video encoder preset used.
+func (r *DownloadResolver) Preset() string {
+	return r.r.Preset
+}
+
+// State returns the state of the transcoding job.
+func (r *DownloadResolver) State() string {
+	return r.r.State
+}
+
+// Progress returns the progress of the transcoding job in percent.
+func (r *DownloadResolver) Progress() float64 {
+	return r.r.Progress
+}
+
+// Error returns why the transcoding job failed, if it did.
+func (r *DownloadResolver) Error() string {
+	return r.r.Error
+}
+
+// FileSize returns the size of the downloadable file in bytes.
+func (r *DownloadResolver) FileSize() int32 {
+	return int32(r.r.Size)
+}
+
+// ExpiresAt returns when the download will be removed, empty while it is not done yet.
+func (r *DownloadResolver) ExpiresAt() string {
+	if r.r.ExpiresAt.IsZero() {
+		return ""
+	}
+	return r.r.ExpiresAt.Format(time.RFC3339)
+}
+
+// DownloadPath returns a signed URI to fetch the finished download from.
+func (r *DownloadResolver) DownloadPath() (string, error) {
+	if r.r.State != db.JobStateDone {
+		return "", nil
+	}
+
+	token, err := auth.CreateDownloadJWT(r.r.UserID, r.r.UUID, r.r.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := strings.TrimSuffix(r.r.FileName, path.Ext(r.r.FileName)) + ".mp4"
+	return helpers.StreamingPath("downloads", token, fileName), nil
+}
+
+// DownloadResponse holds a download and an error if needed.
+type DownloadResponse struct {
+	Error    *ErrorResolver
+	Download *DownloadResolver
+}
+
+// DownloadResponseResolver resolves DownloadResponse.
+type DownloadResponseResolver struct {
+	r DownloadResponse
+}
+
+// Error returns error.
+func (r *DownloadResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Download returns the download.
+func (r *DownloadResponseResolver) Download() *DownloadResolver {
+	return r.r.Download
+}
+
+func downloadErrResponse(err error) *DownloadResponseResolver {
+	return &DownloadResponseResolver{DownloadResponse{Error: CreateErrResolver(err)}}
+}
+
+// Downloads returns the downloads of the current user.
+func (r *Resolver) Downloads(ctx context.Context) (downloads []*DownloadResolver) {
+	userID, _ := auth.UserID(ctx)
+	for _, d := range db.FindDownloadsForUser(userID) {
+		downloads = append(downloads, &DownloadResolver{r: d})
+	}
+	return downloads
+}
+
+// findFileToDownload finds the file with the given UUID or the first file of the movie or
+// episode with the given UUID.
+func findFileToDownload(uuid string) (db.MediaFile, string, error) {
+	if file := db.FindContentByUUID(uuid); file != nil {
+		return file, uuid, nil
+	}
+
+	if movie, err := db.FindMovieByUUID(uuid); err == nil && len(movie.MovieFiles) > 0 {
+		return movie.MovieFiles[0], movie.MovieFiles[0].UUID, nil
+	}
+
+	if episode, err := db.FindEpisodeByUUID(uuid); err == nil && len(episode.EpisodeFiles) > 0 {
+		return episode.EpisodeFiles[0], episode.EpisodeFiles[0].UUID, nil
+	}
+
+	return nil, "", fmt.Errorf("No file found for UUID %s", uuid)
+}
+
+func int32sToStreamIDs(ids *[]int32) []int64 {
+	streamIDs := []int64{}
+	if ids != nil {
+		for _, id := range *ids {
+			streamIDs = append(streamIDs, int64(id))
+		}
+	}
+	return streamIDs
+}
+
+// CreateDownload queues a download of the given movie, episode or file for the current user.
+func (r *Resolver) CreateDownload(ctx context.Context, args struct {
+	UUID              string
+	Preset            string
+	AudioStreamIDs    *[]int32
+	SubtitleStreamIDs *[]int32
+}) *DownloadResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	file, fileUUID, err := findFileToDownload(args.UUID)
+	if err != nil {
+		return downloadErrResponse(err)
+	}
+
+	d, err := r.downloads.Queue(userID, file, fileUUID, ffmpeg.TranscodeToFileOptions{
+		Preset:            args.Preset,
+		AudioStreamIds:    int32sToStreamIDs(args.AudioStreamIDs),
+		SubtitleStreamIds: int32sToStreamIDs(args.SubtitleStreamIDs),
+	})
+	if err != nil {
+		return downloadErrResponse(err)
+	}
+
+	return &DownloadResponseResolver{DownloadResponse{Download: &DownloadResolver{r: *d}}}
+}
+
+// DeleteDownload removes a download of the current user, cancelling it if required.
+func (r *Resolver) DeleteDownload(ctx context.Context, args struct{ UUID string }) *DownloadResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	d, err := db.FindDownloadByUUID(args.UUID)
+	if err != nil || d.UserID != userID {
+		return downloadErrResponse(fmt.Errorf("No download found for UUID %s", args.UUID))
+	}
+	r.downloads.Delete(d)
+
+	return &DownloadResponseResolver{DownloadResponse{Download: &DownloadResolver{r: *d}}}
+}
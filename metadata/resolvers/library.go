@@ -2,11 +2,13 @@ package resolvers
 
 import (
 	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	mhelpers "gitlab.com/olaris/olaris-server/metadata/helpers"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 var rescanningLibraries bool
@@ -88,6 +90,32 @@ func (r *LibraryResolver) Kind() int32 {
 	return int32(r.r.Kind)
 }
 
+// ExcludePatterns returns the patterns of paths in the library that are not scanned.
+func (r *LibraryResolver) ExcludePatterns() []string {
+	return splitNonEmpty(r.r.ExcludePatterns, "\n")
+}
+
+// Extensions returns the file extensions that are scanned, empty if the default ones are.
+func (r *LibraryResolver) Extensions() []string {
+	return splitNonEmpty(r.r.Extensions, ",")
+}
+
+// MinFileSize returns the size in bytes below which files are not scanned, 0 for the default.
+func (r *LibraryResolver) MinFileSize() int32 {
+	return int32(r.r.MinFileSize)
+}
+
+// splitNonEmpty splits s at sep, leaving out empty parts.
+func splitNonEmpty(s string, sep string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
 type createLibraryArgs struct {
 	Name       string
 	FilePath   string
@@ -204,6 +232,70 @@ func (r *Resolver) CreateLibrary(ctx context.Context, args *createLibraryArgs) *
 	return &LibResResolv{libRes}
 }
 
+type updateLibraryArgs struct {
+	ID              int32
+	Name            *string
+	ExcludePatterns *[]string
+	Extensions      *[]string
+	MinFileSize     *int32
+}
+
+// UpdateLibrary changes the name and scanning rules of a library. Only the given fields are
+// changed. The new rules apply from the next scan on, files that are already in the library stay.
+func (r *Resolver) UpdateLibrary(ctx context.Context, args *updateLibraryArgs) *LibResResolv {
+	if err := ifAdmin(ctx); err != nil {
+		return errResponse(err)
+	}
+
+	// Update the library the manager works with so that it picks up the changes right away.
+	var library *db.Library
+	for _, lm := range r.libs {
+		if lm.Library.ID == uint(args.ID) {
+			library = lm.Library
+		}
+	}
+	if library == nil {
+		return errResponse(fmt.Errorf("library not found"))
+	}
+
+	if args.MinFileSize != nil && *args.MinFileSize < 0 {
+		return errResponse(fmt.Errorf("minimum file size can't be negative"))
+	}
+	if args.ExcludePatterns != nil {
+		for _, pattern := range *args.ExcludePatterns {
+			if strings.Contains(pattern, "\n") {
+				return errResponse(fmt.Errorf("exclude patterns can't contain line breaks"))
+			}
+		}
+	}
+
+	if args.Name != nil {
+		library.Name = *args.Name
+	}
+	if args.ExcludePatterns != nil {
+		library.ExcludePatterns = strings.Join(*args.ExcludePatterns, "\n")
+	}
+	if args.Extensions != nil {
+		var extensions []string
+		for _, ext := range *args.Extensions {
+			if ext = strings.TrimSpace(ext); ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			extensions = append(extensions, ext)
+		}
+		library.Extensions = strings.Join(extensions, ",")
+	}
+	if args.MinFileSize != nil {
+		library.MinFileSize = int64(*args.MinFileSize)
+	}
+	db.SaveLibrary(library)
+
+	return &LibResResolv{LibraryResponse{Library: &LibraryResolver{Library{*library, nil, nil}}}}
+}
+
 // LibResResolv holds a library response.
 type LibResResolv struct {
 	r LibraryResponse
@@ -0,0 +1,98 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"path/filepath"
+)
+
+// LibraryRootResolver resolves one of the directories of a library.
+type LibraryRootResolver struct {
+	r db.LibraryRoot
+}
+
+// ID returns the root's ID.
+func (r *LibraryRootResolver) ID() int32 {
+	return int32(r.r.ID)
+}
+
+// Backend returns the root's backend type.
+func (r *LibraryRootResolver) Backend() int32 {
+	return int32(r.r.Backend)
+}
+
+// RcloneName returns the name of the root's Rclone remote.
+func (r *LibraryRootResolver) RcloneName() *string {
+	return &r.r.RcloneName
+}
+
+// FilePath returns the path of the root directory.
+func (r *LibraryRootResolver) FilePath() string {
+	return r.r.FilePath
+}
+
+// Healthy returns whether the root could be accessed during the last scan.
+func (r *LibraryRootResolver) Healthy() bool {
+	return r.r.Healthy
+}
+
+// Roots returns the directories of the library.
+func (r *LibraryResolver) Roots() []*LibraryRootResolver {
+	roots := []*LibraryRootResolver{}
+	for _, root := range db.FindLibraryRoots(&r.r.Library) {
+		roots = append(roots, &LibraryRootResolver{r: root})
+	}
+	return roots
+}
+
+type addLibraryRootArgs struct {
+	LibraryID  int32
+	FilePath   string
+	Backend    int32
+	RcloneName *string
+}
+
+// AddLibraryRoot adds another directory to a library and scans it.
+func (r *Resolver) AddLibraryRoot(ctx context.Context, args *addLibraryRootArgs) *LibResResolv {
+	if err := ifAdmin(ctx); err != nil {
+		return errResponse(err)
+	}
+
+	root := db.LibraryRoot{FilePath: filepath.Clean(args.FilePath), Backend: int(args.Backend)}
+	if args.RcloneName != nil {
+		root.RcloneName = *args.RcloneName
+	}
+
+	for _, lm := range r.libs {
+		if lm.Library.ID == uint(args.LibraryID) {
+			if err := lm.AddRoot(&root); err != nil {
+				return errResponse(err)
+			}
+			return &LibResResolv{LibraryResponse{Library: &LibraryResolver{Library{*lm.Library, nil, nil}}}}
+		}
+	}
+	return errResponse(fmt.Errorf("library not found"))
+}
+
+// RemoveLibraryRoot removes a directory from its library together with the files in it.
+func (r *Resolver) RemoveLibraryRoot(ctx context.Context, args struct{ ID int32 }) *LibResResolv {
+	if err := ifAdmin(ctx); err != nil {
+		return errResponse(err)
+	}
+
+	root, err := db.FindLibraryRoot(uint(args.ID))
+	if err != nil {
+		return errResponse(fmt.Errorf("library root not found"))
+	}
+
+	for _, lm := range r.libs {
+		if lm.Library.ID == root.LibraryID {
+			if err := lm.RemoveRoot(root.ID); err != nil {
+				return errResponse(err)
+			}
+			return &LibResResolv{LibraryResponse{Library: &LibraryResolver{Library{*lm.Library, nil, nil}}}}
+		}
+	}
+	return errResponse(fmt.Errorf("library not found"))
+}
@@ -0,0 +1,149 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+const mebiByte = 1 << 20
+
+// LibrarySettingsResolver resolves the settings of a library.
+type LibrarySettingsResolver struct {
+	r db.LibrarySettings
+}
+
+// MetadataLanguage returns the language metadata is retrieved in.
+func (r *LibrarySettingsResolver) MetadataLanguage() string {
+	return r.r.MetadataLanguage
+}
+
+// MetadataRegion returns the region whose release information is preferred.
+func (r *LibrarySettingsResolver) MetadataRegion() string {
+	return r.r.MetadataRegion
+}
+
+// Agent returns the metadata agent files are identified with.
+func (r *LibrarySettingsResolver) Agent() string {
+	return r.r.Agent
+}
+
+// RescanIntervalMinutes returns how often the library is rescanned automatically.
+func (r *LibrarySettingsResolver) RescanIntervalMinutes() int32 {
+	return int32(r.r.RescanInterval / time.Minute)
+}
+
+// VfsCacheMode returns the rclone VFS cache mode.
+func (r *LibrarySettingsResolver) VfsCacheMode() string {
+	return r.r.VFSCacheMode
+}
+
+// VfsCacheMaxSizeMB returns the size the rclone VFS cache is kept below.
+func (r *LibrarySettingsResolver) VfsCacheMaxSizeMB() int32 {
+	if r.r.VFSCacheMaxSize < 0 {
+		return -1
+	}
+	return int32(r.r.VFSCacheMaxSize / mebiByte)
+}
+
+// VfsChunkSizeMB returns the size of the chunks files are requested from the remote in.
+func (r *LibrarySettingsResolver) VfsChunkSizeMB() int32 {
+	return int32(r.r.VFSChunkSize / mebiByte)
+}
+
+// ProbeConcurrency returns how many files are probed at the same time.
+func (r *LibrarySettingsResolver) ProbeConcurrency() int32 {
+	return int32(r.r.ProbeConcurrency)
+}
+
+// Settings returns the settings of the library.
+func (r *LibraryResolver) Settings() *LibrarySettingsResolver {
+	return &LibrarySettingsResolver{r: *db.FindLibrarySettings(r.r.ID)}
+}
+
+// LibrarySettingsResponse holds the settings of a library and an error if needed.
+type LibrarySettingsResponse struct {
+	Error    *ErrorResolver
+	Settings *LibrarySettingsResolver
+}
+
+// LibrarySettingsResponseResolver resolves LibrarySettingsResponse.
+type LibrarySettingsResponseResolver struct {
+	r LibrarySettingsResponse
+}
+
+// Error returns error.
+func (r *LibrarySettingsResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Settings returns the settings.
+func (r *LibrarySettingsResponseResolver) Settings() *LibrarySettingsResolver {
+	return r.r.Settings
+}
+
+func librarySettingsErrResponse(err error) *LibrarySettingsResponseResolver {
+	return &LibrarySettingsResponseResolver{LibrarySettingsResponse{Error: CreateErrResolver(err)}}
+}
+
+type updateLibrarySettingsArgs struct {
+	LibraryID             int32
+	MetadataLanguage      *string
+	MetadataRegion        *string
+	Agent                 *string
+	RescanIntervalMinutes *int32
+	VfsCacheMode          *string
+	VfsCacheMaxSizeMB     *int32
+	VfsChunkSizeMB        *int32
+	ProbeConcurrency      *int32
+}
+
+// UpdateLibrarySettings changes the settings of a library. Only the given fields are changed.
+func (r *Resolver) UpdateLibrarySettings(ctx context.Context, args *updateLibrarySettingsArgs) *LibrarySettingsResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return librarySettingsErrResponse(err)
+	}
+
+	for _, lm := range r.libs {
+		if lm.Library.ID != uint(args.LibraryID) {
+			continue
+		}
+
+		settings := *lm.Settings()
+		if args.MetadataLanguage != nil {
+			settings.MetadataLanguage = *args.MetadataLanguage
+		}
+		if args.MetadataRegion != nil {
+			settings.MetadataRegion = *args.MetadataRegion
+		}
+		if args.Agent != nil {
+			settings.Agent = *args.Agent
+		}
+		if args.RescanIntervalMinutes != nil {
+			settings.RescanInterval = time.Duration(*args.RescanIntervalMinutes) * time.Minute
+		}
+		if args.VfsCacheMode != nil {
+			settings.VFSCacheMode = *args.VfsCacheMode
+		}
+		if args.VfsCacheMaxSizeMB != nil {
+			settings.VFSCacheMaxSize = int64(*args.VfsCacheMaxSizeMB) * mebiByte
+			if *args.VfsCacheMaxSizeMB < 0 {
+				settings.VFSCacheMaxSize = -1
+			}
+		}
+		if args.VfsChunkSizeMB != nil {
+			settings.VFSChunkSize = int64(*args.VfsChunkSizeMB) * mebiByte
+		}
+		if args.ProbeConcurrency != nil {
+			settings.ProbeConcurrency = int(*args.ProbeConcurrency)
+		}
+
+		if err := lm.UpdateSettings(&settings); err != nil {
+			return librarySettingsErrResponse(err)
+		}
+		return &LibrarySettingsResponseResolver{LibrarySettingsResponse{Settings: &LibrarySettingsResolver{r: settings}}}
+	}
+
+	return librarySettingsErrResponse(fmt.Errorf("library not found"))
+}
@@ -0,0 +1,127 @@
+package resolvers
+
+import (
+	"context"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+// MissingFileResolver resolves a movie or episode file that went missing.
+type MissingFileResolver struct {
+	r       db.MediaFile
+	purgeAt time.Time
+}
+
+// UUID returns the UUID of the file.
+func (r *MissingFileResolver) UUID() string {
+	switch f := r.r.(type) {
+	case db.MovieFile:
+		return f.UUID
+	case db.EpisodeFile:
+		return f.UUID
+	}
+	return ""
+}
+
+// FileName returns the name of the file.
+func (r *MissingFileResolver) FileName() string {
+	return r.r.GetFileName()
+}
+
+// FilePath returns the path the file used to be at.
+func (r *MissingFileResolver) FilePath() (string, error) {
+	fileLocator, err := filesystem.ParseFileLocator(r.r.GetFilePath())
+	if err != nil {
+		return "", err
+	}
+	return fileLocator.Path, nil
+}
+
+// Library returns the library the file belongs to.
+func (r *MissingFileResolver) Library() *LibraryResolver {
+	return &LibraryResolver{r: Library{Library: *r.r.GetLibrary()}}
+}
+
+// MissingSince returns when the file went missing.
+func (r *MissingFileResolver) MissingSince() string {
+	return r.r.GetMissingSince().Format(time.RFC3339)
+}
+
+// PurgeAt returns when the file and its metadata will be removed for good.
+func (r *MissingFileResolver) PurgeAt() string {
+	return r.purgeAt.Format(time.RFC3339)
+}
+
+// MissingFileResponse holds a missing file and an error if needed.
+type MissingFileResponse struct {
+	Error       *ErrorResolver
+	MissingFile *MissingFileResolver
+}
+
+// MissingFileResponseResolver resolves MissingFileResponse.
+type MissingFileResponseResolver struct {
+	r MissingFileResponse
+}
+
+// Error returns error.
+func (r *MissingFileResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// MissingFile returns the missing file.
+func (r *MissingFileResponseResolver) MissingFile() *MissingFileResolver {
+	return r.r.MissingFile
+}
+
+func missingFileErrResponse(err error) *MissingFileResponseResolver {
+	return &MissingFileResponseResolver{MissingFileResponse{Error: CreateErrResolver(err)}}
+}
+
+func (r *Resolver) newMissingFileResolver(file db.MediaFile) *MissingFileResolver {
+	return &MissingFileResolver{r: file, purgeAt: r.trash.PurgeAt(file)}
+}
+
+// MissingFiles returns all files that went missing and haven't been purged yet.
+func (r *Resolver) MissingFiles(ctx context.Context) (files []*MissingFileResolver) {
+	if err := ifAdmin(ctx); err != nil {
+		return files
+	}
+	for _, f := range db.FindMissingMediaFiles() {
+		files = append(files, r.newMissingFileResolver(f))
+	}
+	return files
+}
+
+// RestoreMissingFile makes a missing file that is back available again without waiting for the
+// next scan.
+func (r *Resolver) RestoreMissingFile(ctx context.Context, args struct{ UUID string }) *MissingFileResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return missingFileErrResponse(err)
+	}
+
+	file, err := db.FindMissingMediaFileByUUID(args.UUID)
+	if err != nil {
+		return missingFileErrResponse(err)
+	}
+	if err := r.trash.Restore(file); err != nil {
+		return missingFileErrResponse(err)
+	}
+
+	return &MissingFileResponseResolver{MissingFileResponse{MissingFile: r.newMissingFileResolver(file)}}
+}
+
+// PurgeMissingFile removes a missing file and its metadata and play states right away.
+func (r *Resolver) PurgeMissingFile(ctx context.Context, args struct{ UUID string }) *MissingFileResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return missingFileErrResponse(err)
+	}
+
+	file, err := db.FindMissingMediaFileByUUID(args.UUID)
+	if err != nil {
+		return missingFileErrResponse(err)
+	}
+	r.trash.Purge(file)
+
+	return &MissingFileResponseResolver{MissingFileResponse{MissingFile: r.newMissingFileResolver(file)}}
+}
@@ -87,6 +87,16 @@ func (r *MovieResolver) PosterPath() string {
 	return r.r.PosterPath
 }
 
+// FrameGrabPath returns the frame grabbed from the first file of this movie that has one.
+func (r *MovieResolver) FrameGrabPath() string {
+	for _, file := range r.r.MovieFiles {
+		if file.FrameGrabPath != "" {
+			return file.FrameGrabPath
+		}
+	}
+	return ""
+}
+
 // Year returns year
 func (r *MovieResolver) Year() string {
 	return r.r.YearAsString()
@@ -143,6 +153,19 @@ func (r *MovieFileResolver) FilePath() (string, error) {
 	return fileLocator.Path, nil
 }
 
+// FrameGrabPath returns the frame grabbed from this file.
+func (r *MovieFileResolver) FrameGrabPath() string {
+	return r.r.FrameGrabPath
+}
+
+// OptimizedVersions returns the pre-transcoded versions of this file.
+func (r *MovieFileResolver) OptimizedVersions() (versions []*OptimizedVersionResolver) {
+	for _, v := range db.FindOptimizedVersionsForFile(r.r) {
+		versions = append(versions, &OptimizedVersionResolver{r: v})
+	}
+	return versions
+}
+
 // FileName returns movie filename
 func (r *MovieFileResolver) FileName() string {
 	return r.r.FileName
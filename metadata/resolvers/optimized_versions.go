@@ -0,0 +1,211 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// OptimizedVersionResolver resolves an OptimizedVersion.
+type OptimizedVersionResolver struct {
+	r db.OptimizedVersion
+}
+
+// UUID returns the UUID of the optimized version.
+func (r *OptimizedVersionResolver) UUID() string {
+	return r.r.UUID
+}
+
+// Preset returns the name of the video encoder preset used.
+func (r *OptimizedVersionResolver) Preset() string {
+	return r.r.Preset
+}
+
+// State returns the state of the transcoding job.
+func (r *OptimizedVersionResolver) State() string {
+	return r.r.State
+}
+
+// Progress returns the progress of the transcoding job in percent.
+func (r *OptimizedVersionResolver) Progress() float64 {
+	return r.r.Progress
+}
+
+// Error returns why the transcoding job failed, if it did.
+func (r *OptimizedVersionResolver) Error() string {
+	return r.r.Error
+}
+
+// FileSize returns the size of the optimized file in bytes.
+func (r *OptimizedVersionResolver) FileSize() int32 {
+	return int32(r.r.Size)
+}
+
+// MovieFile returns the original file if this is an optimized version of a MovieFile.
+func (r *OptimizedVersionResolver) MovieFile() *MovieFileResolver {
+	file, _ := r.r.GetSourceFile()
+	if mf, ok := file.(db.MovieFile); ok {
+		return &MovieFileResolver{r: mf}
+	}
+	return nil
+}
+
+// EpisodeFile returns the original file if this is an optimized version of an EpisodeFile.
+func (r *OptimizedVersionResolver) EpisodeFile() *EpisodeFileResolver {
+	file, _ := r.r.GetSourceFile()
+	if ef, ok := file.(db.EpisodeFile); ok {
+		return &EpisodeFileResolver{r: ef}
+	}
+	return nil
+}
+
+// OptimizedVersionsResponse holds the affected optimized versions and an error if needed.
+type OptimizedVersionsResponse struct {
+	Error             *ErrorResolver
+	OptimizedVersions []*OptimizedVersionResolver
+}
+
+// OptimizedVersionsResponseResolver resolves OptimizedVersionsResponse.
+type OptimizedVersionsResponseResolver struct {
+	r OptimizedVersionsResponse
+}
+
+// Error returns error.
+func (r *OptimizedVersionsResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// OptimizedVersions returns the affected optimized versions.
+func (r *OptimizedVersionsResponseResolver) OptimizedVersions() []*OptimizedVersionResolver {
+	return r.r.OptimizedVersions
+}
+
+func optimizedVersionsErrResponse(err error) *OptimizedVersionsResponseResolver {
+	return &OptimizedVersionsResponseResolver{OptimizedVersionsResponse{Error: CreateErrResolver(err)}}
+}
+
+// OptimizedVersions returns all optimized versions and transcoding jobs.
+func (r *Resolver) OptimizedVersions(ctx context.Context) (versions []*OptimizedVersionResolver) {
+	if err := ifAdmin(ctx); err != nil {
+		return versions
+	}
+	for _, v := range db.FindAllOptimizedVersions() {
+		versions = append(versions, &OptimizedVersionResolver{r: v})
+	}
+	return versions
+}
+
+// findFilesToOptimize collects the files belonging to the movie, season, series or file with the
+// given UUID.
+func findFilesToOptimize(uuid string) ([]db.MediaFile, error) {
+	var files []db.MediaFile
+
+	if movie, err := db.FindMovieByUUID(uuid); err == nil {
+		for _, f := range movie.MovieFiles {
+			files = append(files, f)
+		}
+		return files, nil
+	}
+
+	if season, err := db.FindSeasonByUUID(uuid); err == nil {
+		for _, e := range season.Episodes {
+			for _, f := range e.EpisodeFiles {
+				files = append(files, f)
+			}
+		}
+		return files, nil
+	}
+
+	if series, err := db.FindSeriesByUUID(uuid); err == nil {
+		for _, s := range series.Seasons {
+			for _, e := range s.Episodes {
+				for _, f := range e.EpisodeFiles {
+					files = append(files, f)
+				}
+			}
+		}
+		return files, nil
+	}
+
+	if file := db.FindContentByUUID(uuid); file != nil {
+		return append(files, file), nil
+	}
+
+	return nil, fmt.Errorf("no movie, season, series or file found for UUID %s", uuid)
+}
+
+// CreateOptimizedVersions queues optimized versions of all files of the given movie, season,
+// series, file or library.
+func (r *Resolver) CreateOptimizedVersions(ctx context.Context, args struct {
+	UUID      *string
+	LibraryID *int32
+	Preset    string
+}) *OptimizedVersionsResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+
+	var files []db.MediaFile
+	if args.UUID != nil {
+		var err error
+		files, err = findFilesToOptimize(*args.UUID)
+		if err != nil {
+			return optimizedVersionsErrResponse(err)
+		}
+	} else if args.LibraryID != nil {
+		libraryID := uint(*args.LibraryID)
+		for _, f := range db.FindMovieFilesInLibrary(libraryID) {
+			files = append(files, f)
+		}
+		for _, f := range db.FindEpisodeFilesInLibrary(libraryID) {
+			files = append(files, f)
+		}
+	} else {
+		return optimizedVersionsErrResponse(fmt.Errorf("either uuid or libraryID is required"))
+	}
+
+	var versions []*OptimizedVersionResolver
+	for _, f := range files {
+		v, err := r.optimizer.Queue(f, args.Preset)
+		if err != nil {
+			return optimizedVersionsErrResponse(err)
+		}
+		versions = append(versions, &OptimizedVersionResolver{r: *v})
+	}
+
+	return &OptimizedVersionsResponseResolver{OptimizedVersionsResponse{OptimizedVersions: versions}}
+}
+
+// CancelOptimizedVersion cancels a queued or running transcoding job.
+func (r *Resolver) CancelOptimizedVersion(ctx context.Context, args struct{ UUID string }) *OptimizedVersionsResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+
+	v, err := db.FindOptimizedVersionByUUID(args.UUID)
+	if err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+	if err := r.optimizer.Cancel(v); err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+
+	return &OptimizedVersionsResponseResolver{OptimizedVersionsResponse{
+		OptimizedVersions: []*OptimizedVersionResolver{{r: *v}}}}
+}
+
+// DeleteOptimizedVersion removes an optimized version and its file, cancelling it if required.
+func (r *Resolver) DeleteOptimizedVersion(ctx context.Context, args struct{ UUID string }) *OptimizedVersionsResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+
+	v, err := db.FindOptimizedVersionByUUID(args.UUID)
+	if err != nil {
+		return optimizedVersionsErrResponse(err)
+	}
+	r.optimizer.Delete(v)
+
+	return &OptimizedVersionsResponseResolver{OptimizedVersionsResponse{
+		OptimizedVersions: []*OptimizedVersionResolver{{r: *v}}}}
+}
@@ -0,0 +1,232 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/managers"
+	"time"
+)
+
+// PartyResolver resolves a watch-together party.
+type PartyResolver struct {
+	r managers.Party
+}
+
+func usersByID(ids []uint) (users []*UserResolver) {
+	users = []*UserResolver{}
+	for _, id := range ids {
+		if user, err := db.FindUser(id); err == nil {
+			users = append(users, &UserResolver{*user})
+		}
+	}
+	return users
+}
+
+// ID returns the ID of the party.
+func (r *PartyResolver) ID() string {
+	return r.r.ID
+}
+
+// Host returns the user that created the party.
+func (r *PartyResolver) Host() *UserResolver {
+	user, err := db.FindUser(r.r.HostID)
+	if err != nil {
+		return nil
+	}
+	return &UserResolver{*user}
+}
+
+// MediaUUID returns the UUID of the movie or episode that is watched.
+func (r *PartyResolver) MediaUUID() string {
+	return r.r.MediaUUID
+}
+
+// AllowMemberControl returns whether participants other than the host may control playback.
+func (r *PartyResolver) AllowMemberControl() bool {
+	return r.r.AllowMemberControl
+}
+
+// Members returns the users that were invited to the party.
+func (r *PartyResolver) Members() []*UserResolver {
+	return usersByID(r.r.Members)
+}
+
+// Participants returns the users that are currently in the party.
+func (r *PartyResolver) Participants() []*UserResolver {
+	return usersByID(r.r.Participants)
+}
+
+// Playing returns whether the party is playing or paused.
+func (r *PartyResolver) Playing() bool {
+	return r.r.Playing
+}
+
+// Position returns where playback should be right now in seconds.
+func (r *PartyResolver) Position() float64 {
+	return r.r.CurrentPosition()
+}
+
+// UpdatedAt returns when playback was last changed, in RFC 3339 format.
+func (r *PartyResolver) UpdatedAt() string {
+	return r.r.UpdatedAt.Format(time.RFC3339)
+}
+
+// PartyResponse is returned by party mutations.
+type PartyResponse struct {
+	Error *ErrorResolver
+	Party *PartyResolver
+}
+
+// PartyResponseResolver resolves PartyResponse.
+type PartyResponseResolver struct {
+	r PartyResponse
+}
+
+// Error returns error.
+func (r *PartyResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Party returns the party.
+func (r *PartyResponseResolver) Party() *PartyResolver {
+	return r.r.Party
+}
+
+func partyResponse(party managers.Party, err error) *PartyResponseResolver {
+	if err != nil {
+		return &PartyResponseResolver{PartyResponse{Error: CreateErrResolver(err)}}
+	}
+	return &PartyResponseResolver{PartyResponse{Party: &PartyResolver{party}}}
+}
+
+// PartyEventResolver resolves an event that happened in a party.
+type PartyEventResolver struct {
+	r managers.PartyEvent
+}
+
+// Type returns what happened, e.g. "play", "pause", "seek", "join", "leave", "closed" or "sync".
+func (r *PartyEventResolver) Type() string {
+	return r.r.Type
+}
+
+// Party returns the state of the party after the event.
+func (r *PartyEventResolver) Party() *PartyResolver {
+	return &PartyResolver{r.r.Party}
+}
+
+// User returns the user that caused the event.
+func (r *PartyEventResolver) User() *UserResolver {
+	user, err := db.FindUser(r.r.UserID)
+	if err != nil {
+		return nil
+	}
+	return &UserResolver{*user}
+}
+
+// Parties returns all parties the current user is a member of.
+func (r *Resolver) Parties(ctx context.Context) (parties []*PartyResolver) {
+	userID, _ := auth.UserID(ctx)
+	for _, p := range r.parties.PartiesForUser(userID) {
+		parties = append(parties, &PartyResolver{p})
+	}
+	return parties
+}
+
+// CreateParty creates a party for the given media item, hosted by the current user.
+func (r *Resolver) CreateParty(ctx context.Context, args struct {
+	MediaUUID          string
+	AllowMemberControl *bool
+}) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+
+	if _, err := db.FindMovieByUUID(args.MediaUUID); err != nil {
+		if _, err := db.FindEpisodeByUUID(args.MediaUUID); err != nil {
+			return partyResponse(managers.Party{}, fmt.Errorf("no media item with UUID %s", args.MediaUUID))
+		}
+	}
+
+	allowMemberControl := args.AllowMemberControl != nil && *args.AllowMemberControl
+	return partyResponse(r.parties.CreateParty(userID, args.MediaUUID, allowMemberControl), nil)
+}
+
+// InviteToParty allows the given user to join a party hosted by the current user.
+func (r *Resolver) InviteToParty(ctx context.Context, args struct {
+	PartyID string
+	UserID  int32
+}) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	if _, err := db.FindUser(uint(args.UserID)); err != nil {
+		return partyResponse(managers.Party{}, err)
+	}
+	return partyResponse(r.parties.Invite(args.PartyID, userID, uint(args.UserID)))
+}
+
+// JoinParty adds the current user to a party they were invited to.
+func (r *Resolver) JoinParty(ctx context.Context, args struct{ PartyID string }) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	return partyResponse(r.parties.Join(args.PartyID, userID))
+}
+
+// LeaveParty removes the current user from the party. If the host leaves, the party is closed.
+func (r *Resolver) LeaveParty(ctx context.Context, args struct{ PartyID string }) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	return partyResponse(r.parties.Leave(args.PartyID, userID))
+}
+
+// CloseParty closes the party for everyone.
+func (r *Resolver) CloseParty(ctx context.Context, args struct{ PartyID string }) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	return partyResponse(r.parties.Close(args.PartyID, userID, ifAdmin(ctx) == nil))
+}
+
+// UpdatePartyPlayback plays, pauses or seeks the party's playback.
+func (r *Resolver) UpdatePartyPlayback(ctx context.Context, args struct {
+	PartyID  string
+	Action   string
+	Position float64
+}) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	return partyResponse(r.parties.UpdatePlayback(args.PartyID, userID, args.Action, args.Position))
+}
+
+// ReportPartyPosition tells the server where the current user's player is. If it drifted from
+// the rest of the party, a "sync" event is sent to the user.
+func (r *Resolver) ReportPartyPosition(ctx context.Context, args struct {
+	PartyID  string
+	Position float64
+}) *PartyResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	if _, err := r.parties.ReportPosition(args.PartyID, userID, args.Position); err != nil {
+		return partyResponse(managers.Party{}, err)
+	}
+	return partyResponse(r.parties.FindParty(args.PartyID, userID))
+}
+
+// PartyEvents creates a subscription for all events of the given party.
+func (r *Resolver) PartyEvents(ctx context.Context, args struct{ PartyID string }) <-chan *PartyEventResolver {
+	c := make(chan *PartyEventResolver)
+	userID, _ := auth.UserID(ctx)
+
+	events, err := r.parties.Subscribe(args.PartyID, userID, ctx.Done())
+	if err != nil {
+		log.WithFields(log.Fields{"party": args.PartyID, "error": err}).Debugln("Not subscribing to party")
+		close(c)
+		return c
+	}
+
+	log.Debugln("Adding subscription to PartyEvents")
+	go func() {
+		defer close(c)
+		for e := range events {
+			select {
+			case c <- &PartyEventResolver{e}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c
+}
@@ -0,0 +1,71 @@
+package resolvers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"testing"
+)
+
+func TestParty(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+
+	host, _ := db.CreateUser("host", "password", false)
+	guest, _ := db.CreateUser("guest", "password", false)
+	hostCtx := auth.ContextWithUserID(context.Background(), host.ID)
+	guestCtx, cancelGuest := context.WithCancel(auth.ContextWithUserID(context.Background(), guest.ID))
+	defer cancelGuest()
+
+	movie := db.Movie{Title: "Party Movie"}
+	db.CreateMovie(&movie)
+
+	res := r.CreateParty(hostCtx, struct {
+		MediaUUID          string
+		AllowMemberControl *bool
+	}{MediaUUID: movie.UUID})
+	assert.Nil(t, res.Error())
+	partyID := res.Party().ID()
+
+	// Guests have to be invited first.
+	assert.NotNil(t, r.JoinParty(guestCtx, struct{ PartyID string }{partyID}).Error())
+	assert.Nil(t, r.InviteToParty(hostCtx, struct {
+		PartyID string
+		UserID  int32
+	}{partyID, int32(guest.ID)}).Error())
+	assert.Nil(t, r.JoinParty(guestCtx, struct{ PartyID string }{partyID}).Error())
+
+	events := r.PartyEvents(guestCtx, struct{ PartyID string }{partyID})
+
+	// Only the host controls playback unless allowMemberControl is set.
+	playback := struct {
+		PartyID  string
+		Action   string
+		Position float64
+	}{partyID, "play", 60}
+	assert.NotNil(t, r.UpdatePartyPlayback(guestCtx, playback).Error())
+	assert.Nil(t, r.UpdatePartyPlayback(hostCtx, playback).Error())
+
+	e := <-events
+	assert.Equal(t, "play", e.Type())
+	assert.True(t, e.Party().Playing())
+	assert.Equal(t, "host", e.User().Username())
+
+	// A guest far behind is told to catch up.
+	assert.Nil(t, r.ReportPartyPosition(guestCtx, struct {
+		PartyID  string
+		Position float64
+	}{partyID, 10}).Error())
+	e = <-events
+	assert.Equal(t, "sync", e.Type())
+	assert.InDelta(t, 60, e.Party().Position(), 1)
+
+	// The party ends when the host leaves.
+	assert.Nil(t, r.LeaveParty(hostCtx, struct{ PartyID string }{partyID}).Error())
+	e = <-events
+	assert.Equal(t, "closed", e.Type())
+	_, open := <-events
+	assert.False(t, open)
+	assert.Empty(t, r.Parties(guestCtx))
+}
@@ -44,6 +44,7 @@ func (r *Resolver) CreatePlayState(ctx context.Context, args *playStateArgs) *Pl
 		UserID:    userID,
 		Finished:  args.Finished,
 		Playtime:  args.Playtime,
+		Source:    db.PlayStateSourceClient,
 	}
 
 	// This apparently means mark as unwatched
@@ -13,6 +13,12 @@ import (
 type Resolver struct {
 	env                *app.MetadataContext
 	libs               []*managers.LibraryManager
+	optimizer          *managers.OptimizationManager
+	downloads          *managers.DownloadManager
+	clips              *managers.ClipManager
+	trash              *managers.TrashManager
+	parties            *managers.PartyManager
+	devices            *managers.DeviceManager
 	subscriber         *graphqlLibrarySubscriber
 	exitChan           chan bool
 	movieAddedEvents   chan *MovieAddedEvent
@@ -26,6 +32,12 @@ type Resolver struct {
 func NewResolver(env *app.MetadataContext) *Resolver {
 	r := &Resolver{
 		env:                env,
+		optimizer:          managers.NewOptimizationManager(),
+		downloads:          managers.NewDownloadManager(),
+		clips:              managers.NewClipManager(),
+		trash:              managers.NewTrashManager(),
+		parties:            managers.NewPartyManager(),
+		devices:            managers.NewDeviceManager(),
 		exitChan:           env.ExitChan,
 		subscriberChan:     make(chan *graphqlSubscriber),
 		movieAddedEvents:   make(chan *MovieAddedEvent),
@@ -43,10 +55,18 @@ func NewResolver(env *app.MetadataContext) *Resolver {
 	}
 
 	go r.startGraphQLSubscriptionManager(r.exitChan)
+	env.OnCleanup(r.shutdown)
 
 	return r
 }
 
+// shutdown stops the background managers, cancelling their running ffmpeg jobs.
+func (r *Resolver) shutdown() {
+	r.optimizer.Shutdown()
+	r.downloads.Shutdown()
+	r.clips.Shutdown()
+}
+
 // AddLibraryManager adds a new manager
 func (r *Resolver) AddLibraryManager(lib *db.Library) {
 	man := managers.NewLibraryManager(lib, r.env.MetadataManager)
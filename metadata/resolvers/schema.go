@@ -21,6 +21,15 @@ var SchemaTxt = `
 		episodeAdded(): EpisodeAddedEvent!
 		seriesAdded(): SeriesAddedEvent!
 		seasonAdded(): SeasonAddedEvent!
+		# Periodically sends all playback sessions. Admin only.
+		activeSessionsUpdated(): ActiveSessionsUpdatedEvent!
+		# Playback changes and members coming and going in the given party.
+		partyEvents(partyID: String!): PartyEvent!
+		# Announces a device of the current user and sends it the commands addressed to it. The
+		# device is online for as long as this subscription lasts.
+		deviceCommands(deviceID: String!, name: String!, type: String): DeviceCommand!
+		# State changes of the given device of the current user, or all of their devices.
+		deviceStateUpdated(deviceID: String): Device!
 	}
 
 	# The query type, represents all of the entry points into our object graph
@@ -43,6 +52,33 @@ var SchemaTxt = `
 
 		tmdbSearchMovies(query: String!): [TmdbMovieSearchItem]!
 		tmdbSearchSeries(query: String!): [TmdbSeriesSearchItem]!
+
+		# All optimized versions, including queued and running transcoding jobs.
+		optimizedVersions(): [OptimizedVersion]!
+
+		# Offline downloads of the current user.
+		downloads(): [Download]!
+
+		# Clips of the current user.
+		clips(): [Clip]!
+
+		# All playback sessions, i.e. what is being watched right now. Admin only.
+		activeSessions(): [ActiveSession]!
+
+		# Unexpired streaming tickets of the given user. Admins or the user themselves only.
+		streamingTickets(userID: Int!): [StreamingTicket]!
+
+		# Watch-together parties the current user is a member of.
+		parties(): [Party]!
+
+		# Devices of the current user that can be remote controlled.
+		devices(): [Device]!
+
+		# All share links for admins, the current user's own links otherwise.
+		shareLinks(): [ShareLink]!
+
+		# Files that went missing and are hidden until they are found again or purged. Admin only.
+		missingFiles(): [MissingFile]!
 	}
 
 	type Mutation {
@@ -54,6 +90,21 @@ var SchemaTxt = `
 		# Delete a library and remove all collected metadata.
 		deleteLibrary(id: Int!): LibraryResponse!
 
+		# Change the name and scanning rules of a library. Only the given fields are changed.
+		updateLibrary(id: Int!, name: String, excludePatterns: [String!], extensions: [String!], minFileSize: Int): LibraryResponse!
+
+		# Add another directory to a library, e.g. on a second disk or another Rclone remote.
+		# 'backend' can be 0 for local and 1 for Rclone.
+		addLibraryRoot(libraryID: Int!, filePath: String!, backend: Int!, rcloneName: String): LibraryResponse!
+
+		# Remove a directory from a library together with the metadata of the files in it. The last
+		# directory of a library can't be removed, delete the library instead.
+		removeLibraryRoot(id: Int!): LibraryResponse!
+
+		# Change the metadata, scan and rclone VFS settings of a library. Only the given fields are
+		# changed.
+		updateLibrarySettings(libraryID: Int!, metadataLanguage: String, metadataRegion: String, agent: String, rescanIntervalMinutes: Int, vfsCacheMode: String, vfsCacheMaxSizeMB: Int, vfsChunkSizeMB: Int, probeConcurrency: Int): LibrarySettingsResponse!
+
 		# Create a invite code so a user can register on the server
 		createUserInvite(): UserInviteResponse!
 
@@ -81,6 +132,340 @@ var SchemaTxt = `
 
 		# Retag one or multiple EpisodeFiles
 		updateEpisodeFileMetadata(input: UpdateEpisodeFileMetadataInput!): UpdateEpisodeFileMetadataPayload!
+
+		# Pre-transcode all files of the given movie, season, series or file, or of the whole library,
+		# to the given video preset, e.g. "720-5000k-video".
+		createOptimizedVersions(uuid: String, libraryID: Int, preset: String!): OptimizedVersionsResponse!
+
+		# Cancel a queued or running optimized version transcoding job.
+		cancelOptimizedVersion(uuid: String!): OptimizedVersionsResponse!
+
+		# Delete an optimized version and its file.
+		deleteOptimizedVersion(uuid: String!): OptimizedVersionsResponse!
+
+		# Transcode the given movie, episode or file to a single MP4 file to take offline. If no
+		# audio streams are given, all are included. Only text subtitles can be included.
+		createDownload(uuid: String!, preset: String!, audioStreamIDs: [Int!], subtitleStreamIDs: [Int!]): DownloadResponse!
+
+		# Delete a download, cancelling it if it is still being transcoded.
+		deleteDownload(uuid: String!): DownloadResponse!
+
+		# Cut the part between start and end, in seconds, out of the given movie, episode or file
+		# into a small MP4 file that can be shared with anyone through its clipPath until it
+		# expires. The given embedded subtitle stream is burned into the video.
+		createClip(uuid: String!, start: Float!, end: Float!, subtitleStreamID: Int): ClipResponse!
+
+		# Delete a clip, cancelling it if it is still being transcoded.
+		deleteClip(uuid: String!): ClipResponse!
+
+		# Make a missing file that is back available again without waiting for the next scan.
+		restoreMissingFile(uuid: String!): MissingFileResponse!
+
+		# Remove a missing file and its metadata and play states right away.
+		purgeMissingFile(uuid: String!): MissingFileResponse!
+
+		# Limit the streaming bitrate of the given user in bits per second. 0 means no limit other
+		# than the global one.
+		updateUserBitrateLimits(id: Int!, maxLocalBitrate: Int!, maxRemoteBitrate: Int!): UserResponse!
+
+		# Stop the client session the given playback session belongs to, killing its transcoding
+		# processes and revoking its streaming tickets. Further segment requests are rejected with
+		# the given reason.
+		stopSession(playbackSessionID: String!, reason: String): StopSessionResponse!
+
+		# Revoke a streaming ticket so that it can't be used to stream anymore.
+		revokeStreamingTicket(jti: String!): RevokeStreamingTicketsResponse!
+
+		# Revoke all streaming tickets of the given user, stopping all of their playback.
+		revokeStreamingTickets(userID: Int!): RevokeStreamingTicketsResponse!
+
+		# Create a watch-together party for a movie or episode, hosted by the current user.
+		# If allowMemberControl is set, everyone in the party can play, pause and seek.
+		createParty(mediaUUID: String!, allowMemberControl: Boolean): PartyResponse!
+
+		# Allow another user to join a party you host.
+		inviteToParty(partyID: String!, userID: Int!): PartyResponse!
+
+		joinParty(partyID: String!): PartyResponse!
+
+		# Leave a party. If the host leaves, the party is closed.
+		leaveParty(partyID: String!): PartyResponse!
+
+		# Close a party for everyone. Host or admin only.
+		closeParty(partyID: String!): PartyResponse!
+
+		# Play, pause or seek the party to the given position in seconds. action is one of
+		# "play", "pause" or "seek".
+		updatePartyPlayback(partyID: String!, action: String!, position: Float!): PartyResponse!
+
+		# Report the current user's playback position in seconds. If it drifted from the party,
+		# a "sync" event is sent to the user.
+		reportPartyPosition(partyID: String!, position: Float!): PartyResponse!
+
+		# Send a command to another device of the current user.
+		sendDeviceCommand(deviceID: String!, command: DeviceCommandInput!): DeviceResponse!
+
+		# Tell the other devices of the current user what this device is playing.
+		reportDeviceState(deviceID: String!, state: DeviceStateInput!): DeviceResponse!
+
+		# Create a link through which a movie or episode can be streamed without an account.
+		# Admins and users allowed to share only. Valid for 48 hours and unlimited plays unless given.
+		createShareLink(mediaUUID: String!, validForHours: Int, maxPlays: Int): ShareLinkResponse!
+
+		# Revoke a share link, stopping streams through it.
+		revokeShareLink(token: String!): ShareLinkResponse!
+
+		# Allow or disallow a user to create share links.
+		updateUserCanShare(id: Int!, canShare: Boolean!): UserResponse!
+	}
+
+	# A link through which a single movie or episode can be streamed without an account.
+	type ShareLink {
+		token: String!
+		# Path of the public landing endpoint that hands out streaming tickets.
+		path: String!
+		mediaUUID: String!
+		createdBy: User
+		expiresAt: String!
+		# 0 if the number of plays is not limited.
+		maxPlays: Int!
+		plays: Int!
+		revoked: Boolean!
+	}
+
+	type ShareLinkResponse {
+		shareLink: ShareLink
+		error: Error
+	}
+
+	input DeviceCommandInput {
+		# One of "play", "pause", "resume", "seek", "stop", "setAudioStream" or "setSubtitleStream".
+		action: String!
+		# Required for "play".
+		mediaUUID: String
+		# Position in seconds for "play" and "seek".
+		position: Float
+		# Stream for "setAudioStream" and "setSubtitleStream", -1 disables subtitles.
+		streamID: Int
+		# The device sending the command, if it is one.
+		fromDeviceID: String
+	}
+
+	input DeviceStateInput {
+		mediaUUID: String
+		position: Float!
+		playing: Boolean!
+		audioStreamID: Int
+		subtitleStreamID: Int
+	}
+
+	# A client of a user that can be remote controlled from their other clients.
+	type Device {
+		id: String!
+		name: String!
+		type: String!
+		online: Boolean!
+		lastSeen: String!
+		state: DeviceState!
+	}
+
+	type DeviceState {
+		# Empty if nothing is playing.
+		mediaUUID: String!
+		position: Float!
+		playing: Boolean!
+		audioStreamID: Int!
+		# -1 if subtitles are disabled.
+		subtitleStreamID: Int!
+		updatedAt: String!
+	}
+
+	type DeviceCommand {
+		action: String!
+		mediaUUID: String!
+		position: Float!
+		streamID: Int!
+		fromDeviceID: String!
+	}
+
+	type DeviceResponse {
+		device: Device
+		error: Error
+	}
+
+	# A watch-together room in which all participants play the same media item in sync.
+	type Party {
+		id: String!
+		host: User
+		mediaUUID: String!
+		allowMemberControl: Boolean!
+		# Users that were invited, including the host.
+		members: [User]!
+		# Users that are currently in the party.
+		participants: [User]!
+		playing: Boolean!
+		# Where playback should be right now in seconds.
+		position: Float!
+		updatedAt: String!
+	}
+
+	type PartyResponse {
+		party: Party
+		error: Error
+	}
+
+	type PartyEvent {
+		# One of "play", "pause", "seek", "join", "leave", "invite", "closed" or "sync".
+		type: String!
+		party: Party!
+		user: User
+	}
+
+	type RevokeStreamingTicketsResponse {
+		success: Boolean!
+		error: Error
+	}
+
+	# A streaming ticket, i.e. a token that grants access to stream a file.
+	type StreamingTicket {
+		jti: String!
+		filePath: String!
+		createdAt: String!
+		expiresAt: String!
+		revoked: Boolean!
+	}
+
+	type StopSessionResponse {
+		success: Boolean!
+		error: Error
+	}
+
+	type ActiveSessionsUpdatedEvent {
+		sessions: [ActiveSession]!
+	}
+
+	# A stream that is being played.
+	type ActiveSession {
+		# ID to stop the session with
+		playbackSessionID: String!
+		# ID of the client session, shared between its audio and video streams
+		sessionID: String!
+		user: User
+		mediaItem: MediaItem
+		# One of "video", "audio" or "subtitle"
+		streamType: String!
+		# ID of the representation, e.g. "direct" or "preset:720-5000k-video"
+		representation: String!
+		# Whether the stream is transcoded rather than transmuxed
+		transcoded: Boolean!
+		# How far the transcoder got through the stream in percent
+		progress: Float!
+		# Whether the transcoder is throttled because it is far enough ahead of the client
+		throttled: Boolean!
+		clientIP: String!
+		# Average rate at which segments were served in bits per second
+		bandwidth: Int!
+		# When the client last requested a segment, in RFC 3339 format
+		lastAccessed: String!
+	}
+
+	type DownloadResponse {
+		download: Download
+		error: Error
+	}
+
+	# A file transcoded for taking it offline.
+	type Download {
+		uuid: String!
+		# UUID of the MovieFile or EpisodeFile
+		mediaFileUUID: String!
+		fileName: String!
+		# Name of the video encoder preset, e.g. "720-5000k-video"
+		preset: String!
+		# One of "queued", "transcoding", "done", "failed" or "cancelled"
+		state: String!
+		# Transcoding progress in percent
+		progress: Float!
+		# Why transcoding failed, if it did
+		error: String!
+		# FileSize in bytes
+		fileSize: Int!
+		# When the download will be removed, in RFC 3339 format. Empty until it is done.
+		expiresAt: String!
+		# Path with a JWT to fetch the file from. Empty until it is done.
+		downloadPath: String!
+	}
+
+	type MissingFileResponse {
+		missingFile: MissingFile
+		error: Error
+	}
+
+	# A movie or episode file that went missing, e.g. because its drive isn't mounted.
+	type MissingFile {
+		# UUID of the MovieFile or EpisodeFile
+		uuid: String!
+		fileName: String!
+		# Path the file used to be at
+		filePath: String!
+		library: Library!
+		# When the file went missing, in RFC 3339 format
+		missingSince: String!
+		# When the file and its metadata will be removed for good, in RFC 3339 format
+		purgeAt: String!
+	}
+
+	type ClipResponse {
+		clip: Clip
+		error: Error
+	}
+
+	# A short part of a movie or episode cut into a file to share it.
+	type Clip {
+		uuid: String!
+		# UUID of the MovieFile or EpisodeFile
+		mediaFileUUID: String!
+		fileName: String!
+		# Start and end of the clip in seconds
+		start: Float!
+		end: Float!
+		# Subtitle stream burned into the clip, -1 if none
+		subtitleStreamID: Int!
+		# One of "queued", "transcoding", "done", "failed" or "cancelled"
+		state: String!
+		# Transcoding progress in percent
+		progress: Float!
+		# Why transcoding failed, if it did
+		error: String!
+		# FileSize in bytes
+		fileSize: Int!
+		# When the clip will be removed, in RFC 3339 format. Empty until it is done.
+		expiresAt: String!
+		# Public path with a JWT to fetch the clip from. Empty until it is done.
+		clipPath: String!
+	}
+
+	type OptimizedVersionsResponse {
+		optimizedVersions: [OptimizedVersion]!
+		error: Error
+	}
+
+	# A version of a file that was transcoded ahead of time for streaming with limited bandwidth.
+	type OptimizedVersion {
+		uuid: String!
+		# Name of the video encoder preset, e.g. "720-5000k-video"
+		preset: String!
+		# One of "queued", "transcoding", "done", "failed" or "cancelled"
+		state: String!
+		# Transcoding progress in percent
+		progress: Float!
+		# Why transcoding failed, if it did
+		error: String!
+		# FileSize in bytes
+		fileSize: Int!
+		# The original file, depending on the type
+		movieFile: MovieFile
+		episodeFile: EpisodeFile
 	}
 
 	type LibraryResponse {
@@ -125,6 +510,12 @@ var SchemaTxt = `
 		id: Int!
 		username: String!
 		admin: Boolean!
+		# Maximum streaming bitrates in bits per second for clients in the local network and
+		# remote clients. 0 means no limit other than the global one.
+		maxLocalBitrate: Int!
+		maxRemoteBitrate: Int!
+		# Whether the user may create share links. Admins always can.
+		canShare: Boolean!
 	}
 
 	type PlayState {
@@ -148,9 +539,12 @@ var SchemaTxt = `
 		# Human readable name of the Library (unused)
 		name: String!
 
-		# Path that this library manages
+		# Path of the directory the library was created with, see roots for all of them
 		filePath: String!
 
+		# All directories that this library manages
+		roots: [LibraryRoot!]!
+
 		# Whether olaris-server is currently scanning the library
 		isRefreshing: Boolean!
 
@@ -160,13 +554,72 @@ var SchemaTxt = `
 		# If Backend is Rclone it will return the name of the remote
 		rcloneName: String
 
-		# This attribute will be false whenever a Rclone remote can't be reached
+		# This attribute will be false whenever one of the library's roots can't be reached
 		healthy: Boolean!
 
+		# Patterns in .olarisignore (gitignore) syntax of paths in the library that are not scanned
+		excludePatterns: [String!]!
+
+		# File extensions that are scanned, e.g. ".mkv". Empty if the default ones are.
+		extensions: [String!]!
+
+		# Files smaller than this many bytes are not scanned, 0 means the default of 5MB
+		minFileSize: Int!
+
+		settings: LibrarySettings!
+
 		movies: [Movie]!
 		episodes: [Episode]!
 	}
 
+	type LibraryRoot {
+		id: Int!
+
+		# Backend of the directory (0 - Local filesystem, 1 - Rclone)
+		backend: Int!
+
+		# If Backend is Rclone it will return the name of the remote
+		rcloneName: String
+
+		filePath: String!
+
+		# False if the directory couldn't be accessed during the last scan
+		healthy: Boolean!
+	}
+
+	type LibrarySettings {
+		# ISO 639-1 code of the language metadata is retrieved in, empty for the agent's default
+		metadataLanguage: String!
+
+		# ISO 3166-1 code of the country whose release information is preferred, empty for the
+		# agent's default
+		metadataRegion: String!
+
+		# Metadata agent new files are identified with, "tmdb" or "none" to only identify them by hand
+		agent: String!
+
+		# How often the library is rescanned automatically, 0 for never
+		rescanIntervalMinutes: Int!
+
+		# Rclone VFS cache mode: off, minimal, writes or full. Changes take effect after a restart
+		# if the remote is already in use.
+		vfsCacheMode: String!
+
+		# Size the rclone VFS cache is kept below, -1 for unlimited
+		vfsCacheMaxSizeMB: Int!
+
+		# Size of the chunks files are requested from the rclone remote in
+		vfsChunkSizeMB: Int!
+
+		# How many files are probed at the same time
+		probeConcurrency: Int!
+	}
+
+	type LibrarySettingsResponse {
+		settings: LibrarySettings
+		error: Error
+	}
+
 	type Series {
 		name: String!
 		overview: String!
@@ -198,6 +651,9 @@ var SchemaTxt = `
 		name: String!
 		overview: String!
 		stillPath: String!
+		# Frame grabbed from one of the files, served by the "local" image provider.
+		# Use as fallback if stillPath is empty.
+		frameGrabPath: String!
 		airDate: String!
 		episodeNumber: Int!
 		tmdbID: Int!
@@ -220,6 +676,10 @@ var SchemaTxt = `
 		fileSize: Int!
 		# Get the library for the given file
 		library: Library!
+		# Frame grabbed from the file, served by the "local" image provider.
+		frameGrabPath: String!
+		# Pre-transcoded versions of this file.
+		optimizedVersions: [OptimizedVersion]!
 	}
 
 	type Stream {
@@ -265,6 +725,9 @@ var SchemaTxt = `
 		backdropPath: String!
 		# ID to retrieve poster
 		posterPath: String!
+		# Frame grabbed from one of the files, served by the "local" image provider.
+		# Use as fallback if posterPath or backdropPath are empty.
+		frameGrabPath: String!
 		uuid: String!
 		files: [MovieFile]!
 		playState: PlayState
@@ -285,6 +748,10 @@ var SchemaTxt = `
 		fileSize: Int!
 		# Get the library for the given file
 		library: Library!
+		# Frame grabbed from the file, served by the "local" image provider.
+		frameGrabPath: String!
+		# Pre-transcoded versions of this file.
+		optimizedVersions: [OptimizedVersion]!
 	}
 
 	input UpdateMovieFileMetadataInput {
@@ -225,6 +225,16 @@ func (r *EpisodeResolver) StillPath() string {
 	return r.r.StillPath
 }
 
+// FrameGrabPath returns the frame grabbed from the first file of this episode that has one.
+func (r *EpisodeResolver) FrameGrabPath() string {
+	for _, file := range r.r.EpisodeFiles {
+		if file.FrameGrabPath != "" {
+			return file.FrameGrabPath
+		}
+	}
+	return ""
+}
+
 // TmdbID returns tmdb id.
 func (r *EpisodeResolver) TmdbID() int32 {
 	return int32(r.r.TmdbID)
@@ -279,6 +289,19 @@ func (r *EpisodeFileResolver) FilePath() (string, error) {
 	return fileLocator.Path, nil
 }
 
+// FrameGrabPath returns the frame grabbed from this file.
+func (r *EpisodeFileResolver) FrameGrabPath() string {
+	return r.r.FrameGrabPath
+}
+
+// OptimizedVersions returns the pre-transcoded versions of this file.
+func (r *EpisodeFileResolver) OptimizedVersions() (versions []*OptimizedVersionResolver) {
+	for _, v := range db.FindOptimizedVersionsForFile(r.r) {
+		versions = append(versions, &OptimizedVersionResolver{r: v})
+	}
+	return versions
+}
+
 // FileName returns filename.
 func (r *EpisodeFileResolver) FileName() string {
 	return r.r.FileName
@@ -0,0 +1,170 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+// How long share links are valid if no validity is given.
+const defaultShareLinkValidity = 48 * time.Hour
+
+// ShareLinkResolver resolves a share link.
+type ShareLinkResolver struct {
+	r db.ShareLink
+}
+
+// Token returns the token that grants access.
+func (r *ShareLinkResolver) Token() string {
+	return r.r.Token
+}
+
+// Path returns the path of the public landing endpoint of the link.
+func (r *ShareLinkResolver) Path() string {
+	return helpers.MetadataPath("v1", "share", r.r.Token)
+}
+
+// MediaUUID returns the UUID of the shared movie or episode.
+func (r *ShareLinkResolver) MediaUUID() string {
+	return r.r.MediaUUID
+}
+
+// CreatedBy returns the user that created the link.
+func (r *ShareLinkResolver) CreatedBy() *UserResolver {
+	user, err := db.FindUser(r.r.UserID)
+	if err != nil {
+		return nil
+	}
+	return &UserResolver{*user}
+}
+
+// ExpiresAt returns when the link expires, in RFC 3339 format.
+func (r *ShareLinkResolver) ExpiresAt() string {
+	return r.r.ExpiresAt.Format(time.RFC3339)
+}
+
+// MaxPlays returns how often the link can be opened, 0 if there is no limit.
+func (r *ShareLinkResolver) MaxPlays() int32 {
+	return int32(r.r.MaxPlays)
+}
+
+// Plays returns how often the link was opened.
+func (r *ShareLinkResolver) Plays() int32 {
+	return int32(r.r.Plays)
+}
+
+// Revoked returns whether the link was revoked.
+func (r *ShareLinkResolver) Revoked() bool {
+	return r.r.Revoked
+}
+
+// ShareLinkResponse is returned by share link mutations.
+type ShareLinkResponse struct {
+	Error     *ErrorResolver
+	ShareLink *ShareLinkResolver
+}
+
+// ShareLinkResponseResolver resolves ShareLinkResponse.
+type ShareLinkResponseResolver struct {
+	r ShareLinkResponse
+}
+
+// Error returns error.
+func (r *ShareLinkResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// ShareLink returns the share link.
+func (r *ShareLinkResponseResolver) ShareLink() *ShareLinkResolver {
+	return r.r.ShareLink
+}
+
+func shareLinkErrResponse(err error) *ShareLinkResponseResolver {
+	return &ShareLinkResponseResolver{ShareLinkResponse{Error: CreateErrResolver(err)}}
+}
+
+// ShareLinks returns all share links for admins and the user's own links otherwise.
+func (r *Resolver) ShareLinks(ctx context.Context) (links []*ShareLinkResolver) {
+	userID, _ := auth.UserID(ctx)
+	if ifAdmin(ctx) == nil {
+		userID = 0
+	}
+	for _, link := range db.FindShareLinks(userID) {
+		links = append(links, &ShareLinkResolver{r: link})
+	}
+	return links
+}
+
+// CreateShareLink creates a link through which the given movie or episode can be streamed
+// without an account.
+func (r *Resolver) CreateShareLink(ctx context.Context, args struct {
+	MediaUUID     string
+	ValidForHours *int32
+	MaxPlays      *int32
+}) *ShareLinkResponseResolver {
+	userID, _ := auth.UserID(ctx)
+	if ifAdmin(ctx) != nil {
+		user, err := db.FindUser(userID)
+		if err != nil || !user.CanShare {
+			return shareLinkErrResponse(CreateNoAuthorisationError())
+		}
+	}
+
+	if _, err := db.FindMovieByUUID(args.MediaUUID); err != nil {
+		if _, err := db.FindEpisodeByUUID(args.MediaUUID); err != nil {
+			return shareLinkErrResponse(fmt.Errorf("no movie or episode with UUID %s", args.MediaUUID))
+		}
+	}
+
+	validFor := defaultShareLinkValidity
+	if args.ValidForHours != nil {
+		if *args.ValidForHours <= 0 {
+			return shareLinkErrResponse(fmt.Errorf("validForHours must be positive"))
+		}
+		validFor = time.Duration(*args.ValidForHours) * time.Hour
+	}
+	maxPlays := 0
+	if args.MaxPlays != nil {
+		if *args.MaxPlays < 0 {
+			return shareLinkErrResponse(fmt.Errorf("maxPlays must not be negative"))
+		}
+		maxPlays = int(*args.MaxPlays)
+	}
+
+	token, err := helpers.SecureRandAlphaString(32)
+	if err != nil {
+		return shareLinkErrResponse(err)
+	}
+	link := db.ShareLink{
+		Token:     token,
+		MediaUUID: args.MediaUUID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(validFor),
+		MaxPlays:  maxPlays,
+	}
+	if err := db.CreateShareLink(&link); err != nil {
+		return shareLinkErrResponse(err)
+	}
+	return &ShareLinkResponseResolver{ShareLinkResponse{ShareLink: &ShareLinkResolver{r: link}}}
+}
+
+// RevokeShareLink revokes a share link, which also stops streams through it. Admins can revoke
+// any link, users only their own.
+func (r *Resolver) RevokeShareLink(ctx context.Context, args struct{ Token string }) *ShareLinkResponseResolver {
+	link, err := db.FindShareLinkByToken(args.Token)
+	if err != nil {
+		return shareLinkErrResponse(err)
+	}
+	if err := ifAdminOrUser(ctx, link.UserID); err != nil {
+		return shareLinkErrResponse(err)
+	}
+
+	if err := db.RevokeShareLink(args.Token); err != nil {
+		return shareLinkErrResponse(err)
+	}
+	link.Revoked = true
+	return &ShareLinkResponseResolver{ShareLinkResponse{ShareLink: &ShareLinkResolver{r: *link}}}
+}
@@ -0,0 +1,107 @@
+package resolvers
+
+import (
+	"context"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+// StreamingTicketResolver resolves a streaming ticket handed out to a user.
+type StreamingTicketResolver struct {
+	r db.StreamingTicket
+}
+
+// JTI returns the unique ID of the ticket.
+func (r *StreamingTicketResolver) JTI() string {
+	return r.r.JTI
+}
+
+// FilePath returns the file the ticket grants access to.
+func (r *StreamingTicketResolver) FilePath() string {
+	return r.r.FilePath
+}
+
+// CreatedAt returns when the ticket was issued, in RFC 3339 format.
+func (r *StreamingTicketResolver) CreatedAt() string {
+	return r.r.CreatedAt.Format(time.RFC3339)
+}
+
+// ExpiresAt returns when the ticket expires, in RFC 3339 format.
+func (r *StreamingTicketResolver) ExpiresAt() string {
+	return r.r.ExpiresAt.Format(time.RFC3339)
+}
+
+// Revoked returns whether the ticket was revoked.
+func (r *StreamingTicketResolver) Revoked() bool {
+	return r.r.Revoked
+}
+
+// ifAdminOrUser returns an error unless the request is made by an admin or the given user.
+func ifAdminOrUser(ctx context.Context, userID uint) error {
+	if currentUserID, ok := auth.UserID(ctx); ok && currentUserID == userID {
+		return nil
+	}
+	return ifAdmin(ctx)
+}
+
+// StreamingTickets returns the unexpired streaming tickets of the given user.
+func (r *Resolver) StreamingTickets(ctx context.Context, args struct{ UserID int32 }) (tickets []*StreamingTicketResolver) {
+	if err := ifAdminOrUser(ctx, uint(args.UserID)); err != nil {
+		return tickets
+	}
+
+	for _, ticket := range db.FindStreamingTicketsForUser(uint(args.UserID)) {
+		tickets = append(tickets, &StreamingTicketResolver{r: ticket})
+	}
+	return tickets
+}
+
+// RevokeStreamingTicketsResponse is returned when revoking streaming tickets.
+type RevokeStreamingTicketsResponse struct {
+	Error   *ErrorResolver
+	Success bool
+}
+
+// RevokeStreamingTicketsResponseResolver resolves RevokeStreamingTicketsResponse.
+type RevokeStreamingTicketsResponseResolver struct {
+	r RevokeStreamingTicketsResponse
+}
+
+// Error returns error.
+func (r *RevokeStreamingTicketsResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Success returns whether the tickets were revoked.
+func (r *RevokeStreamingTicketsResponseResolver) Success() bool {
+	return r.r.Success
+}
+
+// RevokeStreamingTicket revokes a single streaming ticket.
+func (r *Resolver) RevokeStreamingTicket(ctx context.Context, args struct{ JTI string }) *RevokeStreamingTicketsResponseResolver {
+	ticket, err := db.FindStreamingTicketByJTI(args.JTI)
+	if err != nil {
+		return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Error: CreateErrResolver(err)}}
+	}
+	if err := ifAdminOrUser(ctx, ticket.UserID); err != nil {
+		return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Error: CreateErrResolver(err)}}
+	}
+
+	if err := db.RevokeStreamingTicket(args.JTI); err != nil {
+		return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Error: CreateErrResolver(err)}}
+	}
+	return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Success: true}}
+}
+
+// RevokeStreamingTickets revokes all streaming tickets of the given user.
+func (r *Resolver) RevokeStreamingTickets(ctx context.Context, args struct{ UserID int32 }) *RevokeStreamingTicketsResponseResolver {
+	if err := ifAdminOrUser(ctx, uint(args.UserID)); err != nil {
+		return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Error: CreateErrResolver(err)}}
+	}
+
+	if err := db.RevokeStreamingTicketsForUser(uint(args.UserID)); err != nil {
+		return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Error: CreateErrResolver(err)}}
+	}
+	return &RevokeStreamingTicketsResponseResolver{RevokeStreamingTicketsResponse{Success: true}}
+}
@@ -25,6 +25,21 @@ func (r *UserResolver) Admin() bool {
 	return r.r.Admin
 }
 
+// MaxLocalBitrate returns the maximum streaming bitrate for clients in the local network.
+func (r *UserResolver) MaxLocalBitrate() int32 {
+	return int32(r.r.MaxLocalBitrate)
+}
+
+// MaxRemoteBitrate returns the maximum streaming bitrate for remote clients.
+func (r *UserResolver) MaxRemoteBitrate() int32 {
+	return int32(r.r.MaxRemoteBitrate)
+}
+
+// CanShare returns whether the user may create share links.
+func (r *UserResolver) CanShare() bool {
+	return r.r.CanShare
+}
+
 // UserResponse holds user information and error if needed.
 type UserResponse struct {
 	Error *ErrorResolver
@@ -73,3 +88,39 @@ func (r *Resolver) DeleteUser(ctx context.Context, args struct{ ID int32 }) *Use
 	return &UserResponseResolver{&UserResponse{User: &UserResolver{user}}}
 
 }
+
+// UpdateUserBitrateLimits sets the maximum streaming bitrates of the given user.
+func (r *Resolver) UpdateUserBitrateLimits(ctx context.Context, args struct {
+	ID               int32
+	MaxLocalBitrate  int32
+	MaxRemoteBitrate int32
+}) *UserResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	user, err := db.UpdateUserBitrateLimits(
+		uint(args.ID), int(args.MaxLocalBitrate), int(args.MaxRemoteBitrate))
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
+
+// UpdateUserCanShare sets whether the given user may create share links.
+func (r *Resolver) UpdateUserCanShare(ctx context.Context, args struct {
+	ID       int32
+	CanShare bool
+}) *UserResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	user, err := db.UpdateUserCanShare(uint(args.ID), args.CanShare)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
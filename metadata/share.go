@@ -0,0 +1,111 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"net/http"
+	"path"
+	"time"
+)
+
+// shareLinkResponse tells the holder of a share link what they can watch and how.
+type shareLinkResponse struct {
+	MediaUUID         string `json:"mediaUUID"`
+	Title             string `json:"title"`
+	JWT               string `json:"jwt"`
+	MetadataPath      string `json:"metadataPath"`
+	HLSStreamingPath  string `json:"hlsStreamingPath"`
+	DASHStreamingPath string `json:"dashStreamingPath"`
+	ExpiresAt         string `json:"expiresAt"`
+	// PlaysLeft is -1 if the number of plays is not limited.
+	PlaysLeft int `json:"playsLeft"`
+}
+
+type shareLinkError struct {
+	HasError bool   `json:"has_error"`
+	Message  string `json:"message"`
+}
+
+func writeShareLinkError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(shareLinkError{true, message})
+}
+
+// findSharedMedia returns the title and the file to stream of the movie or episode with the given
+// UUID.
+func findSharedMedia(mediaUUID string) (string, string, error) {
+	if movie, err := db.FindMovieByUUID(mediaUUID); err == nil {
+		if len(movie.MovieFiles) == 0 {
+			return "", "", fmt.Errorf("no file for movie %s", movie.Title)
+		}
+		return movie.Title, movie.MovieFiles[0].FilePath, nil
+	}
+	if episode, err := db.FindEpisodeByUUID(mediaUUID); err == nil {
+		if len(episode.EpisodeFiles) == 0 {
+			return "", "", fmt.Errorf("no file for episode %s", episode.Name)
+		}
+		return episode.Name, episode.EpisodeFiles[0].FilePath, nil
+	}
+	return "", "", fmt.Errorf("no movie or episode with UUID %s", mediaUUID)
+}
+
+// ShareLinkHandler is the public landing endpoint of share links. It counts a play of the link
+// and hands out a streaming ticket restricted to the shared media item. No login is required.
+func ShareLinkHandler(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	link, err := db.FindShareLinkByToken(token)
+	if err != nil {
+		writeShareLinkError(w, "Unknown share link", http.StatusNotFound)
+		return
+	}
+	title, filePath, err := findSharedMedia(link.MediaUUID)
+	if err != nil {
+		writeShareLinkError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	link, err = db.UseShareLink(token)
+	if err != nil {
+		writeShareLinkError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Every visit gets its own guest ID, so that guests don't share their playback sessions.
+	jwt, err := auth.CreateShareStreamingJWT(link, uuid.New().String(), filePath)
+	if err != nil {
+		writeShareLinkError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	basePath := helpers.StreamingPath("files", "jwt", jwt) + "/"
+	sessionID := helpers.RandAlphaString(16)
+
+	playsLeft := -1
+	if link.MaxPlays != 0 {
+		playsLeft = link.MaxPlays - link.Plays
+	}
+
+	log.WithFields(log.Fields{"mediaUUID": link.MediaUUID, "plays": link.Plays}).Infoln("Share link opened")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shareLinkResponse{
+		MediaUUID:    link.MediaUUID,
+		Title:        title,
+		JWT:          jwt,
+		MetadataPath: path.Join(basePath, "metadata.json"),
+		HLSStreamingPath: path.Join(
+			basePath, fmt.Sprintf("/session:%s/hls-manifest.m3u8", sessionID)),
+		DASHStreamingPath: path.Join(
+			basePath, fmt.Sprintf("/session:%s/dash-manifest.mpd", sessionID)),
+		ExpiresAt: link.ExpiresAt.Format(time.RFC3339),
+		PlaysLeft: playsLeft,
+	})
+}
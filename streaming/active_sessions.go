@@ -0,0 +1,144 @@
+package streaming
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ActiveSession is a snapshot of a PlaybackSession for the "now playing" dashboard. Its UserID is 0
+// for guests watching through a share link.
+type ActiveSession struct {
+	PlaybackSessionID string
+	SessionID         string
+	UserID            uint
+	FileLocator       string
+	StreamType        string
+	RepresentationID  string
+	// Transcoded is set if the stream is transcoded rather than transmuxed.
+	Transcoded bool
+	// ProgressPercent of ffmpeg through the stream
+	ProgressPercent float32
+	Throttled       bool
+	ClientIP        string
+	// Bandwidth is the average rate the session's segments were served at in bits per second.
+	Bandwidth    int64
+	LastAccessed time.Time
+}
+
+type stoppedSessionKey struct {
+	sessionID string
+	userID    uint
+	guestID   string
+}
+
+type stoppedSession struct {
+	reason    string
+	stoppedAt time.Time
+}
+
+// Sessions stopped by an admin. Guarded by sessionsMutex.
+var stoppedSessions = map[stoppedSessionKey]stoppedSession{}
+
+// ActiveSessions returns a snapshot of all playback sessions.
+func ActiveSessions() []ActiveSession {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	sessions := []ActiveSession{}
+	for _, s := range playbackSessions {
+		var bandwidth int64
+		if elapsed := time.Since(s.firstServed).Seconds(); !s.firstServed.IsZero() && elapsed > 0 {
+			bandwidth = int64(float64(s.bytesServed*8) / elapsed)
+		}
+
+		sessions = append(sessions, ActiveSession{
+			PlaybackSessionID: s.playbackSessionID,
+			SessionID:         s.sessionID,
+			UserID:            s.userID,
+			FileLocator:       s.FileLocator.String(),
+			StreamType:        s.TranscodingSession.Stream.Stream.StreamType,
+			RepresentationID:  s.representationID,
+			Transcoded:        s.TranscodingSession.Stream.Representation.Transcoded,
+			ProgressPercent:   s.TranscodingSession.ProgressPercent,
+			Throttled:         s.TranscodingSession.Throttled,
+			ClientIP:          s.clientIP,
+			Bandwidth:         bandwidth,
+			LastAccessed:      s.lastAccessed,
+		})
+	}
+	return sessions
+}
+
+// StopSession stops the client session the given playback session belongs to, including its
+// other streams. Further segment requests of that client session are rejected with the reason.
+// Since clients pick their session IDs, the streaming tickets the session used are revoked too so
+// that it can't just carry on under another ID. A client that is still logged in can still ask
+// for new tickets and start playback over though.
+func StopSession(playbackSessionID string, reason string) error {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	var stopped *PlaybackSession
+	for _, s := range playbackSessions {
+		if s.playbackSessionID == playbackSessionID {
+			stopped = s
+			break
+		}
+	}
+	if stopped == nil {
+		return fmt.Errorf("No PlaybackSession with the given ID %s", playbackSessionID)
+	}
+
+	// Forget about sessions stopped long ago, their client has given up by now.
+	for k, s := range stoppedSessions {
+		if time.Since(s.stoppedAt) > playbackSessionTimeout {
+			delete(stoppedSessions, k)
+		}
+	}
+	stoppedSessions[stoppedSessionKey{stopped.sessionID, stopped.userID, stopped.guestID}] = stoppedSession{
+		reason:    reason,
+		stoppedAt: time.Now(),
+	}
+
+	for _, s := range playbackSessions {
+		if s.sessionID == stopped.sessionID && s.userID == stopped.userID && s.guestID == stopped.guestID {
+			if s.ticketID != "" {
+				if err := db.RevokeStreamingTicket(s.ticketID); err != nil {
+					log.WithFields(log.Fields{"error": err}).Warnln("Failed to revoke streaming ticket")
+				}
+			}
+			removePlaybackSessionLocked(s)
+		}
+	}
+
+	log.WithFields(log.Fields{"sessionID": stopped.sessionID, "user": stopped.userID, "reason": reason}).
+		Infoln("Playback session stopped")
+	return nil
+}
+
+// stoppedSessionReason returns why the given client session was stopped, if it was.
+func stoppedSessionReason(sessionID string, userID uint, guestID string) (string, bool) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	s, stopped := stoppedSessions[stoppedSessionKey{sessionID, userID, guestID}]
+	return s.reason, stopped
+}
+
+// recordServed updates the session's statistics after the given segment was served.
+func (s *PlaybackSession) recordServed(r *http.Request, segmentPath string) {
+	s.clientIP = getClientIP(r)
+	if claims, ok := streamingClaimsFromRequest(r); ok {
+		s.ticketID = claims.Id
+	}
+	if s.firstServed.IsZero() {
+		s.firstServed = time.Now()
+	}
+	if stat, err := os.Stat(segmentPath); err == nil {
+		s.bytesServed += stat.Size()
+	}
+}
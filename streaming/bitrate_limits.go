@@ -0,0 +1,112 @@
+package streaming
+
+import (
+	"errors"
+	"flag"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"net/http"
+)
+
+var maxLocalBitrateFlag = flag.Int(
+	"max_local_streaming_bitrate",
+	0,
+	"Maximum video bitrate in bits per second streamed to clients in the local network. 0 means no limit.")
+
+var maxRemoteBitrateFlag = flag.Int(
+	"max_remote_streaming_bitrate",
+	0,
+	"Maximum video bitrate in bits per second streamed to remote clients. 0 means no limit.")
+
+var localNetworksFlag = flag.String(
+	"local_networks",
+	"127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,::1/128,fc00::/7,fe80::/10",
+	"Comma-separated list of CIDRs whose clients are considered to be in the local network")
+
+// isLocalIP returns whether the given IP address is in one of the local networks.
+func isLocalIP(ipStr string) bool {
+	return ipInNetworks(ipStr, *localNetworksFlag)
+}
+
+// ErrBitrateLimitExceeded is returned when a client asks for a video representation that exceeds
+// its bitrate limit. Manifests only offer representations within the limit, but clients can make
+// up the URLs of others.
+var ErrBitrateLimitExceeded = errors.New("Representation exceeds the bitrate limit")
+
+// minBitrateLimit returns the stricter of two limits where 0 means no limit.
+func minBitrateLimit(a int, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// getMaxBitrate returns the maximum video bitrate for the request's user and network, 0 if there
+// is no limit.
+func getMaxBitrate(r *http.Request) int {
+	local := isLocalIP(getClientIP(r))
+
+	maxBitrate := *maxRemoteBitrateFlag
+	if local {
+		maxBitrate = *maxLocalBitrateFlag
+	}
+
+	claims, ok := streamingClaimsFromRequest(r)
+	if !ok {
+		return maxBitrate
+	}
+	user, err := db.FindUser(claims.UserID)
+	if err != nil {
+		return maxBitrate
+	}
+	if local {
+		return minBitrateLimit(maxBitrate, user.MaxLocalBitrate)
+	}
+	return minBitrateLimit(maxBitrate, user.MaxRemoteBitrate)
+}
+
+// exceedsBitrateLimit returns whether the given representation is a video representation with a
+// bitrate above maxBitrate.
+func exceedsBitrateLimit(r ffmpeg.StreamRepresentation, maxBitrate int) bool {
+	return maxBitrate != 0 &&
+		r.Stream.StreamType == "video" &&
+		r.Representation.BitRate > maxBitrate
+}
+
+// limitVideoRepresentation replaces the given representation with a transcoded one if it exceeds
+// maxBitrate.
+func limitVideoRepresentation(
+	r ffmpeg.StreamRepresentation,
+	maxBitrate int) (ffmpeg.StreamRepresentation, error) {
+
+	if maxBitrate == 0 || r.Representation.BitRate <= maxBitrate {
+		return r, nil
+	}
+	return ffmpeg.GetCappedTranscodedVideoRepresentation(r.Stream, maxBitrate)
+}
+
+// limitVideoRepresentations drops representations that exceed maxBitrate. If none are left, the
+// best representation that doesn't is transcoded instead.
+func limitVideoRepresentations(
+	representations []ffmpeg.StreamRepresentation,
+	maxBitrate int) ([]ffmpeg.StreamRepresentation, error) {
+
+	if maxBitrate == 0 || len(representations) == 0 {
+		return representations, nil
+	}
+
+	res := []ffmpeg.StreamRepresentation{}
+	for _, r := range representations {
+		if r.Representation.BitRate <= maxBitrate {
+			res = append(res, r)
+		}
+	}
+	if len(res) == 0 {
+		r, err := limitVideoRepresentation(representations[0], maxBitrate)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
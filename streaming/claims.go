@@ -0,0 +1,38 @@
+package streaming
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"net/http"
+)
+
+type contextKey string
+
+var contextKeyStreamingClaims = contextKey("streaming_claims")
+
+// streamingClaimsMiddleware validates the streaming JWT in the request's file locator once and
+// makes its claims available to the handler through the request context. Requests without a JWT
+// are passed on as they are, the handlers decide whether they can do without.
+func streamingClaimsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := getStreamingClaims(mux.Vars(r)["fileLocator"])
+		if err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), contextKeyStreamingClaims, claims))
+		}
+		next(w, r)
+	}
+}
+
+// streamingClaimsFromRequest returns the claims of the request's streaming JWT, as put into the
+// request context by streamingClaimsMiddleware.
+func streamingClaimsFromRequest(r *http.Request) (*auth.StreamingClaims, bool) {
+	claims, ok := r.Context().Value(contextKeyStreamingClaims).(*auth.StreamingClaims)
+	return claims, ok
+}
+
+// streamingClaimsOrNil returns the claims of the request's streaming JWT, nil if there is none.
+func streamingClaimsOrNil(r *http.Request) *auth.StreamingClaims {
+	claims, _ := streamingClaimsFromRequest(r)
+	return claims
+}
@@ -0,0 +1,67 @@
+package streaming
+
+import (
+	"flag"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var trustedProxiesFlag = flag.String(
+	"trusted_proxies",
+	"127.0.0.0/8,::1/128",
+	"Comma-separated list of CIDRs of reverse proxies whose X-Forwarded-For header is trusted")
+
+// ipInNetworks returns whether the given IP address is in one of the given comma-separated CIDRs.
+func ipInNetworks(ipStr string, cidrs string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range strings.Split(cidrs, ",") {
+		if strings.TrimSpace(cidr) == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			log.WithFields(log.Fields{"cidr": cidr, "error": err}).Warnln("Invalid network")
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getClientIP returns the IP address of the client. If the request came through trusted reverse
+// proxies, that is the rightmost address in X-Forwarded-For that isn't one of them. Everything to
+// the left of it may have been made up by the client.
+func getClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !ipInNetworks(ip, *trustedProxiesFlag) {
+		return ip
+	}
+
+	var hops []string
+	for _, forwardedFor := range r.Header["X-Forwarded-For"] {
+		hops = append(hops, strings.Split(forwardedFor, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Nothing left of a hop we can't make sense of can be trusted either.
+			break
+		}
+		ip = hop
+		if !ipInNetworks(ip, *trustedProxiesFlag) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,37 @@
+package streaming
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// serveClip serves a finished clip to anyone with a link to it. Unlike downloads, clips are meant
+// to be shared, so the file is served inline for browsers to play it.
+func serveClip(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ValidateClipJWT(mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	c, err := db.FindClipByUUID(claims.ClipUUID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if c.State != db.JobStateDone || time.Now().After(c.ExpiresAt) {
+		http.Error(w, "Clip is not available", http.StatusNotFound)
+		return
+	}
+
+	fileName := strings.TrimSuffix(c.FileName, path.Ext(c.FileName)) + ".mp4"
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName))
+	http.ServeFile(w, r, c.FilePath)
+}
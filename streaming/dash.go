@@ -29,10 +29,20 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 
 	videoStream := dash.StreamRepresentations{Stream: streams.GetVideoStream()}
 	// Get transmuxed or similar transcoded representation
-	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	fullQualityRepresentation, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	if err == nil {
+		// Transcode down to the user's bitrate limit if needed, the lower qualities follow from that.
+		fullQualityRepresentation, err = limitVideoRepresentation(fullQualityRepresentation, getMaxBitrate(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	videoStream.Representations = append(videoStream.Representations, fullQualityRepresentation)
 
-	lowQualityRepresentations := ffmpeg.GetStandardPresetVideoRepresentations(streams.GetVideoStream())
+	lowQualityRepresentations := preferOptimizedRepresentations(
+		ffmpeg.GetStandardPresetVideoRepresentations(streams.GetVideoStream()),
+		getOptimizedVideoRepresentations(streams.GetVideoStream()))
 	for _, r := range lowQualityRepresentations {
 		if r.Representation.BitRate < fullQualityRepresentation.Representation.BitRate {
 			videoStream.Representations = append(videoStream.Representations, r)
@@ -56,9 +66,8 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 	subtitleStreams := []dash.SubtitleStreamRepresentation{}
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
 	for _, s := range subtitleRepresentations {
-		// NOTE(Leon Handreke): Because we'd have to propagate the UserID here through
-		// context or something like that and it's not used anyway, just use 0 here.
-		jwt, err := auth.CreateStreamingJWT(0, fileLocator.String())
+		// The ticket is handed to the client, so it needs the same user and restrictions as the request.
+		jwt, err := auth.CreateDerivedStreamingJWT(streamingClaimsOrNil(r), fileLocator.String())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -50,6 +50,25 @@ const transcodingSessionsDebugPageTemplate = `
 			{{ end }}
 			</tbody>
 		</table>
+		<table style="border: 1px solid black;">
+			<caption>Transcoding Workers</caption>
+			<thead><tr>
+				<th>Name</th>
+				<th>URL</th>
+				<th>Last Seen</th>
+				<th>Jobs</th>
+			</tr></thead>
+			<tbody>
+			{{ range .workers }}
+				<tr>
+					<td>{{ .Name }}</td>
+					<td>{{ .URL }}</td>
+					<td>{{ .LastSeen }}</td>
+					<td>{{ .Jobs }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
 	</body>
 </html>
 `
@@ -68,6 +87,7 @@ func servePlaybackSessionDebugPage(w http.ResponseWriter, r *http.Request) {
 
 	templateData := map[string]interface{}{
 		"sessions": playbackSessions,
+		"workers":  ffmpeg.GetTranscodingWorkers(),
 	}
 
 	t := template.Must(template.New("manifest").Parse(transcodingSessionsDebugPageTemplate))
@@ -0,0 +1,37 @@
+package streaming
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// serveDownload serves a finished download to the user it was created for. The file name in the
+// URL is only there so that browsers pick a sensible name when saving the file.
+func serveDownload(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ValidateDownloadJWT(mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	d, err := db.FindDownloadByUUID(claims.DownloadUUID)
+	if err != nil || d.UserID != claims.UserID {
+		http.NotFound(w, r)
+		return
+	}
+	if d.State != db.JobStateDone || time.Now().After(d.ExpiresAt) {
+		http.Error(w, "Download is not available", http.StatusNotFound)
+		return
+	}
+
+	fileName := strings.TrimSuffix(d.FileName, path.Ext(d.FileName)) + ".mp4"
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	http.ServeFile(w, r, d.FilePath)
+}
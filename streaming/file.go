@@ -25,6 +25,9 @@ func serveFile(w http.ResponseWriter, r *http.Request) {
 	} else if node.BackendType() == filesystem.BackendRclone {
 		serveRcloneFile(w, r, node)
 		return
+	} else if node.BackendType() == filesystem.BackendHTTP {
+		serveHTTPFile(w, r, node)
+		return
 	}
 
 	http.NotFound(w, r)
@@ -3,24 +3,32 @@ package streaming
 import (
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
 )
 
 // RegisterRoutes registers streaming routes to an existing router
 func RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-transmuxing-manifest.m3u8", serveHlsTransmuxingMasterPlaylist)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-transcoding-manifest.m3u8", serveHlsTranscodingMasterPlaylist)
-	router.HandleFunc("/files/{fileLocator:.*}/metadata.json", serveMetadata)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-manifest.m3u8", serveHlsMasterPlaylist)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/dash-manifest.mpd", serveDASHManifest)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/media.m3u8", serveHlsTranscodingMediaPlaylist)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.m4s", serveMediaSegment)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.vtt", serveSubtitleSegment)
-	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/init.mp4", serveInit)
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-transmuxing-manifest.m3u8", streamingClaimsMiddleware(serveHlsTransmuxingMasterPlaylist))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-transcoding-manifest.m3u8", streamingClaimsMiddleware(serveHlsTranscodingMasterPlaylist))
+	router.HandleFunc("/files/{fileLocator:.*}/metadata.json", streamingClaimsMiddleware(serveMetadata))
+	router.HandleFunc("/files/{fileLocator:.*}/screenshot.jpg", streamingClaimsMiddleware(serveScreenshot))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/hls-manifest.m3u8", streamingClaimsMiddleware(serveHlsMasterPlaylist))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/dash-manifest.mpd", streamingClaimsMiddleware(serveDASHManifest))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/media.m3u8", streamingClaimsMiddleware(serveHlsTranscodingMediaPlaylist))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.m4s", streamingClaimsMiddleware(serveMediaSegment))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.vtt", streamingClaimsMiddleware(serveSubtitleSegment))
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/init.mp4", streamingClaimsMiddleware(serveInit))
 	router.HandleFunc("/ffmpeg/{playbackSessionID}/feedback", serveFFmpegFeedback)
+	ffmpeg.HandleInternal("/olaris/s/ffmpeg/{playbackSessionID}/feedback", serveFFmpegFeedback)
+	router.HandleFunc("/downloads/{token}/{fileName}", serveDownload)
+	router.HandleFunc("/clips/{token}/{fileName}", serveClip)
+	router.HandleFunc("/workers/register", workerAuthMiddleware(serveWorkerRegistration)).Methods("POST")
+	router.HandleFunc("/workers/jobs/{jobID}/done", workerAuthMiddleware(serveWorkerJobDone)).Methods("POST")
+	router.HandleFunc("/workers/jobs/{jobID}/{fileName}", workerAuthMiddleware(serveWorkerSegmentUpload)).Methods("PUT")
 
 	// This handler just serves up the file for downloading. This is also used
 	// internally by ffmpeg to access rclone files.
-	router.HandleFunc("/files/{fileLocator:.*}", serveFile)
+	router.HandleFunc("/files/{fileLocator:.*}", streamingClaimsMiddleware(serveFile))
 
 	router.HandleFunc("/debug/playbackSessions", servePlaybackSessionDebugPage)
 
@@ -37,5 +45,9 @@ func Cleanup() {
 			log.Warn("Playback session reference count leak: ", s.TranscodingSession)
 		}
 	}
+	for _, s := range prefetchedSessions {
+		s.referenceCount--
+		s.CleanupIfRequired()
+	}
 	log.Println("Cleaned up all streaming context")
 }
@@ -29,20 +29,34 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get transmuxed or similar transcoded representation
-	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	fullQualityRepresentation, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	if err == nil {
+		// Transcode down to the user's bitrate limit if needed, the lower qualities follow from that.
+		fullQualityRepresentation, err = limitVideoRepresentation(fullQualityRepresentation, getMaxBitrate(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	videoRepresentations := []ffmpeg.StreamRepresentation{fullQualityRepresentation}
 
-	// TODO(Leon Handreke): I've observed issues with switching from transmuxed representations to transcoded
-	// (garbled output). Therefore, serve alternative streams only for transcoded for now. See
-	// https://gitlab.com/olaris/olaris-server/issues/48
-	if fullQualityRepresentation.Representation.Transcoded {
-		// Build lower-quality transcoded versions
-		for _, preset := range []string{"preset:480-1000k-video", "preset:720-5000k-video", "preset:1080-10000k-video"} {
-			r, _ := ffmpeg.StreamRepresentationFromRepresentationId(
-				streams.GetVideoStream(), preset)
-			if r.Representation.BitRate < fullQualityRepresentation.Representation.BitRate {
-				videoRepresentations = append(videoRepresentations, r)
+	optimizedRepresentations := getOptimizedVideoRepresentations(streams.GetVideoStream())
+
+	// Build lower-quality versions, preferring optimized versions over transcoding on the fly
+	for _, preset := range ffmpeg.StandardPresets {
+		r, ok := optimizedRepresentations[preset]
+		if !ok {
+			// TODO(Leon Handreke): I've observed issues with switching from transmuxed representations to transcoded
+			// (garbled output). Therefore, serve alternative transcoded streams only for transcoded for now. See
+			// https://gitlab.com/olaris/olaris-server/issues/48
+			if !fullQualityRepresentation.Representation.Transcoded {
+				continue
 			}
+			r, _ = ffmpeg.StreamRepresentationFromRepresentationId(
+				streams.GetVideoStream(), preset)
+		}
+		if r.Representation.BitRate < fullQualityRepresentation.Representation.BitRate {
+			videoRepresentations = append(videoRepresentations, r)
 		}
 	}
 
@@ -68,7 +82,8 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(
+		subtitleRepresentations, mux.Vars(r)["sessionID"], streamingClaimsOrNil(r))
 
 	manifest := hls.BuildMasterPlaylistFromFile(combinations, subtitlePlaylistItems)
 	w.Write([]byte(manifest))
@@ -86,7 +101,12 @@ func serveHlsTransmuxingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transmuxedVideoStream := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
+	transmuxedVideoStream, err := limitVideoRepresentation(
+		ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream()), getMaxBitrate(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	audioStreamRepresentations := []ffmpeg.StreamRepresentation{}
 	for _, s := range streams.AudioStreams {
@@ -95,7 +115,8 @@ func serveHlsTransmuxingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(
+		subtitleRepresentations, mux.Vars(r)["sessionID"], streamingClaimsOrNil(r))
 
 	manifest := hls.BuildMasterPlaylistFromFile(
 		[]hls.RepresentationCombination{
@@ -127,8 +148,14 @@ func serveHlsTranscodingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 		streams.GetVideoStream(), "preset:480-1000k-video")
 	videoRepresentation2, _ := ffmpeg.StreamRepresentationFromRepresentationId(
 		streams.GetVideoStream(), "preset:720-5000k-video")
-	videoRepresentations := []ffmpeg.StreamRepresentation{
-		videoRepresentation1, videoRepresentation2}
+	videoRepresentations := preferOptimizedRepresentations(
+		[]ffmpeg.StreamRepresentation{videoRepresentation1, videoRepresentation2},
+		getOptimizedVideoRepresentations(streams.GetVideoStream()))
+	videoRepresentations, err = limitVideoRepresentations(videoRepresentations, getMaxBitrate(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	representationCombinations := []hls.RepresentationCombination{}
 
@@ -155,7 +182,8 @@ func serveHlsTranscodingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(
+		subtitleRepresentations, mux.Vars(r)["sessionID"], streamingClaimsOrNil(r))
 
 	manifest := hls.BuildMasterPlaylistFromFile(
 		representationCombinations, subtitlePlaylistItems)
@@ -176,21 +204,35 @@ func serveHlsTranscodingMediaPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	stream, err := ffmpeg.GetStream(streamKey)
-	streamRepresentation, err := ffmpeg.StreamRepresentationFromRepresentationId(
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	streamRepresentation, err := streamRepresentationFromRepresentationId(
 		stream,
 		mux.Vars(r)["representationId"])
+	if err == ffmpeg.ErrStaleRepresentationID {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	manifest := hls.BuildTranscodingMediaPlaylistFromFile(streamRepresentation)
 	w.Write([]byte(manifest))
 }
 
-func buildSubtitlePlaylistItems(representations []ffmpeg.StreamRepresentation, sessionID string) []hls.SubtitlePlaylistItem {
+func buildSubtitlePlaylistItems(
+	representations []ffmpeg.StreamRepresentation,
+	sessionID string,
+	claims *auth.StreamingClaims) []hls.SubtitlePlaylistItem {
+
 	// Subtitles may be in another file, so we need to list their absolute URI.
 	subtitlePlaylistItems := []hls.SubtitlePlaylistItem{}
 	for _, s := range representations {
-		// NOTE(Leon Handreke): Because we'd have to propagate the UserID here through
-		// context or something like that and it's not used anyway, just use 0 here.
-		jwt, _ := auth.CreateStreamingJWT(0, s.Stream.FileLocator.String())
+		// The ticket is handed to the client, so it needs the same user and restrictions as the request.
+		jwt, _ := auth.CreateDerivedStreamingJWT(claims, s.Stream.FileLocator.String())
 		subtitlePlaylistItems = append(subtitlePlaylistItems,
 			hls.SubtitlePlaylistItem{
 				StreamRepresentation: s,
@@ -0,0 +1,25 @@
+package streaming
+
+import (
+	"fmt"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"net/http"
+)
+
+// serveHTTPFile proxies a file on an HTTP(S) server, e.g. the target of a .strm file, so that
+// clients don't need to be able to reach that server themselves.
+func serveHTTPFile(w http.ResponseWriter, r *http.Request, node filesystem.Node) {
+	f, err := node.Open()
+	if err != nil {
+		http.Error(w,
+			fmt.Sprintf(
+				"Failed get file \"%s\" over HTTP: %s",
+				node.FileLocator().String(),
+				err.Error()),
+			http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	http.ServeContent(w, r, node.Name(), node.ModTime(), f)
+}
@@ -34,7 +34,11 @@ func serveMetadata(w http.ResponseWriter, r *http.Request) {
 	checkCodecs := []string{}
 
 	transmuxedVideo := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
-	transcodedVideo := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
+	transcodedVideo, err := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	checkCodecs = append(checkCodecs,
 		transmuxedVideo.Representation.Codecs,
@@ -48,7 +52,11 @@ func serveMetadata(w http.ResponseWriter, r *http.Request) {
 
 	for _, s := range streams.AudioStreams {
 		transmuxedAudio := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
-		transcodedAudio := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
+		transcodedAudio, err := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		lowQualityAudio, _ := ffmpeg.StreamRepresentationFromRepresentationId(
 			s, "preset:128k-audio")
 
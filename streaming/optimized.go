@@ -0,0 +1,90 @@
+package streaming
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"strings"
+)
+
+// optimizedRepresentationPrefix prefixes representation IDs that are served by transmuxing an
+// optimized version of the file instead of transcoding the file on the fly, e.g.
+// "optimized:720-5000k-video".
+const optimizedRepresentationPrefix = "optimized:"
+
+// getOptimizedVideoRepresentations returns representations for all finished optimized versions of
+// the file the given video stream belongs to, keyed by the ID of the preset representation they
+// replace, e.g. "preset:720-5000k-video".
+func getOptimizedVideoRepresentations(stream ffmpeg.Stream) map[string]ffmpeg.StreamRepresentation {
+	representations := map[string]ffmpeg.StreamRepresentation{}
+
+	for _, v := range db.FindDoneOptimizedVersionsForFilePath(stream.FileLocator.String()) {
+		r, err := getOptimizedVideoRepresentation(v)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err, "uuid": v.UUID}).
+				Warnln("Failed to get streams of optimized version")
+			continue
+		}
+		representations["preset:"+v.Preset] = r
+	}
+
+	return representations
+}
+
+func getOptimizedVideoRepresentation(v db.OptimizedVersion) (ffmpeg.StreamRepresentation, error) {
+	fileLocator, err := filesystem.ParseFileLocator(v.FilePath)
+	if err != nil {
+		return ffmpeg.StreamRepresentation{}, err
+	}
+	streams, err := ffmpeg.GetStreams(fileLocator)
+	if err != nil {
+		return ffmpeg.StreamRepresentation{}, err
+	}
+	if len(streams.VideoStreams) == 0 {
+		return ffmpeg.StreamRepresentation{}, fmt.Errorf("optimized version has no video stream")
+	}
+
+	r := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
+	r.Representation.RepresentationId = optimizedRepresentationPrefix + v.Preset
+	return r, nil
+}
+
+// preferOptimizedRepresentations replaces preset representations that would be transcoded on the
+// fly with the matching optimized version, if one exists.
+func preferOptimizedRepresentations(
+	representations []ffmpeg.StreamRepresentation,
+	optimized map[string]ffmpeg.StreamRepresentation) []ffmpeg.StreamRepresentation {
+
+	res := []ffmpeg.StreamRepresentation{}
+	for _, r := range representations {
+		if o, ok := optimized[r.Representation.RepresentationId]; ok {
+			r = o
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// streamRepresentationFromRepresentationId is like ffmpeg.StreamRepresentationFromRepresentationId
+// but also knows about optimized versions of the stream's file.
+func streamRepresentationFromRepresentationId(
+	stream ffmpeg.Stream,
+	representationId string) (ffmpeg.StreamRepresentation, error) {
+
+	if !strings.HasPrefix(representationId, optimizedRepresentationPrefix) {
+		return ffmpeg.StreamRepresentationFromRepresentationId(stream, representationId)
+	}
+
+	preset := strings.TrimPrefix(representationId, optimizedRepresentationPrefix)
+	// Only consider optimized versions of the file that was authorized in the streaming JWT.
+	for _, v := range db.FindDoneOptimizedVersionsForFilePath(stream.FileLocator.String()) {
+		if v.Preset == preset {
+			return getOptimizedVideoRepresentation(v)
+		}
+	}
+
+	return ffmpeg.StreamRepresentation{},
+		fmt.Errorf("No optimized version %s found for file %s", preset, stream.FileLocator)
+}
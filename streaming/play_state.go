@@ -0,0 +1,58 @@
+package streaming
+
+import (
+	"flag"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+var playStateTrackingIntervalFlag = flag.Duration(
+	"play_state_tracking_interval",
+	30*time.Second,
+	"How often the play state of a user is inferred from the segments streamed to them. 0 disables tracking.")
+
+var playStateFinishedThresholdFlag = flag.Float64(
+	"play_state_finished_threshold",
+	90,
+	"Percentage of a media item after which it is marked as finished when tracking play states")
+
+// trackPlayState saves the user's position in the media item based on the served segment, at
+// most once per play_state_tracking_interval. Only video streams are tracked so that each item is
+// only saved once per session.
+func (s *PlaybackSession) trackPlayState(segmentIdx int) {
+	interval := *playStateTrackingIntervalFlag
+	stream := s.TranscodingSession.Stream.Stream
+	if interval == 0 || s.userID == 0 || stream.StreamType != "video" ||
+		time.Since(s.lastPlayStateTracked) < interval {
+		return
+	}
+	s.lastPlayStateTracked = time.Now()
+
+	playtime := time.Duration(segmentIdx) * ffmpeg.SegmentDuration
+	finished := stream.TotalDuration > 0 &&
+		float64(playtime)/float64(stream.TotalDuration)*100 >= *playStateFinishedThresholdFlag
+	userID := s.userID
+	filePath := s.FileLocator.String()
+
+	go func() {
+		mediaUUID, err := db.FindMediaUUIDForFilePath(filePath)
+		if err != nil {
+			log.WithFields(log.Fields{"file": filePath, "error": err}).
+				Debugln("Not tracking play state for file without media item")
+			return
+		}
+
+		// Give clients that report their play state themselves some slack, they know better.
+		_, err = db.SaveInferredPlayState(&db.PlayState{
+			MediaUUID: mediaUUID,
+			UserID:    userID,
+			Playtime:  playtime.Seconds(),
+			Finished:  finished,
+		}, 2*interval)
+		if err != nil {
+			log.WithFields(log.Fields{"file": filePath, "error": err}).Warnln("Failed to save play state")
+		}
+	}()
+}
@@ -0,0 +1,221 @@
+package streaming
+
+import (
+	"flag"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"time"
+)
+
+var prefetchThresholdFlag = flag.Float64(
+	"prefetch_next_episode_threshold",
+	0.9,
+	"Fraction of an episode after which the first segments of the next episode are transcoded ahead of time. 0 disables prefetching.")
+
+// prefetchKey identifies a prefetched session. It is a PlaybackSessionKey without the sessionID,
+// which the client will only choose once it starts playing the next episode.
+type prefetchKey struct {
+	ffmpeg.StreamKey
+	representationID string
+	userID           uint
+}
+
+// prefetchedSessions holds sessions that were started ahead of time, guarded by sessionsMutex.
+// Like all sessions, they are discarded by their timeout ticker if the client never uses them.
+var prefetchedSessions = map[prefetchKey]*PlaybackSession{}
+
+// prefetchTarget is a stream of the next episode to prefetch at the given representation.
+type prefetchTarget struct {
+	streamKey        ffmpeg.StreamKey
+	representationID string
+	userID           uint
+	// maxBitrate is the limit of the session the next episode is prefetched for.
+	maxBitrate int
+}
+
+// takePrefetchedSession removes and returns a prefetched session matching the given key, if any.
+// Must be called with sessionsMutex held.
+func takePrefetchedSession(playbackSessionKey PlaybackSessionKey) *PlaybackSession {
+	k := prefetchKey{
+		playbackSessionKey.StreamKey,
+		playbackSessionKey.representationID,
+		playbackSessionKey.userID,
+	}
+	s := prefetchedSessions[k]
+	if s != nil {
+		delete(prefetchedSessions, k)
+		log.WithFields(log.Fields{"file": k.FileLocator, "stream": k.StreamId}).
+			Debugln("Using prefetched playback session")
+	}
+	return s
+}
+
+// maybePrefetchNextEpisode starts prefetching the next episode if the given video session has
+// crossed the prefetch threshold.
+func maybePrefetchNextEpisode(s *PlaybackSession, segmentIdx int) {
+	// Guests only get to watch what was shared with them.
+	if *prefetchThresholdFlag <= 0 || s.userID == 0 {
+		return
+	}
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	stream := s.TranscodingSession.Stream.Stream
+	if s.nextEpisodePrefetched || stream.StreamType != "video" || stream.TotalDuration <= 0 {
+		return
+	}
+	position := time.Duration(segmentIdx) * ffmpeg.SegmentDuration
+	if position.Seconds()/stream.TotalDuration.Seconds() < *prefetchThresholdFlag {
+		return
+	}
+	s.nextEpisodePrefetched = true
+
+	// Prefetch all audio/video streams the client is currently playing in this session.
+	current := []*PlaybackSession{}
+	for _, other := range playbackSessions {
+		if other.sessionID == s.sessionID && other.userID == s.userID &&
+			other.FileLocator == s.FileLocator {
+			current = append(current, other)
+		}
+	}
+
+	go prefetchNextEpisode(s.FileLocator, s.userID, current)
+}
+
+func prefetchNextEpisode(fileLocator filesystem.FileLocator, userID uint, current []*PlaybackSession) {
+	logFields := log.Fields{"file": fileLocator, "user": userID}
+
+	next, err := findNextEpisodeFile(fileLocator, userID)
+	if err != nil {
+		log.WithFields(logFields).WithField("error", err).Debugln("Not prefetching next episode")
+		return
+	}
+	nextFileLocator, err := filesystem.ParseFileLocator(next.FilePath)
+	if err != nil {
+		return
+	}
+	currentStreams, err := ffmpeg.GetStreams(fileLocator)
+	if err != nil {
+		return
+	}
+	nextStreams, err := ffmpeg.GetStreams(nextFileLocator)
+	if err != nil {
+		log.WithFields(logFields).WithField("error", err).Warnln("Failed to get streams of next episode")
+		return
+	}
+
+	for _, s := range current {
+		target, ok := matchingStream(s.StreamKey, currentStreams, nextStreams)
+		if !ok {
+			continue
+		}
+		startPrefetch(prefetchTarget{target, s.representationID, userID, s.maxBitrate})
+	}
+}
+
+// findNextEpisodeFile finds the file of the episode the user will likely watch after the episode
+// in the given file, as computed by db.UpNextEpisodes.
+func findNextEpisodeFile(fileLocator filesystem.FileLocator, userID uint) (*db.EpisodeFile, error) {
+	file, err := db.FindEpisodeFileByPath(fileLocator.String())
+	if err != nil {
+		return nil, err
+	}
+	episode, err := db.FindEpisodeByID(file.EpisodeID)
+	if err != nil {
+		return nil, err
+	}
+	season, err := db.FindSeason(episode.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	var next *db.Episode
+	for _, ep := range db.UpNextEpisodes(userID) {
+		if ep.ID == episode.ID {
+			// The current episode isn't marked as finished yet, so the one after it is up next.
+			break
+		}
+		if epSeason, err := db.FindSeason(ep.SeasonID); err == nil && epSeason.SeriesID == season.SeriesID {
+			next = ep
+			break
+		}
+	}
+	if next == nil {
+		if next, err = db.NextEpisode(episode); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(next.EpisodeFiles) == 0 {
+		return nil, fmt.Errorf("episode %s has no files", next.UUID)
+	}
+	return &next.EpisodeFiles[0], nil
+}
+
+// matchingStream finds the stream in the next file that corresponds to the given stream of the
+// current file, i.e. the video stream or the audio stream at the same position.
+func matchingStream(
+	streamKey ffmpeg.StreamKey,
+	currentStreams *ffmpeg.Streams,
+	nextStreams *ffmpeg.Streams) (ffmpeg.StreamKey, bool) {
+
+	for _, s := range currentStreams.VideoStreams {
+		if s.StreamKey == streamKey && len(nextStreams.VideoStreams) > 0 {
+			return nextStreams.GetVideoStream().StreamKey, true
+		}
+	}
+	for i, s := range currentStreams.AudioStreams {
+		if s.StreamKey != streamKey || len(nextStreams.AudioStreams) == 0 {
+			continue
+		}
+		if i < len(nextStreams.AudioStreams) {
+			return nextStreams.AudioStreams[i].StreamKey, true
+		}
+		return nextStreams.AudioStreams[0].StreamKey, true
+	}
+	return ffmpeg.StreamKey{}, false
+}
+
+func startPrefetch(t prefetchTarget) {
+	k := prefetchKey{t.streamKey, t.representationID, t.userID}
+
+	sessionsMutex.Lock()
+	if prefetchedSessions[k] != nil {
+		sessionsMutex.Unlock()
+		return
+	}
+	s, err := NewPlaybackSession(
+		PlaybackSessionKey{
+			StreamKey:        t.streamKey,
+			representationID: t.representationID,
+			userID:           t.userID,
+		}, 0, t.maxBitrate)
+	if err != nil {
+		sessionsMutex.Unlock()
+		log.WithFields(log.Fields{"file": t.streamKey.FileLocator, "error": err}).
+			Warnln("Failed to prefetch next episode")
+		return
+	}
+	prefetchedSessions[k] = s
+	sessionsMutex.Unlock()
+
+	log.WithFields(log.Fields{"file": t.streamKey.FileLocator, "stream": t.streamKey.StreamId}).
+		Infoln("Prefetching next episode")
+}
+
+// removePrefetchedSession removes the given session from the prefetched sessions and returns
+// whether it was one.
+// Must be called with sessionsMutex held.
+func removePrefetchedSession(s *PlaybackSession) bool {
+	for k, p := range prefetchedSessions {
+		if p == s {
+			delete(prefetchedSessions, k)
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,6 @@ package streaming
 
 import (
 	"fmt"
-	"github.com/ncw/rclone/vfs"
 	"gitlab.com/olaris/olaris-server/filesystem"
 	"net/http"
 	"path"
@@ -10,9 +9,7 @@ import (
 )
 
 func serveRcloneFile(w http.ResponseWriter, r *http.Request, node filesystem.Node) {
-	rcloneNode := node.(*filesystem.RcloneNode)
-
-	f, err := rcloneNode.Node.(*vfs.File).Open(0)
+	f, err := node.Open()
 	if err != nil {
 		http.Error(w,
 			fmt.Sprintf(
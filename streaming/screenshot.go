@@ -0,0 +1,55 @@
+package streaming
+
+import (
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxScreenshotHeight is the biggest height screenshots can be scaled to.
+const maxScreenshotHeight = 2160
+
+// serveScreenshot serves a JPEG of the frame at the time given by the "t" query parameter
+// (in seconds). The optional "height" query parameter scales the frame to the given height, up to
+// maxScreenshotHeight.
+func serveScreenshot(w http.ResponseWriter, r *http.Request) {
+	fileLocator, statusErr := getFileLocatorOrFail(r)
+	if statusErr != nil {
+		http.Error(w, statusErr.Error(), statusErr.Status())
+		return
+	}
+
+	var seconds float64
+	if t := r.URL.Query().Get("t"); t != "" {
+		var err error
+		seconds, err = strconv.ParseFloat(t, 64)
+		if err != nil || seconds < 0 {
+			http.Error(w, "Invalid timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var height int
+	if h := r.URL.Query().Get("height"); h != "" {
+		var err error
+		height, err = strconv.Atoi(h)
+		if err != nil || height < 0 {
+			http.Error(w, "Invalid height", http.StatusBadRequest)
+			return
+		}
+		if height > maxScreenshotHeight {
+			height = maxScreenshotHeight
+		}
+	}
+
+	frame, err := ffmpeg.ExtractFrame(
+		fileLocator, time.Duration(seconds*float64(time.Second)), height)
+	if err != nil {
+		http.Error(w, "Failed to extract frame: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(frame)
+}
@@ -28,9 +28,15 @@ func serveInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := getStreamingClaims(mux.Vars(r)["fileLocator"])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	claims, ok := streamingClaimsFromRequest(r)
+	if !ok {
+		http.Error(w, "No valid streaming JWT in file locator", http.StatusBadRequest)
+		return
+	}
+
+	userID, guestID := sessionOwner(claims)
+	if reason, stopped := stoppedSessionReason(sessionID, userID, guestID); stopped {
+		http.Error(w, "Playback session was stopped: "+reason, http.StatusForbidden)
 		return
 	}
 
@@ -39,8 +45,26 @@ func serveInit(w http.ResponseWriter, r *http.Request) {
 			StreamKey:        streamKey,
 			sessionID:        sessionID,
 			representationID: representationId,
-			userID:           claims.UserID},
-		InitSegmentIdx)
+			userID:           userID,
+			guestID:          guestID},
+		InitSegmentIdx,
+		getMaxBitrate(r))
+	if err == ErrTooManySessions {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err == ErrSessionNotOwned || err == ErrBitrateLimitExceeded {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err == ffmpeg.ErrStaleRepresentationID {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	} else if err == ffmpeg.ErrInvalidRepresentationID {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	defer playbackSession.Release()
 
 	for {
@@ -53,6 +77,7 @@ func serveInit(w http.ResponseWriter, r *http.Request) {
 			log.Info("Serving path ", segmentPath, " with MIME type ", videoMIMEType)
 			w.Header().Set("Content-Type", videoMIMEType)
 			http.ServeFile(w, r, segmentPath)
+			playbackSession.recordServed(r, segmentPath)
 
 			playbackSession.lastAccessed = time.Now()
 			return
@@ -85,9 +110,15 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 		return
 	}
 
-	claims, err := getStreamingClaims(mux.Vars(r)["fileLocator"])
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	claims, ok := streamingClaimsFromRequest(r)
+	if !ok {
+		http.Error(w, "No valid streaming JWT in file locator", http.StatusBadRequest)
+		return
+	}
+
+	userID, guestID := sessionOwner(claims)
+	if reason, stopped := stoppedSessionReason(sessionID, userID, guestID); stopped {
+		http.Error(w, "Playback session was stopped: "+reason, http.StatusForbidden)
 		return
 	}
 
@@ -96,11 +127,31 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 			streamKey,
 			sessionID,
 			representationId,
-			claims.UserID,
+			userID,
+			guestID,
 		},
-		segmentIdx)
+		segmentIdx,
+		getMaxBitrate(r))
+	if err == ErrTooManySessions {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err == ErrSessionNotOwned || err == ErrBitrateLimitExceeded {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err == ffmpeg.ErrStaleRepresentationID {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	} else if err == ffmpeg.ErrInvalidRepresentationID {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	playbackSession.Release()
 
+	maybePrefetchNextEpisode(playbackSession, segmentIdx)
+
 	for {
 		availableSegments, err := playbackSession.TranscodingSession.AvailableSegments()
 		if err != nil {
@@ -112,6 +163,7 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 			log.Info("Serving path ", segmentPath, " with MIME type ", mimeType)
 			w.Header().Set("Content-Type", mimeType)
 			http.ServeFile(w, r, segmentPath)
+			playbackSession.recordServed(r, segmentPath)
 
 			// Sometimes video.js seems to request the same segment twice, deal with that.
 			if playbackSession.lastRequestedSegmentIdx != segmentIdx {
@@ -119,6 +171,7 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 				playbackSession.lastServedSegmentIdx++
 			}
 			playbackSession.lastAccessed = time.Now()
+			playbackSession.trackPlayState(segmentIdx)
 			return
 		} else {
 			time.Sleep(100 * time.Millisecond)
@@ -1,9 +1,11 @@
 package streaming
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"sync"
 	"time"
 )
@@ -12,21 +14,19 @@ const playbackSessionTimeout = 20 * time.Minute
 
 const InitSegmentIdx = -1
 
-// TODO(Leon Handreke): Figure out a better way than a package-global variable to
-// convey this info from the top-level command flag to ffmpeg. Or maybe a setter is enough?
-// Also this should go in some util package to build URLs
-var FfmpegUrlPort = 8080
-
 type PlaybackSessionKey struct {
 	ffmpeg.StreamKey
 
 	// Unique per user playback session, but shared between the different streams of that session.
 	sessionID string
 
-	// Identifies the representation of the stream, e.g. "direct" or "preset:480-1000k-video"
+	// Identifies the representation of the stream, e.g. "direct", "preset:480-1000k-video" or
+	// "optimized:480-1000k-video"
 	representationID string
 
-	userID uint
+	// userID is 0 for guests watching through a share link, who are told apart by guestID.
+	userID  uint
+	guestID string
 }
 
 type PlaybackSession struct {
@@ -52,6 +52,23 @@ type PlaybackSession struct {
 	referenceCount int
 
 	lastAccessed time.Time
+
+	// nextEpisodePrefetched is set once the next episode was prefetched for this session.
+	nextEpisodePrefetched bool
+
+	// Statistics for the active sessions dashboard
+	clientIP    string
+	bytesServed int64
+	firstServed time.Time
+
+	// maxBitrate is the video bitrate limit the session was started with, 0 if there is none.
+	maxBitrate int
+
+	// ticketID is the JTI of the streaming ticket the session was last accessed with.
+	ticketID string
+
+	// When the user's play state was last inferred from this session
+	lastPlayStateTracked time.Time
 }
 
 // Read-modify-write mutex for sessions. This ensures that two parallel requests don't both create a session.
@@ -59,22 +76,32 @@ var sessionsMutex = sync.Mutex{}
 
 var playbackSessions = map[PlaybackSessionKey]*PlaybackSession{}
 
-func NewPlaybackSession(playbackSessionKey PlaybackSessionKey, segmentIdx int) (*PlaybackSession, error) {
+func NewPlaybackSession(
+	playbackSessionKey PlaybackSessionKey,
+	segmentIdx int,
+	maxBitrate int) (*PlaybackSession, error) {
+
 	stream, err := ffmpeg.GetStream(playbackSessionKey.StreamKey)
 	if err != nil {
 		return nil, err
 	}
-	streamRepresentation, err := ffmpeg.StreamRepresentationFromRepresentationId(
+	streamRepresentation, err := streamRepresentationFromRepresentationId(
 		stream, playbackSessionKey.representationID)
+	if err != nil {
+		return nil, err
+	}
+	if exceedsBitrateLimit(streamRepresentation, maxBitrate) {
+		return nil, ErrBitrateLimitExceeded
+	}
 
 	playbackSessionID := uuid.New().String()
-	// TODO(Leon Handreke): Find a better way to build URLs
-
-	feedbackURL := fmt.Sprintf("http://127.0.0.1:%d/olaris/s/ffmpeg/%s/feedback",
-		FfmpegUrlPort, playbackSessionID)
 
+	// Local ffmpeg processes report through the internal server. The path is the same as the
+	// public route so that remote workers can use it on the public server.
+	feedbackURL, err := ffmpeg.InternalURL(
+		fmt.Sprintf("/olaris/s/ffmpeg/%s/feedback", playbackSessionID))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to build FFmpeg feedback url: %s", err.Error())
+		return nil, err
 	}
 
 	transcodingSession, err := ffmpeg.NewTranscodingSession(
@@ -93,6 +120,7 @@ func NewPlaybackSession(playbackSessionKey PlaybackSessionKey, segmentIdx int) (
 		lastServedSegmentIdx:    segmentIdx - 1,
 		referenceCount:          1,
 		lastAccessed:            time.Now(),
+		maxBitrate:              maxBitrate,
 	}
 	s.startTimeoutTicker()
 
@@ -106,14 +134,23 @@ func NewPlaybackSession(playbackSessionKey PlaybackSessionKey, segmentIdx int) (
 // representationID). This is useful to get  a session to serve the init segment from because
 // it doesn't matter where ffmpeg seeked to, the init segment will
 // always be the same.
+// If starting a new session would exceed the user's limit, ErrTooManySessions is returned. If the
+// session ID is already used by another user, ErrSessionNotOwned is returned. If a new session's
+// video representation exceeds maxBitrate, ErrBitrateLimitExceeded is returned.
 // The returned PlaybackSession must be released after use by calling ReleasePlaybackSession.
 func GetPlaybackSession(
 	playbackSessionKey PlaybackSessionKey,
-	segmentIdx int) (*PlaybackSession, error) {
+	segmentIdx int,
+	maxBitrate int) (*PlaybackSession, error) {
 
 	sessionsMutex.Lock()
 	defer sessionsMutex.Unlock()
 
+	if !sessionOwnedByLocked(
+		playbackSessionKey.sessionID, playbackSessionKey.userID, playbackSessionKey.guestID) {
+		return nil, ErrSessionNotOwned
+	}
+
 	s := playbackSessions[playbackSessionKey]
 
 	// If requesting the init segment, it doesn't matter where the existing session started,
@@ -140,6 +177,26 @@ func GetPlaybackSession(
 		return s, nil
 	}
 
+	// The client may be starting the episode we prefetched.
+	if s == nil && (segmentIdx == InitSegmentIdx || segmentIdx == 0) {
+		if p := takePrefetchedSession(playbackSessionKey); p != nil {
+			p.PlaybackSessionKey = playbackSessionKey
+			p.lastAccessed = time.Now()
+			playbackSessions[playbackSessionKey] = p
+
+			p.referenceCount++
+			go garbageCollectPlaybackSessions()
+			return p, nil
+		}
+	}
+
+	// Seeking within an existing session doesn't start another one for the user.
+	if s == nil {
+		if err := checkSessionLimitLocked(playbackSessionKey); err != nil {
+			return nil, err
+		}
+	}
+
 	// We are either seeking or no session exists yet. Destroy any existing session and
 	// start a new one
 	if s != nil {
@@ -155,7 +212,7 @@ func GetPlaybackSession(
 		startAtSegmentIdx = segmentIdx
 	}
 
-	s, err := NewPlaybackSession(playbackSessionKey, startAtSegmentIdx)
+	s, err := NewPlaybackSession(playbackSessionKey, startAtSegmentIdx, maxBitrate)
 	if err != nil {
 		return nil, err
 	}
@@ -167,16 +224,46 @@ func GetPlaybackSession(
 	return s, nil
 }
 
+// ErrSessionNotOwned is returned if a client tries to use a session ID of another user.
+var ErrSessionNotOwned = errors.New("Playback session belongs to another user")
+
+// sessionOwnedByLocked returns false if any stream of the given session is played by another user
+// or guest. Must be called with sessionsMutex held.
+func sessionOwnedByLocked(sessionID string, userID uint, guestID string) bool {
+	for _, s := range playbackSessions {
+		if s.sessionID == sessionID && (s.userID != userID || s.guestID != guestID) {
+			return false
+		}
+	}
+	for _, s := range prefetchedSessions {
+		if s.sessionID == sessionID && (s.userID != userID || s.guestID != guestID) {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionOwner returns who plays the sessions started with the given ticket. Guests watching
+// through a share link play as themselves rather than as the link's creator, so that they neither
+// count towards the creator's session limit nor change their play state.
+func sessionOwner(claims *auth.StreamingClaims) (userID uint, guestID string) {
+	if claims.ShareToken != "" {
+		return 0, claims.GuestID
+	}
+	return claims.UserID, ""
+}
+
 func garbageCollectPlaybackSessions() {
 	// Clean up streams after a user has switched representation or after they hhave started a
 	// new playback session for the same stream (e.g. by reloading the page)
 	type uniqueKey struct {
 		ffmpeg.StreamKey
-		userID uint
+		userID  uint
+		guestID string
 	}
 	playbackSessionsByUniqueKey := make(map[uniqueKey][]*PlaybackSession)
 	for _, s := range playbackSessions {
-		k := uniqueKey{s.StreamKey, s.userID}
+		k := uniqueKey{s.StreamKey, s.userID, s.guestID}
 		playbackSessionsByUniqueKey[k] = append(playbackSessionsByUniqueKey[k], s)
 	}
 
@@ -214,6 +301,14 @@ func GetPlaybackSessionByID(playbackSessionID string) (*PlaybackSession, error)
 		}
 	}
 
+	// Prefetched sessions also need feedback to be throttled
+	for _, v := range prefetchedSessions {
+		if s == nil && v.playbackSessionID == playbackSessionID {
+			s = v
+			break
+		}
+	}
+
 	if s == nil {
 		return nil, fmt.Errorf("No PlaybackSession with the given ID %s", playbackSessionID)
 	}
@@ -226,8 +321,21 @@ func removePlaybackSession(s *PlaybackSession) {
 	sessionsMutex.Lock()
 	defer sessionsMutex.Unlock()
 
-	delete(playbackSessions, s.PlaybackSessionKey)
-	s.Release()
+	removePlaybackSessionLocked(s)
+}
+
+// removePlaybackSessionLocked removes the session and releases it unless that already happened,
+// e.g. because it was stopped by an admin before it timed out.
+// Must be called with sessionsMutex held.
+func removePlaybackSessionLocked(s *PlaybackSession) {
+	tracked := removePrefetchedSession(s)
+	if playbackSessions[s.PlaybackSessionKey] == s {
+		delete(playbackSessions, s.PlaybackSessionKey)
+		tracked = true
+	}
+	if tracked {
+		s.Release()
+	}
 }
 
 func (s *PlaybackSession) Release() {
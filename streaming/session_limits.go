@@ -0,0 +1,44 @@
+package streaming
+
+import (
+	"errors"
+	"flag"
+	"time"
+)
+
+var maxSessionsPerUserFlag = flag.Int(
+	"max_sessions_per_user",
+	0,
+	"Maximum number of simultaneous playback sessions per user. 0 means no limit.")
+
+// Sessions that haven't requested a segment for this long don't count towards the limit anymore,
+// e.g. because the client was closed or paused.
+const sessionLimitIdleTimeout = 2 * time.Minute
+
+// ErrTooManySessions is returned if starting a playback session would exceed the user's limit.
+var ErrTooManySessions = errors.New("Too many simultaneous playback sessions for this user")
+
+// checkSessionLimitLocked returns ErrTooManySessions if the user already has the maximum number
+// of other playback sessions running. The streams of the same client session, e.g. after seeking,
+// don't count. Guests watching through a share link aren't limited. Must be called with
+// sessionsMutex held.
+func checkSessionLimitLocked(key PlaybackSessionKey) error {
+	if *maxSessionsPerUserFlag <= 0 || key.userID == 0 {
+		return nil
+	}
+
+	otherSessionIDs := map[string]bool{}
+	for _, s := range playbackSessions {
+		if s.userID != key.userID ||
+			s.sessionID == key.sessionID ||
+			time.Since(s.lastAccessed) > sessionLimitIdleTimeout {
+			continue
+		}
+		otherSessionIDs[s.sessionID] = true
+	}
+
+	if len(otherSessionIDs) >= *maxSessionsPerUserFlag {
+		return ErrTooManySessions
+	}
+	return nil
+}
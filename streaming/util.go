@@ -31,9 +31,7 @@ func getFileLocator(urlFileLocatorStr string) (filesystem.FileLocator, error) {
 
 func getStreamingClaims(urlFileLocator string) (*auth.StreamingClaims, error) {
 	// Allow both with and without leading slash, but canonical version is without
-	if urlFileLocator[0] == '/' {
-		urlFileLocator = urlFileLocator[1:]
-	}
+	urlFileLocator = strings.TrimPrefix(urlFileLocator, "/")
 
 	parts := strings.SplitN(urlFileLocator, "/", 2)
 
@@ -53,6 +51,9 @@ func getStreamingClaims(urlFileLocator string) (*auth.StreamingClaims, error) {
 }
 
 func _getFileLocator(urlFileLocator string, allowDirectFileAccess bool) (filesystem.FileLocator, error) {
+	if urlFileLocator == "" {
+		return filesystem.FileLocator{}, errors.New("Empty file locator")
+	}
 	// Allow both with and without leading slash, but canonical version is without
 	if urlFileLocator[0] == '/' {
 		urlFileLocator = urlFileLocator[1:]
@@ -60,6 +61,9 @@ func _getFileLocator(urlFileLocator string, allowDirectFileAccess bool) (filesys
 
 	parts := strings.SplitN(urlFileLocator, "/", 2)
 	if parts[0] == "jwt" {
+		if len(parts) < 2 {
+			return filesystem.FileLocator{}, errors.New("No JWT in file locator")
+		}
 		claims, err := auth.ValidateStreamingJWT(parts[1])
 		if err != nil {
 			return filesystem.FileLocator{},
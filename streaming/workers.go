@@ -0,0 +1,54 @@
+package streaming
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"net/http"
+)
+
+// workerAuthMiddleware only lets requests from transcoding workers with the right secret through.
+func workerAuthMiddleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ffmpeg.CheckWorkerSecret(r.Header.Get(ffmpeg.WorkerSecretHeader)) {
+			http.Error(w, "invalid worker secret", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func serveWorkerRegistration(w http.ResponseWriter, r *http.Request) {
+	registration := struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil || registration.URL == "" {
+		http.Error(w, "invalid registration", http.StatusBadRequest)
+		return
+	}
+
+	ffmpeg.RegisterTranscodingWorker(registration.Name, registration.URL)
+}
+
+func serveWorkerSegmentUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Tell the worker to stop if the session is gone.
+	if err := ffmpeg.StoreRemoteSegment(vars["jobID"], vars["fileName"], r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+}
+
+func serveWorkerJobDone(w http.ResponseWriter, r *http.Request) {
+	result := struct {
+		Error string `json:"error"`
+	}{}
+	json.NewDecoder(r.Body).Decode(&result)
+
+	if err := ffmpeg.FinishRemoteJob(mux.Vars(r)["jobID"], result.Error); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+}